@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/contact-tracker/apiService/cases"
+	t "github.com/contact-tracker/apiService/cases/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+type handler struct {
+	Usecase cases.CaseService
+	jwt     *auth.JWTService
+}
+
+func changedBy(ctx context.Context) string {
+	if _, claims := auth.ClaimsFromContext(ctx); claims != nil {
+		return claims.Subject
+	}
+	return "system"
+}
+
+func (d *handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+		cs, err := d.Usecase.Get(ctx, id)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, cs)
+	}
+}
+
+func (d *handler) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.GetCases{}
+		api.ParseHTTPParams(r, req)
+
+		resp, err := d.Usecase.GetAll(ctx, req)
+		api.CheckHTTPError(api.PageErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (d *handler) Report() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.ReportCase{}
+		api.ParseHTTPParams(r, req)
+
+		if userID, ok := cases.ReportPolicy.OwnerOnly(ctx); ok {
+			req.UserID = userID
+		}
+		req.ReportedBy = changedBy(ctx)
+		resp, err := d.Usecase.Report(ctx, req)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusCreated, resp)
+	}
+}
+
+func (d *handler) Notify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+		resp, err := d.Usecase.Notify(ctx, id, changedBy(ctx))
+		api.CheckHTTPError(statusErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (d *handler) UpdateStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.UpdateCaseStatus{}
+		api.ParseHTTPParams(r, req)
+
+		req.ID = chi.URLParam(r, "id")
+		req.ChangedBy = changedBy(ctx)
+		resp, err := d.Usecase.UpdateStatus(ctx, req)
+		api.CheckHTTPError(statusErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// statusErrorCode rejects invalid status changes as the caller's fault
+func statusErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok || errors.Cause(err) == cases.ErrInvalidTransition {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func NewServer(port string, cfg cases.Config) (server *api.Server, service *cases.CaseService, err error) {
+	fmt.Printf("Listening for cases on %s...\n", port)
+
+	svc, j, err := cases.Init(cfg)
+	if err != nil {
+		log.Panic(err)
+		return nil, nil, err
+	}
+	service = &svc
+
+	h := &handler{
+		Usecase: svc,
+		jwt:     j,
+	}
+
+	server = api.NewServer(port)
+	r := server.Router
+	r.Post("/cases", j.AuthorizeHandler(cases.ReportPolicy, h.Report()))
+	r.Get("/cases", j.AuthorizeHandler(cases.GetAllPolicy, h.GetAll()))
+	r.Get("/cases/{id}", j.AuthorizeHandler(cases.GetPolicy, h.Get()))
+	r.Put("/cases/{id}/status", j.AuthorizeHandler(cases.UpdateStatusPolicy, h.UpdateStatus()))
+	r.Post("/cases/{id}/notify", j.AuthorizeHandler(cases.NotifyPolicy, h.Notify()))
+
+	return
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	pkgErrors "github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/contact-tracker/apiService/cases"
+	t "github.com/contact-tracker/apiService/cases/types"
+	apiHttp "github.com/contact-tracker/apiService/pkg/api/http"
+	api "github.com/contact-tracker/apiService/pkg/api/lambda"
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+type handler struct {
+	usecase cases.CaseService
+	jwt     *auth.JWTService
+}
+
+func isAuthorized(ctx context.Context, req *api.Request, policy auth.Policy) error {
+	return policy.AuthorizeLambda(ctx, req)
+}
+
+func changedBy(ctx context.Context) string {
+	if _, claims := auth.ClaimsFromContext(ctx); claims != nil {
+		return claims.Subject
+	}
+	return "system"
+}
+
+func (h handler) router() func(context.Context, api.Request) (api.Response, error) {
+	return func(ctx context.Context, req api.Request) (api.Response, error) {
+
+		// Add cancellation deadline to context
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+
+		// Add auth
+		var err error
+		if ctx, err = h.jwt.IncludeLambdaAuth(ctx, &req); err != nil {
+			return api.Fail(err, http.StatusUnauthorized)
+		}
+
+		// Routes
+		if api.MatchesRoute("/cases", "POST", &req) {
+			if err := isAuthorized(ctx, &req, cases.ReportPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Report(ctx, &req)
+		} else if api.MatchesRoute("/cases", "GET", &req) {
+			if err := isAuthorized(ctx, &req, cases.GetAllPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.GetAll(ctx, &req)
+		} else if api.MatchesRoute("/cases/{id}", "GET", &req) {
+			if err := isAuthorized(ctx, &req, cases.GetPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Get(ctx, &req)
+		} else if api.MatchesRoute("/cases/{id}/status", "PUT", &req) {
+			if err := isAuthorized(ctx, &req, cases.UpdateStatusPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.UpdateStatus(ctx, &req)
+		} else if api.MatchesRoute("/cases/{id}/notify", "POST", &req) {
+			if err := isAuthorized(ctx, &req, cases.NotifyPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Notify(ctx, &req)
+		} else {
+			return api.Fail(errors.New("not found"), http.StatusNotFound)
+		}
+	}
+}
+
+// Get a case
+func (h *handler) Get(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	var cs *t.Case
+	if cs, err = h.usecase.Get(ctx, id); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(cs, http.StatusOK)
+}
+
+// GetAll cases
+func (h *handler) GetAll(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var query t.GetCases
+	if err = api.ParseQuery(req, &query); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
+	}
+	var cs *t.CasePage
+	if cs, err = h.usecase.GetAll(ctx, &query); err != nil {
+		return api.Fail(err, apiHttp.PageErrorCode(err))
+	}
+	return api.Success(cs, http.StatusOK)
+}
+
+// Report a positive case
+func (h *handler) Report(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req t.ReportCase
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if userID, ok := cases.ReportPolicy.OwnerOnly(ctx); ok {
+		req.UserID = userID
+	}
+	req.ReportedBy = changedBy(ctx)
+	var cs *t.Case
+	if cs, err = h.usecase.Report(ctx, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(cs, http.StatusCreated)
+}
+
+// UpdateStatus of a case
+func (h *handler) UpdateStatus(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req t.UpdateCaseStatus
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
+	}
+	if req.ID, err = api.GetPathParam("id", r); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	req.ChangedBy = changedBy(ctx)
+	var cs *t.Case
+	if cs, err = h.usecase.UpdateStatus(ctx, &req); err != nil {
+		return api.Fail(err, statusErrorCode(err))
+	}
+	return api.Success(cs, http.StatusOK)
+}
+
+// statusErrorCode rejects invalid status changes as the caller's fault
+func statusErrorCode(err error) int {
+	if _, ok := pkgErrors.Cause(err).(validator.ValidationErrors); ok || pkgErrors.Cause(err) == cases.ErrInvalidTransition {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// Notify the contacts of a case
+func (h *handler) Notify(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	var cs *t.Case
+	if cs, err = h.usecase.Notify(ctx, id, changedBy(ctx)); err != nil {
+		return api.Fail(err, statusErrorCode(err))
+	}
+	return api.Success(cs, http.StatusOK)
+}
+
+func main() {
+	fmt.Println("Starting cases lambda main...")
+	usecase, j, err := cases.Init(cases.Config{
+		MongoDBName:   os.Getenv("MONGO_DB_NAME"),
+		MongoURI:      os.Getenv("MONGO_URI"),
+		MongoUser:     os.Getenv("MONGO_USER"),
+		MongoPwd:      os.Getenv("MONGO_PWD"),
+		UsersHost:     os.Getenv("USERS_HOST"),
+		CheckInsHost:  os.Getenv("CHECK_INS_HOST"),
+		JWTKeyPath:    os.Getenv("JWT_KEY_PATH"),
+		JWTSecretPath: os.Getenv("JWT_SECRET_PATH"),
+		TraceDepth:    os.Getenv("CASES_TRACE_DEPTH"),
+		MinRiskScore:  os.Getenv("CASES_MIN_RISK_SCORE"),
+		Storage:       os.Getenv("STORAGE"),
+		PostgresURL:   os.Getenv("POSTGRES_URL"),
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	log.Println("After init usecase...")
+
+	h := &handler{usecase, j}
+	lambda.Start(h.router())
+}
@@ -0,0 +1,71 @@
+package cases
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	t "github.com/contact-tracker/apiService/cases/types"
+)
+
+// LoggerAdapter wraps the usecase interface
+// with a logging adapter which can be swapped out
+type LoggerAdapter struct {
+	Logger  *zap.Logger
+	Usecase CaseService
+}
+
+func (a *LoggerAdapter) logErr(err error) {
+	if err != nil {
+		a.Logger.Error(err.Error())
+	}
+}
+
+// Get a single case
+func (a *LoggerAdapter) Get(ctx context.Context, id string) (*t.Case, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("id", id))
+	a.Logger.Info("getting a single case")
+	cs, err := a.Usecase.Get(ctx, id)
+	a.logErr(err)
+	return cs, err
+}
+
+// GetAll gets a page of cases
+func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetCases) (*t.CasePage, error) {
+	defer a.Logger.Sync()
+	a.Logger.Info("getting all cases")
+	cases, err := a.Usecase.GetAll(ctx, req)
+	a.logErr(err)
+	return cases, err
+}
+
+// Report a positive case
+func (a *LoggerAdapter) Report(ctx context.Context, req *t.ReportCase) (*t.Case, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("userId", req.UserID), zap.String("reportedBy", req.ReportedBy))
+	a.Logger.Info("reporting a case")
+	cs, err := a.Usecase.Report(ctx, req)
+	a.logErr(err)
+	return cs, err
+}
+
+// Notify the contacts of a case
+func (a *LoggerAdapter) Notify(ctx context.Context, id, changedBy string) (*t.Case, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("id", id))
+	a.Logger.Info("notifying case contacts")
+	cs, err := a.Usecase.Notify(ctx, id, changedBy)
+	a.logErr(err)
+	return cs, err
+}
+
+// UpdateStatus of a case
+func (a *LoggerAdapter) UpdateStatus(ctx context.Context, req *t.UpdateCaseStatus) (*t.Case, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("id", req.ID), zap.String("status", string(req.Status)))
+	a.Logger.Info("updating case status")
+	cs, err := a.Usecase.UpdateStatus(ctx, req)
+	a.logErr(err)
+	return cs, err
+}
@@ -0,0 +1,21 @@
+package cases
+
+import (
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+// Route policies, shared by the http and lambda deliveries
+var (
+	// ReportPolicy - customers may only report their own case, passing
+	// their id as the userId query param. Places aren't allowed, as
+	// anyone can sign one up and a report alerts the user's contacts.
+	ReportPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).Own("userId", auth.RoleCustomer)
+	// GetAllPolicy - customers may only list their own cases
+	GetAllPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).Own("userId", auth.RoleCustomer).WithScope(auth.ScopeReadCases)
+	// GetPolicy - only health officials may read any case
+	GetPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem)
+	// UpdateStatusPolicy - only health officials may move a case along
+	UpdateStatusPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem)
+	// NotifyPolicy - only health officials may notify a case's contacts
+	NotifyPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem)
+)
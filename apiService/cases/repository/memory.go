@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	t "github.com/contact-tracker/apiService/cases/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/memory"
+)
+
+// MemoryCaseRepository - keeps cases in memory, for running without mongo
+type MemoryCaseRepository struct {
+	dbname string
+}
+
+// NewMemoryCaseRepository -
+func NewMemoryCaseRepository(dbname string) *MemoryCaseRepository {
+	return &MemoryCaseRepository{dbname}
+}
+
+func (r *MemoryCaseRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryCaseRepository) Get(_ context.Context, id string) (*t.Case, error) {
+	c := r.C(ColCases)
+	c.RLock()
+	defer c.RUnlock()
+
+	doc, ok := c.Get(id)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	return copyCase(doc.(*t.Case)), nil
+}
+
+func (r *MemoryCaseRepository) GetAll(_ context.Context, req *t.GetCases, page *api.PageQuery) ([]*t.Case, error) {
+	c := r.C(ColCases)
+	c.RLock()
+	defer c.RUnlock()
+
+	cases := []*t.Case{}
+	c.Each(func(doc interface{}) bool {
+		cs := doc.(*t.Case)
+		if (req.UserID == nil || cs.UserID == *req.UserID) && (req.Status == nil || cs.Status == *req.Status) {
+			cases = append(cases, copyCase(cs))
+		}
+		return true
+	})
+	after, afterID := page.Start()
+
+	resp := []*t.Case{}
+	for _, i := range memory.Page(len(cases), func(i int) (string, string) {
+		return cases[i].SortValue(page.Field), cases[i].ID
+	}, page.Desc, after, afterID, page.Limit+1) {
+		resp = append(resp, cases[i])
+	}
+	return resp, nil
+}
+
+func (r *MemoryCaseRepository) Create(_ context.Context, cs *t.Case) (*t.Case, error) {
+	c := r.C(ColCases)
+	c.Lock()
+	defer c.Unlock()
+
+	if cs.ID == "" {
+		cs.ID = uuid.New().String()
+	}
+	stored := copyCase(cs)
+	c.Put(stored.ID, stored)
+	return copyCase(stored), nil
+}
+
+func (r *MemoryCaseRepository) UpdateStatus(_ context.Context, id string, change *t.StatusChange, notified []string) (*t.Case, error) {
+	c := r.C(ColCases)
+	c.Lock()
+	defer c.Unlock()
+
+	doc, ok := c.Get(id)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	cs := copyCase(doc.(*t.Case))
+	cs.Status = change.Status
+	if notified != nil {
+		cs.NotifiedContacts = append([]string{}, notified...)
+	}
+	statusChange := *change
+	cs.StatusHistory = append(cs.StatusHistory, &statusChange)
+	c.Put(cs.ID, cs)
+	return copyCase(cs), nil
+}
+
+func copyCase(cs *t.Case) *t.Case {
+	cp := *cs
+	cp.NotifiedContacts = append([]string(nil), cs.NotifiedContacts...)
+	cp.StatusHistory = append([]*t.StatusChange(nil), cs.StatusHistory...)
+	return &cp
+}
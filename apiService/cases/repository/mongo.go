@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	t "github.com/contact-tracker/apiService/cases/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	m "github.com/contact-tracker/apiService/pkg/mongo"
+)
+
+var ColCases = "cases"
+
+// MongoMigrations - indexes of the cases collection
+var MongoMigrations = []m.Migration{
+	{
+		// a user's cases and cases by status, newest first
+		Version: "cases-0001-indexes",
+		Up: m.CreateIndexes(ColCases,
+			m.IndexKey("userId", "-createdAt"),
+			m.IndexKey("status", "-createdAt"),
+		),
+	},
+}
+
+// MongoCaseRepository -
+type MongoCaseRepository struct {
+	client *mongo.Client
+	dbname string
+}
+
+// NewMongoCaseRepository -
+func NewMongoCaseRepository(client *mongo.Client, dbname string) *MongoCaseRepository {
+	return &MongoCaseRepository{client, dbname}
+}
+
+// Migrate creates or updates the cases indexes
+func (r *MongoCaseRepository) Migrate(ctx context.Context) error {
+	return m.Migrate(ctx, r.DB(), MongoMigrations)
+}
+
+func (r *MongoCaseRepository) DB() *mongo.Database {
+	return r.client.Database(r.dbname)
+}
+
+func (r *MongoCaseRepository) C(colName string) *mongo.Collection {
+	return r.DB().Collection(colName)
+}
+
+func (r *MongoCaseRepository) Get(ctx context.Context, id string) (resp *t.Case, err error) {
+	c := r.C(ColCases)
+
+	err = m.FindOne(ctx, c, &resp, m.M{"_id": id})
+	return
+}
+
+func (r *MongoCaseRepository) GetAll(ctx context.Context, req *t.GetCases, page *api.PageQuery) (resp []*t.Case, err error) {
+	c := r.C(ColCases)
+
+	query := m.M{}
+	if req.UserID != nil {
+		query["userId"] = *req.UserID
+	}
+	if req.Status != nil {
+		query["status"] = *req.Status
+	}
+	var after interface{}
+	if page.After != nil {
+		if after, err = page.After.Time(); err != nil {
+			return
+		}
+	}
+	_, afterID := page.Start()
+
+	resp = []*t.Case{}
+	err = m.FindPage(ctx, c, &resp, query, "createdAt", page.Desc, after, afterID, page.Limit+1)
+	return
+}
+
+func (r *MongoCaseRepository) Create(ctx context.Context, cs *t.Case) (*t.Case, error) {
+	c := r.C(ColCases)
+
+	if cs.ID == "" {
+		cs.ID = uuid.New().String()
+	}
+
+	var resp t.Case
+	err := m.Upsert(ctx, c, &resp, m.M{"_id": cs.ID}, m.M{"$set": cs})
+	return &resp, err
+}
+
+func (r *MongoCaseRepository) UpdateStatus(ctx context.Context, id string, change *t.StatusChange, notified []string) (*t.Case, error) {
+	c := r.C(ColCases)
+
+	set := m.M{"status": change.Status}
+	if notified != nil {
+		set["notified"] = notified
+	}
+
+	var resp t.Case
+	err := m.Update(ctx, c, &resp, m.M{"_id": id}, m.M{
+		"$set":  set,
+		"$push": m.M{"statusHist": change},
+	})
+	return &resp, err
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	t "github.com/contact-tracker/apiService/cases/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+)
+
+// PostgresMigrations - schema of the cases table
+var PostgresMigrations = []pg.Migration{
+	{
+		Version: "cases-0001-create-cases",
+		SQL: `CREATE TABLE cases (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			reported_by text NOT NULL DEFAULT '',
+			test_date timestamptz,
+			symptom_onset timestamptz,
+			infectious_start timestamptz,
+			infectious_end timestamptz,
+			status text NOT NULL,
+			notified text[] NOT NULL DEFAULT '{}',
+			status_history jsonb NOT NULL DEFAULT '[]',
+			created_at timestamptz
+		);
+		CREATE INDEX cases_user_idx ON cases (user_id, created_at DESC);
+		CREATE INDEX cases_status_idx ON cases (status, created_at DESC);`,
+	},
+}
+
+const caseColumns = "id, user_id, reported_by, test_date, symptom_onset, infectious_start, infectious_end, status, notified, status_history, created_at"
+
+// PostgresCaseRepository -
+type PostgresCaseRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresCaseRepository -
+func NewPostgresCaseRepository(db *sql.DB) *PostgresCaseRepository {
+	return &PostgresCaseRepository{db}
+}
+
+// Migrate creates or updates the cases table
+func (r *PostgresCaseRepository) Migrate(ctx context.Context) error {
+	return pg.Migrate(ctx, r.db, PostgresMigrations)
+}
+
+func (r *PostgresCaseRepository) Get(ctx context.Context, id string) (*t.Case, error) {
+	return scanCase(r.db.QueryRowContext(ctx, "SELECT "+caseColumns+" FROM cases WHERE id = $1", id))
+}
+
+func (r *PostgresCaseRepository) GetAll(ctx context.Context, req *t.GetCases, page *api.PageQuery) ([]*t.Case, error) {
+	args := pg.Args{}
+	where := []string{"true"}
+	if req.UserID != nil {
+		where = append(where, "user_id = "+args.Add(*req.UserID))
+	}
+	if req.Status != nil {
+		where = append(where, "status = "+args.Add(string(*req.Status)))
+	}
+	var after interface{}
+	if page.After != nil {
+		createdAt, err := page.After.Time()
+		if err != nil {
+			return nil, err
+		}
+		after = createdAt
+	}
+	_, afterID := page.Start()
+	order := pg.Page(&where, &args, "created_at", "id", page.Desc, after, afterID, page.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+caseColumns+" FROM cases WHERE "+strings.Join(where, " AND ")+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*t.Case{}
+	for rows.Next() {
+		cs, err := scanCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, cs)
+	}
+	return resp, rows.Err()
+}
+
+func (r *PostgresCaseRepository) Create(ctx context.Context, cs *t.Case) (*t.Case, error) {
+	if cs.ID == "" {
+		cs.ID = uuid.New().String()
+	}
+	history, err := json.Marshal(statusHistory(cs.StatusHistory))
+	if err != nil {
+		return nil, err
+	}
+	return scanCase(r.db.QueryRowContext(ctx, "INSERT INTO cases ("+caseColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"+
+		" ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, reported_by = EXCLUDED.reported_by, test_date = EXCLUDED.test_date,"+
+		" symptom_onset = EXCLUDED.symptom_onset, infectious_start = EXCLUDED.infectious_start, infectious_end = EXCLUDED.infectious_end,"+
+		" status = EXCLUDED.status, notified = EXCLUDED.notified, status_history = EXCLUDED.status_history, created_at = EXCLUDED.created_at"+
+		" RETURNING "+caseColumns,
+		cs.ID, cs.UserID, cs.ReportedBy, cs.TestDate, cs.SymptomOnset, cs.InfectiousStart, cs.InfectiousEnd, string(cs.Status),
+		pq.Array(notifiedContacts(cs.NotifiedContacts)), string(history), cs.CreatedAt))
+}
+
+func (r *PostgresCaseRepository) UpdateStatus(ctx context.Context, id string, change *t.StatusChange, notified []string) (*t.Case, error) {
+	history, err := json.Marshal([]*t.StatusChange{change})
+	if err != nil {
+		return nil, err
+	}
+	set := pg.Assignments{}
+	set.Set("status", string(change.Status))
+	if notified != nil {
+		set.Set("notified", pq.Array(notified))
+	}
+	query := "UPDATE cases SET " + set.String() + ", status_history = status_history || " + set.Args.Add(string(history)) + "::jsonb" +
+		" WHERE id = " + set.Args.Add(id) + " RETURNING " + caseColumns
+	return scanCase(r.db.QueryRowContext(ctx, query, set.Args...))
+}
+
+func scanCase(row pg.Scanner) (*t.Case, error) {
+	var (
+		cs      t.Case
+		status  string
+		history []byte
+	)
+	if err := row.Scan(&cs.ID, &cs.UserID, &cs.ReportedBy, &cs.TestDate, &cs.SymptomOnset, &cs.InfectiousStart, &cs.InfectiousEnd,
+		&status, pq.Array(&cs.NotifiedContacts), &history, &cs.CreatedAt); err != nil {
+		return nil, err
+	}
+	cs.Status = t.CaseStatus(status)
+	if err := json.Unmarshal(history, &cs.StatusHistory); err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+func notifiedContacts(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+func statusHistory(changes []*t.StatusChange) []*t.StatusChange {
+	if changes == nil {
+		return []*t.StatusChange{}
+	}
+	return changes
+}
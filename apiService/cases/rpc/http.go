@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	chkT "github.com/contact-tracker/apiService/check-ins/types"
+	apiRpc "github.com/contact-tracker/apiService/pkg/api/rpc"
+	"github.com/contact-tracker/apiService/pkg/auth"
+	uT "github.com/contact-tracker/apiService/users/types"
+)
+
+// RPCClient - Make RPC calls via HTTP
+type RPCClient struct {
+	checkInsClient   *apiRpc.HTTPRPCClient
+	usersClient      *apiRpc.HTTPRPCClient
+	checkInsHostName string
+	usersHostName    string
+}
+
+// NewRPCClient - Initializes new client
+func NewRPCClient(checkInsHostName, usersHostName string, tokens apiRpc.TokenSource) *RPCClient {
+	return &RPCClient{
+		checkInsClient:   apiRpc.NewHTTPRPCClient(tokens, auth.ServiceCheckIns, auth.ScopeTraceContacts),
+		usersClient:      apiRpc.NewHTTPRPCClient(tokens, auth.ServiceUsers, auth.ScopeAlertUsers),
+		checkInsHostName: checkInsHostName,
+		usersHostName:    usersHostName,
+	}
+}
+
+func (c *RPCClient) TraceContacts(ctx context.Context, req *chkT.TraceContacts) (*chkT.ContactTrace, error) {
+	query := url.Values{}
+	query.Set("start", req.Start.Format(time.RFC3339))
+	query.Set("end", req.End.Format(time.RFC3339))
+	query.Set("depth", strconv.Itoa(req.Depth))
+	query.Set("minRiskScore", strconv.FormatFloat(req.MinRiskScore, 'f', -1, 64))
+
+	var trace chkT.ContactTrace
+	path := fmt.Sprintf("%s/check-ins/trace/%s?%s", c.checkInsHostName, req.UserID, query.Encode())
+	if code, err := c.checkInsClient.HttpRequest("GET", path, nil, &trace); err != nil {
+		return nil, errors.Wrapf(err, "error tracing contacts of user %s, status %d", req.UserID, code)
+	}
+
+	return &trace, nil
+}
+
+func (c *RPCClient) AlertUsers(ctx context.Context, ids []string) error {
+	req := &uT.AlertUsers{IDs: ids}
+	if code, err := c.usersClient.HttpRequest("POST", fmt.Sprintf("%s/users/alert", c.usersHostName), req, nil); err != nil {
+		return errors.Wrapf(err, "error alerting %d user(s), status %d", len(ids), code)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package cases
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strconv"
+
+	repo "github.com/contact-tracker/apiService/cases/repository"
+	casesRpc "github.com/contact-tracker/apiService/cases/rpc"
+	t "github.com/contact-tracker/apiService/cases/types"
+	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/contact-tracker/apiService/pkg/memory"
+	m "github.com/contact-tracker/apiService/pkg/mongo"
+	"github.com/contact-tracker/apiService/pkg/postgres"
+)
+
+// CaseService - is the top level signature of this service
+type CaseService interface {
+	Get(ctx context.Context, id string) (*t.Case, error)
+	GetAll(ctx context.Context, req *t.GetCases) (*t.CasePage, error)
+	Report(ctx context.Context, req *t.ReportCase) (*t.Case, error)
+	Notify(ctx context.Context, id, changedBy string) (*t.Case, error)
+	UpdateStatus(ctx context.Context, req *t.UpdateCaseStatus) (*t.Case, error)
+}
+
+// Config - settings for Init, as read from the environment. Empty
+// numbers fall back to their defaults.
+type Config struct {
+	MongoDBName   string
+	MongoURI      string
+	MongoUser     string
+	MongoPwd      string
+	UsersHost     string
+	CheckInsHost  string
+	JWTKeyPath    string
+	JWTSecretPath string
+	// TraceDepth - how many rounds of contacts a report traces
+	TraceDepth string
+	// MinRiskScore - the lowest risk score of a contact that is alerted
+	MinRiskScore string
+	// Storage picks the repositories, mongo by default
+	Storage     string
+	PostgresURL string
+}
+
+// Init sets up an instance of this domains
+// usecase, pre-configured with the dependencies.
+func Init(cfg Config) (CaseService, *auth.JWTService, error) {
+	// Init repositories in the configured storage, mongo by default
+	var (
+		repository  repository
+		revocations auth.RevocationStore
+	)
+	switch cfg.Storage {
+	case memory.Storage:
+		repository = repo.NewMemoryCaseRepository(cfg.MongoDBName)
+		revocations = authRepo.NewMemoryRevocationRepository(cfg.MongoDBName)
+	case postgres.Storage:
+		db, err := postgres.NewClient(cfg.PostgresURL)
+		if err != nil {
+			log.Fatalf("Error starting postgres client: Error: %v\n", err)
+		}
+		pgRepo := repo.NewPostgresCaseRepository(db)
+		if err = pgRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating postgres schema: %v\n", err)
+		}
+		if err = authRepo.MigratePostgres(context.Background(), db); err != nil {
+			log.Fatalf("Error migrating postgres auth schema: %v\n", err)
+		}
+		repository = pgRepo
+		revocations = authRepo.NewPostgresRevocationRepository(db)
+	default:
+		mc, err := m.NewClient(cfg.MongoURI, cfg.MongoUser, cfg.MongoPwd)
+		if err != nil {
+			log.Fatalf("Error starting mongo client: Error: %v\n", err)
+		}
+		mongoRepo := repo.NewMongoCaseRepository(mc, cfg.MongoDBName)
+		if err = mongoRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating mongo indexes: %v\n", err)
+		}
+		if err = authRepo.MigrateMongo(context.Background(), mc, cfg.MongoDBName); err != nil {
+			log.Fatalf("Error migrating mongo auth indexes: %v\n", err)
+		}
+		repository = mongoRepo
+		revocations = authRepo.NewMongoRevocationRepository(mc, cfg.MongoDBName)
+	}
+
+	// Init jwt service
+	jwtKey, err := ioutil.ReadFile(cfg.JWTKeyPath)
+	if err != nil {
+		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", cfg.JWTKeyPath, err)
+	}
+	jwtSecret, err := ioutil.ReadFile(cfg.JWTSecretPath)
+	if err != nil {
+		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", cfg.JWTSecretPath, err)
+	}
+	j, err := auth.NewJWTService(auth.JWTServiceConfig{
+		Key:         jwtKey,
+		Secret:      jwtSecret,
+		Service:     auth.ServiceCases,
+		Revocations: revocations,
+	})
+	if err != nil {
+		log.Fatalf("Error creating jwt service: %v\n", err)
+	}
+
+	// Init rpc client, signing its calls with service tokens
+	rpcClient := casesRpc.NewRPCClient(cfg.CheckInsHost, cfg.UsersHost, j)
+
+	// Tracing config
+	depth := 1
+	if cfg.TraceDepth != "" {
+		if depth, err = strconv.Atoi(cfg.TraceDepth); err != nil {
+			log.Fatalf("Error parsing case trace depth: %v\n", err)
+		}
+	}
+	var minScore float64
+	if cfg.MinRiskScore != "" {
+		if minScore, err = strconv.ParseFloat(cfg.MinRiskScore, 64); err != nil {
+			log.Fatalf("Error parsing case min risk score: %v\n", err)
+		}
+	}
+
+	usecase := &Usecase{
+		Repository:   repository,
+		RPC:          rpcClient,
+		TraceDepth:   depth,
+		MinRiskScore: minScore,
+	}
+	return usecase, j, nil
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"time"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+)
+
+// CaseStatus - where a case is in the reporting workflow
+type CaseStatus string
+
+const (
+	// StatusReported - case has been reported but contacts not yet notified
+	StatusReported CaseStatus = "reported"
+	// StatusVerified - positive test has been verified
+	StatusVerified CaseStatus = "verified"
+	// StatusNotified - contacts of the case have been notified
+	StatusNotified CaseStatus = "notified"
+	// StatusClosed - case is no longer being investigated
+	StatusClosed CaseStatus = "closed"
+)
+
+// statusTransitions - statuses a case may move to from each status
+var statusTransitions = map[CaseStatus][]CaseStatus{
+	StatusReported: {StatusVerified, StatusNotified, StatusClosed},
+	StatusVerified: {StatusNotified, StatusClosed},
+	StatusNotified: {StatusVerified, StatusClosed},
+	StatusClosed:   {},
+}
+
+// OpenStatuses - statuses of cases still being investigated
+var OpenStatuses = []CaseStatus{StatusReported, StatusVerified, StatusNotified}
+
+// CanTransition returns true if a case may move from this status to next
+func (s CaseStatus) CanTransition(next CaseStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Days around the test date or symptom onset that a case is infectious
+const (
+	InfectiousDaysBefore = 2
+	InfectiousDaysAfter  = 10
+)
+
+// Case - a reported positive test
+type Case struct {
+	ID               string          `bson:"_id" json:"id"`
+	UserID           string          `bson:"userId" json:"userId"`
+	ReportedBy       string          `bson:"reportedBy" json:"reportedBy"`
+	TestDate         *time.Time      `bson:"testDate" json:"testDate"`
+	SymptomOnset     *time.Time      `bson:"symptomOnset,omitempty" json:"symptomOnset,omitempty"`
+	InfectiousStart  *time.Time      `bson:"infStart" json:"infectiousStart"`
+	InfectiousEnd    *time.Time      `bson:"infEnd" json:"infectiousEnd"`
+	Status           CaseStatus      `bson:"status" json:"status"`
+	NotifiedContacts []string        `bson:"notified" json:"notifiedContacts"`
+	StatusHistory    []*StatusChange `bson:"statusHist" json:"statusHistory"`
+	CreatedAt        *time.Time      `bson:"createdAt" json:"createdAt"`
+}
+
+// StatusChange - audit record of a case changing status
+type StatusChange struct {
+	Status    CaseStatus `bson:"status" json:"status"`
+	ChangedBy string     `bson:"changedBy" json:"changedBy"`
+	ChangedAt *time.Time `bson:"changedAt" json:"changedAt"`
+	Note      string     `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// ReportCase - request to report a positive test
+type ReportCase struct {
+	UserID       string     `json:"userId" validate:"required"`
+	ReportedBy   string     `json:"-"`
+	TestDate     *time.Time `json:"testDate" validate:"required"`
+	SymptomOnset *time.Time `json:"symptomOnset"`
+}
+
+// InfectiousWindow derives when the reported case was infectious. The window
+// is anchored on symptom onset when known, otherwise on the test date.
+func (r ReportCase) InfectiousWindow(now time.Time) (start, end time.Time) {
+	anchor := *r.TestDate
+	if r.SymptomOnset != nil && r.SymptomOnset.Before(anchor) {
+		anchor = *r.SymptomOnset
+	}
+	start = anchor.AddDate(0, 0, -InfectiousDaysBefore)
+	end = anchor.AddDate(0, 0, InfectiousDaysAfter)
+	if end.After(now) {
+		end = now
+	}
+	return
+}
+
+// UpdateCaseStatus - request to move a case to a new status
+type UpdateCaseStatus struct {
+	ID        string     `json:"-"`
+	Status    CaseStatus `json:"status" validate:"required,oneof=reported verified notified closed"`
+	ChangedBy string     `json:"-"`
+	Note      string     `json:"note"`
+}
+
+// CaseSorts - fields cases may be sorted by, the first by default
+var CaseSorts = []string{"-createdAt"}
+
+// SortValue returns the value of one of CaseSorts
+func (c Case) SortValue(field string) string {
+	return api.TimeValue(c.CreatedAt)
+}
+
+// GetCases - filters and page of a list of cases
+type GetCases struct {
+	api.PageRequest
+	UserID *string     `json:"userId"`
+	Status *CaseStatus `json:"status"`
+}
+
+// CasePage - a page of cases
+type CasePage struct {
+	Items []*Case `json:"items"`
+	api.PageInfo
+}
@@ -0,0 +1,34 @@
+package types
+
+import "testing"
+
+func TestCanTransition(tt *testing.T) {
+	tests := []struct {
+		from CaseStatus
+		to   CaseStatus
+		ok   bool
+	}{
+		{StatusReported, StatusReported, false},
+		{StatusReported, StatusVerified, true},
+		{StatusReported, StatusNotified, true},
+		{StatusReported, StatusClosed, true},
+		{StatusVerified, StatusReported, false},
+		{StatusVerified, StatusVerified, false},
+		{StatusVerified, StatusNotified, true},
+		{StatusVerified, StatusClosed, true},
+		{StatusNotified, StatusReported, false},
+		{StatusNotified, StatusVerified, true},
+		{StatusNotified, StatusNotified, false},
+		{StatusNotified, StatusClosed, true},
+		{StatusClosed, StatusReported, false},
+		{StatusClosed, StatusVerified, false},
+		{StatusClosed, StatusNotified, false},
+		{StatusClosed, StatusClosed, false},
+		{"unknown", StatusClosed, false},
+	}
+	for _, test := range tests {
+		if ok := test.from.CanTransition(test.to); ok != test.ok {
+			tt.Errorf("Moving from %s to %s got %t, want %t", test.from, test.to, ok, test.ok)
+		}
+	}
+}
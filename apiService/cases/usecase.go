@@ -0,0 +1,189 @@
+package cases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	t "github.com/contact-tracker/apiService/cases/types"
+	chkT "github.com/contact-tracker/apiService/check-ins/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+var (
+	validate *validator.Validate
+)
+
+// ErrInvalidTransition - a case can't move from its status to the one asked for
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+type repository interface {
+	Get(ctx context.Context, id string) (*t.Case, error)
+	GetAll(ctx context.Context, req *t.GetCases, page *api.PageQuery) ([]*t.Case, error)
+	Create(ctx context.Context, cs *t.Case) (*t.Case, error)
+	UpdateStatus(ctx context.Context, id string, change *t.StatusChange, notified []string) (*t.Case, error)
+}
+
+type rpc interface {
+	TraceContacts(ctx context.Context, req *chkT.TraceContacts) (*chkT.ContactTrace, error)
+	AlertUsers(ctx context.Context, ids []string) error
+}
+
+// Usecase for interacting with cases
+type Usecase struct {
+	Repository   repository
+	RPC          rpc
+	TraceDepth   int
+	MinRiskScore float64
+}
+
+// Get a single case
+func (u *Usecase) Get(ctx context.Context, id string) (*t.Case, error) {
+	cs, err := u.Repository.Get(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching a single case")
+	}
+	return cs, nil
+}
+
+// GetAll gets a page of cases
+func (u *Usecase) GetAll(ctx context.Context, req *t.GetCases) (*t.CasePage, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	page, err := req.Query(t.CaseSorts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cases, err := u.Repository.GetAll(ctx, req, page)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching cases")
+	}
+	resp := &t.CasePage{Items: cases, PageInfo: api.PageInfo{Limit: page.Limit}}
+	if len(cases) > page.Limit {
+		resp.Items = cases[:page.Limit]
+		last := resp.Items[page.Limit-1]
+		resp.NextCursor = page.NextCursor(last.SortValue(page.Field), last.ID)
+	}
+	return resp, nil
+}
+
+// Report a positive case, then trace and notify its contacts
+func (u *Usecase) Report(ctx context.Context, req *t.ReportCase) (*t.Case, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
+	now := time.Now()
+	if req.TestDate.After(now) {
+		return nil, errors.New("error test date is in the future")
+	}
+	if req.SymptomOnset != nil && req.SymptomOnset.After(now) {
+		return nil, errors.New("error symptom onset is in the future")
+	}
+	start, end := req.InfectiousWindow(now)
+
+	cs, err := u.Repository.Create(ctx, &t.Case{
+		UserID:           req.UserID,
+		ReportedBy:       req.ReportedBy,
+		TestDate:         req.TestDate,
+		SymptomOnset:     req.SymptomOnset,
+		InfectiousStart:  &start,
+		InfectiousEnd:    &end,
+		Status:           t.StatusReported,
+		NotifiedContacts: []string{},
+		StatusHistory: []*t.StatusChange{
+			{Status: t.StatusReported, ChangedBy: req.ReportedBy, ChangedAt: &now},
+		},
+		CreatedAt: &now,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating case")
+	}
+
+	return u.notify(ctx, cs, req.ReportedBy)
+}
+
+// Notify traces and notifies the contacts of a case that has not been
+// notified yet, such as one whose automatic notification failed
+func (u *Usecase) Notify(ctx context.Context, id, changedBy string) (*t.Case, error) {
+	cs, err := u.Repository.Get(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting case for notification")
+	}
+	if !cs.Status.CanTransition(t.StatusNotified) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "error case is %s and can not be notified", cs.Status)
+	}
+	return u.notify(ctx, cs, changedBy)
+}
+
+// UpdateStatus moves a case to a new status
+func (u *Usecase) UpdateStatus(ctx context.Context, req *t.UpdateCaseStatus) (*t.Case, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	if req.Status == t.StatusNotified {
+		return u.Notify(ctx, req.ID, req.ChangedBy)
+	}
+
+	cs, err := u.Repository.Get(ctx, req.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting case for status update")
+	}
+	if !cs.Status.CanTransition(req.Status) {
+		return nil, errors.Wrapf(ErrInvalidTransition, "error case can not move from %s to %s", cs.Status, req.Status)
+	}
+
+	now := time.Now()
+	resp, err := u.Repository.UpdateStatus(ctx, cs.ID, &t.StatusChange{
+		Status:    req.Status,
+		ChangedBy: req.ChangedBy,
+		ChangedAt: &now,
+		Note:      req.Note,
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error updating case status")
+	}
+	return resp, nil
+}
+
+func (u *Usecase) notify(ctx context.Context, cs *t.Case, changedBy string) (*t.Case, error) {
+	trace, err := u.RPC.TraceContacts(ctx, &chkT.TraceContacts{
+		UserID:       cs.UserID,
+		Start:        cs.InfectiousStart,
+		End:          cs.InfectiousEnd,
+		Depth:        u.TraceDepth,
+		MinRiskScore: u.MinRiskScore,
+	})
+	if err != nil {
+		return cs, errors.Wrap(err, "error tracing case contacts")
+	}
+	contacts := trace.UserIDs()
+	if len(contacts) > 0 {
+		if err = u.RPC.AlertUsers(ctx, contacts); err != nil {
+			return cs, errors.Wrap(err, "error alerting case contacts")
+		}
+	}
+
+	now := time.Now()
+	resp, err := u.Repository.UpdateStatus(ctx, cs.ID, &t.StatusChange{
+		Status:    t.StatusNotified,
+		ChangedBy: changedBy,
+		ChangedAt: &now,
+		Note:      fmt.Sprintf("%d contact(s) notified", len(contacts)),
+	}, contacts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error updating case status")
+	}
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package cases
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	repo "github.com/contact-tracker/apiService/cases/repository"
+	t "github.com/contact-tracker/apiService/cases/types"
+	chkT "github.com/contact-tracker/apiService/check-ins/types"
+
+	"github.com/pkg/errors"
+)
+
+// stubRPC - traces a single contact for every case and records who was alerted
+type stubRPC struct {
+	alerted []string
+}
+
+func (s *stubRPC) TraceContacts(_ context.Context, req *chkT.TraceContacts) (*chkT.ContactTrace, error) {
+	return &chkT.ContactTrace{
+		User:     &chkT.User{ID: req.UserID},
+		Contacts: []*chkT.ContactTrace{{User: &chkT.User{ID: "contact"}, Degree: 1, RiskScore: 1}},
+	}, nil
+}
+
+func (s *stubRPC) AlertUsers(_ context.Context, ids []string) error {
+	s.alerted = append(s.alerted, ids...)
+	return nil
+}
+
+func TestUpdateStatus(tt *testing.T) {
+	tests := []struct {
+		name    string
+		from    t.CaseStatus
+		to      t.CaseStatus
+		err     error
+		alerted int
+	}{
+		{"verify a report", t.StatusReported, t.StatusVerified, nil, 0},
+		{"notify a verified case", t.StatusVerified, t.StatusNotified, nil, 1},
+		{"renotify", t.StatusNotified, t.StatusNotified, ErrInvalidTransition, 0},
+		{"close a notified case", t.StatusNotified, t.StatusClosed, nil, 0},
+		{"reopen a closed case", t.StatusClosed, t.StatusVerified, ErrInvalidTransition, 0},
+		{"notify a closed case", t.StatusClosed, t.StatusNotified, ErrInvalidTransition, 0},
+		{"back to reported", t.StatusVerified, t.StatusReported, ErrInvalidTransition, 0},
+	}
+	for i, test := range tests {
+		ctx := context.Background()
+		repository := repo.NewMemoryCaseRepository(fmt.Sprintf("cases-test-%d", i))
+		rpc := &stubRPC{}
+		u := &Usecase{Repository: repository, RPC: rpc, TraceDepth: 1}
+		cs, err := repository.Create(ctx, &t.Case{UserID: "user-1", Status: test.from})
+		if err != nil {
+			tt.Fatalf("%s: error creating case: %v", test.name, err)
+		}
+
+		resp, err := u.UpdateStatus(ctx, &t.UpdateCaseStatus{ID: cs.ID, Status: test.to, ChangedBy: "official-1"})
+		if errors.Cause(err) != test.err {
+			tt.Errorf("%s: got %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if len(rpc.alerted) != test.alerted {
+			tt.Errorf("%s: alerted %v, want %d contact(s)", test.name, rpc.alerted, test.alerted)
+		}
+		stored, err := repository.Get(ctx, cs.ID)
+		if err != nil {
+			tt.Fatalf("%s: error getting case: %v", test.name, err)
+		}
+		want := test.to
+		if test.err != nil {
+			want = test.from
+		} else if resp.Status != want {
+			tt.Errorf("%s: responded with status %s, want %s", test.name, resp.Status, want)
+		}
+		if stored.Status != want {
+			tt.Errorf("%s: got status %s, want %s", test.name, stored.Status, want)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
 
 	chk "github.com/contact-tracker/apiService/check-ins"
 	t "github.com/contact-tracker/apiService/check-ins/types"
@@ -34,10 +38,25 @@ func (d *handler) Get() http.HandlerFunc {
 func (d *handler) GetHistory() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		placeID := chi.URLParam(r, "placeId")
-		now := time.Now()
-		start := now.Add(time.Hour * -24)
-		resp, err := d.Usecase.GetHistory(ctx, placeID, &start, &now)
+		req := &t.GetPlaceHistory{}
+		api.ParseHTTPParams(r, req)
+
+		req.PlaceID = chi.URLParam(r, "placeId")
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, req.PlaceID))
+		resp, err := d.Usecase.GetPlaceHistory(ctx, req)
+		api.CheckHTTPError(historyErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (d *handler) TraceContacts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.TraceContacts{}
+		api.ParseHTTPParams(r, req)
+
+		req.UserID = chi.URLParam(r, "id")
+		resp, err := d.Usecase.TraceContacts(ctx, req)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, resp)
 	}
@@ -50,7 +69,38 @@ func (d *handler) GetAll() http.HandlerFunc {
 		api.ParseHTTPParams(r, req)
 
 		resp, err := d.Usecase.GetAll(ctx, req)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.CheckHTTPError(api.PageErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func historyErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok || errors.Cause(err) == chk.ErrInvalidWindow {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func checkInErrorCode(err error) int {
+	switch errors.Cause(err) {
+	case chk.ErrAlreadyCheckedIn, chk.ErrNotCheckedIn:
+		return http.StatusConflict
+	case chk.ErrUnconfirmed:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func (d *handler) Toggle() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.CreateCheckIn{}
+		api.ParseHTTPParams(r, req)
+
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, req.PlaceID))
+		req.IdempotencyKey = r.Header.Get(t.IdempotencyKeyHeader)
+		resp, err := d.Usecase.Toggle(ctx, req)
+		api.CheckHTTPError(checkInErrorCode(err), err)
 		api.WriteJSON(w, http.StatusOK, resp)
 	}
 }
@@ -61,22 +111,84 @@ func (d *handler) CheckIn() http.HandlerFunc {
 		req := &t.CreateCheckIn{}
 		api.ParseHTTPParams(r, req)
 
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, req.PlaceID))
+		req.IdempotencyKey = r.Header.Get(t.IdempotencyKeyHeader)
 		resp, err := d.Usecase.CheckIn(ctx, req)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.CheckHTTPError(checkInErrorCode(err), err)
+		api.WriteJSON(w, http.StatusCreated, resp)
+	}
+}
+
+func (d *handler) CheckOut() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.CheckOut{}
+		api.ParseHTTPParams(r, req)
+
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, req.PlaceID))
+		req.IdempotencyKey = r.Header.Get(t.IdempotencyKeyHeader)
+		resp, err := d.Usecase.CheckOut(ctx, req)
+		api.CheckHTTPError(checkInErrorCode(err), err)
 		api.WriteJSON(w, http.StatusOK, resp)
 	}
 }
 
-func NewServer(port, mongoDBName, mongoHost, mongoCheckIn, mongoPwd, usersHost, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd string) (server *api.Server, service *chk.CheckInService, err error) {
+func (d *handler) Sync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.SyncCheckIns{}
+		api.ParseHTTPParams(r, req)
+		for _, event := range req.Events {
+			if event != nil {
+				api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, event.PlaceID))
+			}
+		}
+
+		resp, err := d.Usecase.Sync(ctx, req)
+		api.CheckHTTPError(http.StatusBadRequest, err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// Config - the check ins service's settings, plus how often the server
+// closes stale check ins and purges expired ones, never when empty or 0
+type Config struct {
+	chk.Config
+	SweepIntervalMinutes string
+	PurgeIntervalMinutes string
+}
+
+func NewServer(port string, cfg Config) (server *api.Server, service *chk.CheckInService, err error) {
 	fmt.Printf("Listening for check-ins on %s...\n", port)
 
-	svc, j, err := chk.Init(mongoDBName, mongoHost, mongoCheckIn, mongoPwd, usersHost, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd)
+	svc, j, err := chk.Init(cfg.Config)
 	if err != nil {
 		log.Panic(err)
 		return nil, nil, err
 	}
 	service = &svc
 
+	if cfg.SweepIntervalMinutes != "" {
+		interval, err := strconv.Atoi(cfg.SweepIntervalMinutes)
+		if err != nil {
+			log.Panic(err)
+			return nil, nil, err
+		}
+		if interval > 0 {
+			go chk.RunSweeper(context.Background(), svc, time.Duration(interval)*time.Minute)
+		}
+	}
+	if cfg.PurgeIntervalMinutes != "" {
+		interval, err := strconv.Atoi(cfg.PurgeIntervalMinutes)
+		if err != nil {
+			log.Panic(err)
+			return nil, nil, err
+		}
+		if interval > 0 {
+			go chk.RunPurger(context.Background(), svc, time.Duration(interval)*time.Minute)
+		}
+	}
+
 	h := &handler{
 		Usecase: svc,
 		jwt:     j,
@@ -84,10 +196,14 @@ func NewServer(port, mongoDBName, mongoHost, mongoCheckIn, mongoPwd, usersHost,
 
 	server = api.NewServer(port)
 	r := server.Router
-	r.Get("/check-ins/{id}", j.AuthorizeHandler(h.Get()))
-	r.Get("/check-ins/history/{placeId}", j.AuthorizeHandler(h.GetHistory()))
-	r.Get("/check-ins", j.AuthorizeHandler(h.GetAll()))
-	r.Post("/check-ins", j.AuthorizeHandler(h.CheckIn()))
+	r.Get("/check-ins/{id}", j.AuthorizeHandler(chk.GetPolicy, h.Get()))
+	r.Get("/check-ins/history/{placeId}", j.AuthorizeHandler(chk.GetHistoryPolicy, h.GetHistory()))
+	r.Get("/check-ins/trace/{id}", j.AuthorizeHandler(chk.TracePolicy, h.TraceContacts()))
+	r.Get("/check-ins", j.AuthorizeHandler(chk.GetAllPolicy, h.GetAll()))
+	r.Post("/check-ins", j.AuthorizeHandler(chk.CheckInPolicy, h.Toggle()))
+	r.Post("/check-ins/in", j.AuthorizeHandler(chk.CheckInPolicy, h.CheckIn()))
+	r.Post("/check-ins/out", j.AuthorizeHandler(chk.CheckInPolicy, h.CheckOut()))
+	r.Post("/check-ins/sync", j.AuthorizeHandler(chk.CheckInPolicy, h.Sync()))
 
 	return
 }
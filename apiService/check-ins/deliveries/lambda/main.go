@@ -3,17 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/gorilla/schema"
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
 
 	chk "github.com/contact-tracker/apiService/check-ins"
 	t "github.com/contact-tracker/apiService/check-ins/types"
+	apiHttp "github.com/contact-tracker/apiService/pkg/api/http"
 	api "github.com/contact-tracker/apiService/pkg/api/lambda"
 	"github.com/contact-tracker/apiService/pkg/auth"
 )
@@ -25,11 +28,8 @@ type handler struct {
 	jwt     *auth.JWTService
 }
 
-func isAuthorized(ctx context.Context) error {
-	if authorized, _ := auth.ClaimsFromContext(ctx); !authorized {
-		return errors.New("Unauthorized")
-	}
-	return nil
+func isAuthorized(ctx context.Context, req *api.Request, policy auth.Policy) error {
+	return policy.AuthorizeLambda(ctx, req)
 }
 
 func (h handler) router() func(context.Context, api.Request) (api.Response, error) {
@@ -42,30 +42,50 @@ func (h handler) router() func(context.Context, api.Request) (api.Response, erro
 		// Add auth
 		var err error
 		if ctx, err = h.jwt.IncludeLambdaAuth(ctx, &req); err != nil {
-			return api.Fail(err, http.StatusInternalServerError)
+			return api.Fail(err, http.StatusUnauthorized)
 		}
 
 		// Routes
 		if api.MatchesRoute("/check-ins/{id}", "GET", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, chk.GetPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.Get(ctx, &req)
 		} else if api.MatchesRoute("/check-ins", "GET", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, chk.GetAllPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.GetAll(ctx, &req)
-		} else if api.MatchesRoute("/check-ins/history/{id}", "GET", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+		} else if api.MatchesRoute("/check-ins/history/{placeId}", "GET", &req) {
+			if err := isAuthorized(ctx, &req, chk.GetHistoryPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.GetHistory(ctx, &req)
+		} else if api.MatchesRoute("/check-ins/trace/{id}", "GET", &req) {
+			if err := isAuthorized(ctx, &req, chk.TracePolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.TraceContacts(ctx, &req)
 		} else if api.MatchesRoute("/check-ins", "POST", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, chk.CheckInPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Toggle(ctx, &req)
+		} else if api.MatchesRoute("/check-ins/in", "POST", &req) {
+			if err := isAuthorized(ctx, &req, chk.CheckInPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.CheckIn(ctx, &req)
+		} else if api.MatchesRoute("/check-ins/out", "POST", &req) {
+			if err := isAuthorized(ctx, &req, chk.CheckInPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.CheckOut(ctx, &req)
+		} else if api.MatchesRoute("/check-ins/sync", "POST", &req) {
+			if err := isAuthorized(ctx, &req, chk.CheckInPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Sync(ctx, &req)
 		} else {
 			return api.Fail(errors.New("not found"), http.StatusNotFound)
 		}
@@ -87,34 +107,91 @@ func (h *handler) Get(ctx context.Context, req *api.Request) (resp api.Response,
 
 // GetHistory get history of checkins and contacts
 func (h *handler) GetHistory(ctx context.Context, req *api.Request) (resp api.Response, err error) {
-	var id string
-	if id, err = api.GetPathParam("id", req); err != nil {
+	var query t.GetPlaceHistory
+	if err = api.ParseQuery(req, &query); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
+	}
+	if query.PlaceID, err = api.GetPathParam("placeId", req); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
+	if err = auth.AuthorizePlace(ctx, query.PlaceID); err != nil {
+		return api.Fail(err, http.StatusForbidden)
+	}
 	var history []*t.CheckInHistory
-	if history, err = h.usecase.GetHistory(ctx, id); err != nil {
-		return api.Fail(err, http.StatusInternalServerError)
+	if history, err = h.usecase.GetPlaceHistory(ctx, &query); err != nil {
+		return api.Fail(err, historyErrorCode(err))
 	}
 	return api.Success(history, http.StatusOK)
 }
 
-// GetAll check ins
-func (h *handler) GetAll(ctx context.Context, req *api.Request) (resp api.Response, err error) {
-	var query t.GetCheckIns
+// TraceContacts traces a user's contacts to the requested depth
+func (h *handler) TraceContacts(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var query t.TraceContacts
 	parsed := make(map[string][]string)
 	for k, v := range req.QueryStringParameters {
 		parsed[k] = []string{v}
 	}
-	if err := decoder.Decode(query, parsed); err != nil {
-		api.Fail(err, http.StatusInternalServerError)
+	if err := decoder.Decode(&query, parsed); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
 	}
-	var checkIns []*t.CheckIn
-	if checkIns, err = h.usecase.GetAll(ctx, &query); err != nil {
+	if query.UserID, err = api.GetPathParam("id", req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	var trace *t.ContactTrace
+	if trace, err = h.usecase.TraceContacts(ctx, &query); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
+	return api.Success(trace, http.StatusOK)
+}
+
+// GetAll check ins
+func (h *handler) GetAll(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var query t.GetCheckIns
+	if err = api.ParseQuery(req, &query); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
+	}
+	var checkIns *t.CheckInPage
+	if checkIns, err = h.usecase.GetAll(ctx, &query); err != nil {
+		return api.Fail(err, apiHttp.PageErrorCode(err))
+	}
 	return api.Success(checkIns, http.StatusOK)
 }
 
+func historyErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok || errors.Cause(err) == chk.ErrInvalidWindow {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func checkInErrorCode(err error) int {
+	switch errors.Cause(err) {
+	case chk.ErrAlreadyCheckedIn, chk.ErrNotCheckedIn:
+		return http.StatusConflict
+	case chk.ErrUnconfirmed:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// Toggle check in or out of user
+func (h *handler) Toggle(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	body := []byte(req.Body)
+	var query t.CreateCheckIn
+	if err := json.Unmarshal(body, &query); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = auth.AuthorizePlace(ctx, query.PlaceID); err != nil {
+		return api.Fail(err, http.StatusForbidden)
+	}
+	query.IdempotencyKey = api.GetHeader(t.IdempotencyKeyHeader, req)
+	var checkIn *t.CheckIn
+	if checkIn, err = h.usecase.Toggle(ctx, &query); err != nil {
+		return api.Fail(err, checkInErrorCode(err))
+	}
+	return api.Success(checkIn, http.StatusOK)
+}
+
 // Check in user
 func (h *handler) CheckIn(ctx context.Context, req *api.Request) (resp api.Response, err error) {
 	body := []byte(req.Body)
@@ -122,16 +199,83 @@ func (h *handler) CheckIn(ctx context.Context, req *api.Request) (resp api.Respo
 	if err := json.Unmarshal(body, &query); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
+	if err = auth.AuthorizePlace(ctx, query.PlaceID); err != nil {
+		return api.Fail(err, http.StatusForbidden)
+	}
+	query.IdempotencyKey = api.GetHeader(t.IdempotencyKeyHeader, req)
 	var checkIn *t.CheckIn
 	if checkIn, err = h.usecase.CheckIn(ctx, &query); err != nil {
+		return api.Fail(err, checkInErrorCode(err))
+	}
+	return api.Success(checkIn, http.StatusCreated)
+}
+
+// Check out user
+func (h *handler) CheckOut(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	body := []byte(req.Body)
+	var query t.CheckOut
+	if err := json.Unmarshal(body, &query); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
+	if err = auth.AuthorizePlace(ctx, query.PlaceID); err != nil {
+		return api.Fail(err, http.StatusForbidden)
+	}
+	query.IdempotencyKey = api.GetHeader(t.IdempotencyKeyHeader, req)
+	var checkIn *t.CheckIn
+	if checkIn, err = h.usecase.CheckOut(ctx, &query); err != nil {
+		return api.Fail(err, checkInErrorCode(err))
+	}
 	return api.Success(checkIn, http.StatusOK)
 }
 
+// Sync a device's offline check ins
+func (h *handler) Sync(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	body := []byte(req.Body)
+	var query t.SyncCheckIns
+	if err := json.Unmarshal(body, &query); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	for _, event := range query.Events {
+		if event == nil {
+			continue
+		}
+		if err = auth.AuthorizePlace(ctx, event.PlaceID); err != nil {
+			return api.Fail(err, http.StatusForbidden)
+		}
+	}
+	var result *t.SyncCheckInsResult
+	if result, err = h.usecase.Sync(ctx, &query); err != nil {
+		return api.Fail(err, http.StatusBadRequest)
+	}
+	return api.Success(result, http.StatusOK)
+}
+
 func main() {
-	fmt.Println("Starting chekc ins lambda main...")
-	usecase, j, err := chk.Init()
+	fmt.Println("Starting check ins lambda main...")
+	usecase, j, err := chk.Init(chk.Config{
+		MongoDBName:              os.Getenv("MONGO_DB_NAME"),
+		MongoURI:                 os.Getenv("MONGO_URI"),
+		MongoUser:                os.Getenv("MONGO_USER"),
+		MongoPwd:                 os.Getenv("MONGO_PWD"),
+		UsersHost:                os.Getenv("USERS_HOST"),
+		PlacesHost:               os.Getenv("PLACES_HOST"),
+		CasesHost:                os.Getenv("CASES_HOST"),
+		JWTKeyPath:               os.Getenv("JWT_KEY_PATH"),
+		JWTSecretPath:            os.Getenv("JWT_SECRET_PATH"),
+		RiskMinOverlap:           os.Getenv("RISK_MIN_OVERLAP_MINUTES"),
+		RiskFullOverlap:          os.Getenv("RISK_FULL_OVERLAP_MINUTES"),
+		RiskTentativeFactor:      os.Getenv("RISK_TENTATIVE_FACTOR"),
+		RiskUnknownPlaceFactor:   os.Getenv("RISK_UNKNOWN_PLACE_FACTOR"),
+		TentativeMinutes:         os.Getenv("TENTATIVE_CHECKOUT_MINUTES"),
+		MaxDwellMinutes:          os.Getenv("MAX_DWELL_MINUTES"),
+		IdempotencyWindowMinutes: os.Getenv("IDEMPOTENCY_WINDOW_MINUTES"),
+		SyncMaxClockSkewMinutes:  os.Getenv("SYNC_MAX_CLOCK_SKEW_MINUTES"),
+		HistoryWindowMinutes:     os.Getenv("HISTORY_WINDOW_MINUTES"),
+		MaxHistoryWindowMinutes:  os.Getenv("MAX_HISTORY_WINDOW_MINUTES"),
+		RetentionDays:            os.Getenv("RETENTION_DAYS"),
+		Storage:                  os.Getenv("STORAGE"),
+		PostgresURL:              os.Getenv("POSTGRES_URL"),
+	})
 	if err != nil {
 		log.Panic(err)
 	}
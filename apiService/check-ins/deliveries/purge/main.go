@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	chk "github.com/contact-tracker/apiService/check-ins"
+)
+
+type handler struct {
+	usecase chk.CheckInService
+	dryRun  bool
+}
+
+// Purge runs on a schedule and deletes check ins past their retention
+func (h *handler) Purge(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*5)
+	defer cancel()
+
+	report, err := h.usecase.Purge(ctx, h.dryRun)
+	if err != nil {
+		return err
+	}
+	if report.DryRun {
+		log.Printf("Would purge %d expired check in(s), keeping %d in open cases\n", report.Purged, report.Exempt)
+		return nil
+	}
+	log.Printf("Purged %d expired check in(s), kept %d in open cases\n", report.Purged, report.Exempt)
+	return nil
+}
+
+func main() {
+	fmt.Println("Starting check ins purge main...")
+	usecase, _, err := chk.Init(chk.Config{
+		MongoDBName:              os.Getenv("MONGO_DB_NAME"),
+		MongoURI:                 os.Getenv("MONGO_URI"),
+		MongoUser:                os.Getenv("MONGO_USER"),
+		MongoPwd:                 os.Getenv("MONGO_PWD"),
+		UsersHost:                os.Getenv("USERS_HOST"),
+		PlacesHost:               os.Getenv("PLACES_HOST"),
+		CasesHost:                os.Getenv("CASES_HOST"),
+		JWTKeyPath:               os.Getenv("JWT_KEY_PATH"),
+		JWTSecretPath:            os.Getenv("JWT_SECRET_PATH"),
+		RiskMinOverlap:           os.Getenv("RISK_MIN_OVERLAP_MINUTES"),
+		RiskFullOverlap:          os.Getenv("RISK_FULL_OVERLAP_MINUTES"),
+		RiskTentativeFactor:      os.Getenv("RISK_TENTATIVE_FACTOR"),
+		RiskUnknownPlaceFactor:   os.Getenv("RISK_UNKNOWN_PLACE_FACTOR"),
+		TentativeMinutes:         os.Getenv("TENTATIVE_CHECKOUT_MINUTES"),
+		MaxDwellMinutes:          os.Getenv("MAX_DWELL_MINUTES"),
+		IdempotencyWindowMinutes: os.Getenv("IDEMPOTENCY_WINDOW_MINUTES"),
+		SyncMaxClockSkewMinutes:  os.Getenv("SYNC_MAX_CLOCK_SKEW_MINUTES"),
+		HistoryWindowMinutes:     os.Getenv("HISTORY_WINDOW_MINUTES"),
+		MaxHistoryWindowMinutes:  os.Getenv("MAX_HISTORY_WINDOW_MINUTES"),
+		RetentionDays:            os.Getenv("RETENTION_DAYS"),
+		Storage:                  os.Getenv("STORAGE"),
+		PostgresURL:              os.Getenv("POSTGRES_URL"),
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	dryRun, _ := strconv.ParseBool(os.Getenv("PURGE_DRY_RUN"))
+	h := &handler{usecase, dryRun}
+	lambda.Start(h.Purge)
+}
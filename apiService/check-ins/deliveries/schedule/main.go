@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	chk "github.com/contact-tracker/apiService/check-ins"
+)
+
+type handler struct {
+	usecase chk.CheckInService
+}
+
+// CloseStale runs on a schedule and automatically checks out stale check ins
+func (h *handler) CloseStale(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	closed, err := h.usecase.CloseStale(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Automatically checked out %d stale check in(s)\n", len(closed))
+	return nil
+}
+
+func main() {
+	fmt.Println("Starting check ins schedule main...")
+	usecase, _, err := chk.Init(chk.Config{
+		MongoDBName:              os.Getenv("MONGO_DB_NAME"),
+		MongoURI:                 os.Getenv("MONGO_URI"),
+		MongoUser:                os.Getenv("MONGO_USER"),
+		MongoPwd:                 os.Getenv("MONGO_PWD"),
+		UsersHost:                os.Getenv("USERS_HOST"),
+		PlacesHost:               os.Getenv("PLACES_HOST"),
+		CasesHost:                os.Getenv("CASES_HOST"),
+		JWTKeyPath:               os.Getenv("JWT_KEY_PATH"),
+		JWTSecretPath:            os.Getenv("JWT_SECRET_PATH"),
+		RiskMinOverlap:           os.Getenv("RISK_MIN_OVERLAP_MINUTES"),
+		RiskFullOverlap:          os.Getenv("RISK_FULL_OVERLAP_MINUTES"),
+		RiskTentativeFactor:      os.Getenv("RISK_TENTATIVE_FACTOR"),
+		RiskUnknownPlaceFactor:   os.Getenv("RISK_UNKNOWN_PLACE_FACTOR"),
+		TentativeMinutes:         os.Getenv("TENTATIVE_CHECKOUT_MINUTES"),
+		MaxDwellMinutes:          os.Getenv("MAX_DWELL_MINUTES"),
+		IdempotencyWindowMinutes: os.Getenv("IDEMPOTENCY_WINDOW_MINUTES"),
+		SyncMaxClockSkewMinutes:  os.Getenv("SYNC_MAX_CLOCK_SKEW_MINUTES"),
+		HistoryWindowMinutes:     os.Getenv("HISTORY_WINDOW_MINUTES"),
+		MaxHistoryWindowMinutes:  os.Getenv("MAX_HISTORY_WINDOW_MINUTES"),
+		RetentionDays:            os.Getenv("RETENTION_DAYS"),
+		Storage:                  os.Getenv("STORAGE"),
+		PostgresURL:              os.Getenv("POSTGRES_URL"),
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	h := &handler{usecase}
+	lambda.Start(h.CloseStale)
+}
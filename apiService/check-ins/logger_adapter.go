@@ -2,7 +2,6 @@ package checkins
 
 import (
 	"context"
-	"time"
 
 	"go.uber.org/zap"
 
@@ -33,16 +32,36 @@ func (a *LoggerAdapter) Get(ctx context.Context, id string) (*t.CheckIn, error)
 }
 
 // GetHistory gets checkin history
-func (a *LoggerAdapter) GetHistory(ctx context.Context, placeID string, start, end *time.Time) ([]*t.CheckInHistory, error) {
+func (a *LoggerAdapter) GetHistory(ctx context.Context, req *t.GetHistory) ([]*t.CheckInHistory, error) {
 	defer a.Logger.Sync()
 	a.Logger.Info("getting check in history")
-	history, err := a.Usecase.GetHistory(ctx, placeID, start, end)
+	history, err := a.Usecase.GetHistory(ctx, req)
 	a.logErr(err)
 	return history, err
 }
 
-// GetAll gets all checkIns
-func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetCheckIns) ([]*t.CheckIn, error) {
+// GetPlaceHistory gets a place's checkin history over a window
+func (a *LoggerAdapter) GetPlaceHistory(ctx context.Context, req *t.GetPlaceHistory) ([]*t.CheckInHistory, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("placeId", req.PlaceID))
+	a.Logger.Info("getting place check in history")
+	history, err := a.Usecase.GetPlaceHistory(ctx, req)
+	a.logErr(err)
+	return history, err
+}
+
+// TraceContacts traces contacts of an index user
+func (a *LoggerAdapter) TraceContacts(ctx context.Context, req *t.TraceContacts) (*t.ContactTrace, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("userId", req.UserID), zap.Int("depth", req.Depth))
+	a.Logger.Info("tracing contacts")
+	trace, err := a.Usecase.TraceContacts(ctx, req)
+	a.logErr(err)
+	return trace, err
+}
+
+// GetAll gets a page of checkIns
+func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetCheckIns) (*t.CheckInPage, error) {
 	defer a.Logger.Sync()
 	a.Logger.Info("getting all checkIns")
 	checkIns, err := a.Usecase.GetAll(ctx, req)
@@ -50,12 +69,60 @@ func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetCheckIns) ([]*t.Ch
 	return checkIns, err
 }
 
+// Toggle a user's check in
+func (a *LoggerAdapter) Toggle(ctx context.Context, req *t.CreateCheckIn) (*t.CheckIn, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("userId", req.UserID), zap.String("placeId", req.PlaceID))
+	a.Logger.Info("toggle checkin a single user")
+	resp, err := a.Usecase.Toggle(ctx, req)
+	a.logErr(err)
+	return resp, err
+}
+
 // CheckIn a user
 func (a *LoggerAdapter) CheckIn(ctx context.Context, req *t.CreateCheckIn) (*t.CheckIn, error) {
 	defer a.Logger.Sync()
-	a.Logger.With(zap.String("userId", req.UserID))
+	a.Logger.With(zap.String("userId", req.UserID), zap.String("placeId", req.PlaceID))
 	a.Logger.Info("checkin a single user")
 	resp, err := a.Usecase.CheckIn(ctx, req)
 	a.logErr(err)
 	return resp, err
 }
+
+// CheckOut a user
+func (a *LoggerAdapter) CheckOut(ctx context.Context, req *t.CheckOut) (*t.CheckIn, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("userId", req.UserID), zap.String("placeId", req.PlaceID))
+	a.Logger.Info("checkout a single user")
+	resp, err := a.Usecase.CheckOut(ctx, req)
+	a.logErr(err)
+	return resp, err
+}
+
+// CloseStale check ins
+func (a *LoggerAdapter) CloseStale(ctx context.Context) ([]*t.CheckIn, error) {
+	defer a.Logger.Sync()
+	a.Logger.Info("closing stale checkIns")
+	closed, err := a.Usecase.CloseStale(ctx)
+	a.logErr(err)
+	return closed, err
+}
+
+// Purge expired check ins
+func (a *LoggerAdapter) Purge(ctx context.Context, dryRun bool) (*t.PurgeReport, error) {
+	defer a.Logger.Sync()
+	a.Logger.Info("purging expired checkIns", zap.Bool("dryRun", dryRun))
+	report, err := a.Usecase.Purge(ctx, dryRun)
+	a.logErr(err)
+	return report, err
+}
+
+// Sync a device's offline check ins
+func (a *LoggerAdapter) Sync(ctx context.Context, req *t.SyncCheckIns) (*t.SyncCheckInsResult, error) {
+	defer a.Logger.Sync()
+	a.Logger.With(zap.String("deviceId", req.DeviceID), zap.Int("events", len(req.Events)))
+	a.Logger.Info("syncing offline checkIns")
+	resp, err := a.Usecase.Sync(ctx, req)
+	a.logErr(err)
+	return resp, err
+}
@@ -0,0 +1,20 @@
+package checkins
+
+import (
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+// Route policies, shared by the http and lambda deliveries
+var (
+	// GetPolicy - only health officials may read any check in
+	GetPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem)
+	// GetHistoryPolicy - places may only read their own history
+	GetHistoryPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).Own("placeId", auth.RolePlaceStaff, auth.RolePlaceAdmin)
+	// TracePolicy - only health officials may trace contacts
+	TracePolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).WithScope(auth.ScopeTraceContacts)
+	// GetAllPolicy - customers may only list their own check ins
+	GetAllPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).Own("userId", auth.RoleCustomer)
+	// CheckInPolicy - places check customers in and out, limited to their
+	// own place by auth.AuthorizePlace
+	CheckInPolicy = auth.Allow(auth.RolePlaceStaff, auth.RolePlaceAdmin, auth.RoleSystem)
+)
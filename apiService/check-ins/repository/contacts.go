@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"sort"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+)
+
+// JoinContacts sets each history's contacts to the check ins by other
+// users at the same place overlapping it, or at any place for histories
+// without one. Histories and check ins must have their out set, as open
+// ones are when given their tentative check out. Both are swept once in
+// order of check in, so the join takes time in proportion to their number
+// and the contacts found rather than their product.
+func JoinContacts(histories []*t.CheckInHistory, checkIns []*t.CheckIn) {
+	checkIns = sortedCheckIns(checkIns)
+
+	atPlace := map[string][]*t.CheckIn{}
+	for _, check := range checkIns {
+		if check.Place != nil {
+			atPlace[check.Place.ID] = append(atPlace[check.Place.ID], check)
+		}
+	}
+	byPlace := map[string][]*t.CheckInHistory{}
+	anyPlace := []*t.CheckInHistory{}
+	for _, history := range histories {
+		history.Contacts = []*t.Contact{}
+		if history.In == nil || history.Out == nil {
+			continue
+		}
+		if history.Place == nil {
+			anyPlace = append(anyPlace, history)
+		} else {
+			byPlace[history.Place.ID] = append(byPlace[history.Place.ID], history)
+		}
+	}
+
+	for placeID, placeHistories := range byPlace {
+		sweepContacts(placeHistories, atPlace[placeID])
+	}
+	sweepContacts(anyPlace, checkIns)
+}
+
+// sweepContacts joins histories to the check ins, sorted by check in, which
+// overlap them. Check ins that started by the time a history starts are
+// kept active until they end, and later ones are scanned up to the end of
+// the history, so each check in is only passed over once.
+func sweepContacts(histories []*t.CheckInHistory, checkIns []*t.CheckIn) {
+	sort.SliceStable(histories, func(i, j int) bool {
+		return histories[i].In.Before(*histories[j].In)
+	})
+
+	active := []*t.CheckIn{}
+	next := 0
+	for _, history := range histories {
+		for next < len(checkIns) && !checkIns[next].In.After(*history.In) {
+			active = append(active, checkIns[next])
+			next++
+		}
+		stillActive := active[:0]
+		for _, check := range active {
+			if !check.Out.Before(*history.In) {
+				stillActive = append(stillActive, check)
+				addContact(history, check)
+			}
+		}
+		active = stillActive
+		for _, check := range checkIns[next:] {
+			if check.In.After(*history.Out) {
+				break
+			}
+			addContact(history, check)
+		}
+	}
+}
+
+// addContact adds check as a contact of history, unless it is the same
+// check in or user
+func addContact(history *t.CheckInHistory, check *t.CheckIn) {
+	if check.ID == history.ID {
+		return
+	}
+	if check.User != nil && history.User != nil && check.User.ID == history.User.ID {
+		return
+	}
+	history.Contacts = append(history.Contacts, &t.Contact{CheckIn: *check})
+}
+
+// sortedCheckIns returns the check ins with both a check in and out,
+// sorted by check in then id
+func sortedCheckIns(checkIns []*t.CheckIn) []*t.CheckIn {
+	sorted := make([]*t.CheckIn, 0, len(checkIns))
+	for _, check := range checkIns {
+		if check.In != nil && check.Out != nil {
+			sorted = append(sorted, check)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.In.Equal(*b.In) {
+			return a.ID < b.ID
+		}
+		return a.In.Before(*b.In)
+	})
+	return sorted
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+)
+
+const (
+	benchPlaces      = 500
+	benchUsers       = 20000
+	benchOpenPercent = 10
+	benchTentative   = 5 * time.Minute
+)
+
+// generated - memory repositories of generated check ins by size, kept so
+// repeated benchmark runs don't add to them
+var generated = map[int]*MemoryCheckInRepository{}
+
+// generate returns a memory repository of size check ins spread over a day,
+// lasting from five minutes to two hours, some of them still open
+func generate(tb testing.TB, size int) *MemoryCheckInRepository {
+	if r, ok := generated[size]; ok {
+		return r
+	}
+	r := NewMemoryCheckInRepository(fmt.Sprintf("contacts-test-%d", size), benchTentative)
+	rnd := rand.New(rand.NewSource(int64(size)))
+	day := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < size; i++ {
+		in := day.Add(time.Duration(rnd.Int63n(int64(24 * time.Hour))))
+		check := &t.CheckIn{
+			In:    &in,
+			User:  &t.User{ID: fmt.Sprintf("user-%d", rnd.Intn(benchUsers))},
+			Place: &t.Place{ID: fmt.Sprintf("place-%d", rnd.Intn(benchPlaces))},
+		}
+		if rnd.Intn(100) >= benchOpenPercent {
+			out := in.Add(5*time.Minute + time.Duration(rnd.Int63n(int64(115*time.Minute))))
+			check.Out = &out
+		}
+		if _, err := r.Create(context.Background(), check); err != nil {
+			tb.Fatalf("Error generating check ins: %v", err)
+		}
+	}
+	generated[size] = r
+	return r
+}
+
+func countContacts(histories []*t.CheckInHistory) (count int) {
+	for _, history := range histories {
+		count += len(history.Contacts)
+	}
+	return
+}
+
+// everyPair counts contacts by comparing each history with every other,
+// as the history was computed before contacts were joined in one pass
+func everyPair(histories []*t.CheckInHistory) (count int) {
+	for _, history := range histories {
+		for _, other := range histories {
+			if other.User.ID == history.User.ID || other.Place.ID != history.Place.ID {
+				continue
+			}
+			if !other.In.After(*history.Out) && !other.Out.Before(*history.In) {
+				count++
+			}
+		}
+	}
+	return
+}
+
+func TestJoinContacts(tt *testing.T) {
+	for _, size := range []int{1000, 5000} {
+		histories, err := generate(tt, size).GetHistory(context.Background(), &t.GetHistory{})
+		if err != nil {
+			tt.Fatalf("Error getting history: %v", err)
+		}
+		contacts, expected := countContacts(histories), everyPair(histories)
+		if contacts != expected {
+			tt.Errorf("Joining %d check ins found %d contacts, comparing every pair found %d", size, contacts, expected)
+		}
+	}
+}
+
+// BenchmarkJoinContacts times computing a day's check in history with
+// contacts as the number of check ins grows. Check ins are kept in the
+// memory repository, so the timings are of the contact join shared with
+// mongo rather than of the database.
+func BenchmarkJoinContacts(b *testing.B) {
+	for _, size := range []int{1000, 10000, 50000} {
+		r := generate(b, size)
+		b.Run(fmt.Sprintf("checkIns=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := r.GetHistory(context.Background(), &t.GetHistory{}); err != nil {
+					b.Fatalf("Error getting history: %v", err)
+				}
+			}
+		})
+	}
+}
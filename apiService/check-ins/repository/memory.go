@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/memory"
+)
+
+// MemoryCheckInRepository - keeps check ins in memory, for running without mongo
+type MemoryCheckInRepository struct {
+	dbname    string
+	tentative time.Duration
+}
+
+// NewMemoryCheckInRepository - tentative is how long a check in without a
+// check out is assumed to last when computing contacts
+func NewMemoryCheckInRepository(dbname string, tentative time.Duration) *MemoryCheckInRepository {
+	return &MemoryCheckInRepository{dbname, tentative}
+}
+
+func (r *MemoryCheckInRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryCheckInRepository) Get(_ context.Context, id string) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
+	c.RLock()
+	defer c.RUnlock()
+
+	doc, ok := c.Get(id)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	return copyCheckIn(doc.(*t.CheckIn)), nil
+}
+
+// GetHistory matches the mongo pipeline: open check ins are assumed to
+// last the tentative duration, and contacts are other users' check ins at
+// the same place overlapping each check in
+func (r *MemoryCheckInRepository) GetHistory(_ context.Context, req *t.GetHistory) ([]*t.CheckInHistory, error) {
+	checks := r.find(func(check *t.CheckIn) bool { return true })
+	for _, check := range checks {
+		r.withTentativeOut(check)
+	}
+
+	resp := []*t.CheckInHistory{}
+	for _, check := range checks {
+		if req.UserID != nil && len(*req.UserID) != 0 && (check.User == nil || check.User.ID != *req.UserID) {
+			continue
+		}
+		if req.PlaceID != nil && len(*req.PlaceID) != 0 && (check.Place == nil || check.Place.ID != *req.PlaceID) {
+			continue
+		}
+		if req.Start != nil && req.End != nil && !overlaps(check, *req.Start, *req.End) {
+			continue
+		}
+		resp = append(resp, &t.CheckInHistory{
+			ID:                check.ID,
+			In:                check.In,
+			Out:               check.Out,
+			User:              check.User,
+			Place:             check.Place,
+			TentativeCheckout: check.TentativeCheckout,
+			AutoCheckout:      check.AutoCheckout,
+		})
+	}
+	JoinContacts(resp, checks)
+	return resp, nil
+}
+
+func (r *MemoryCheckInRepository) GetAll(_ context.Context, req *t.GetCheckIns, page *api.PageQuery) ([]*t.CheckIn, error) {
+	checks := r.find(func(check *t.CheckIn) bool {
+		if req.UserID != nil && (check.User == nil || check.User.ID != *req.UserID) {
+			return false
+		}
+		if req.PlaceID != nil && (check.Place == nil || check.Place.ID != *req.PlaceID) {
+			return false
+		}
+		return req.Matches(check)
+	})
+	after, afterID := page.Start()
+
+	resp := []*t.CheckIn{}
+	for _, i := range memory.Page(len(checks), func(i int) (string, string) {
+		return checks[i].SortValue(page.Field), checks[i].ID
+	}, page.Desc, after, afterID, page.Limit+1) {
+		resp = append(resp, checks[i])
+	}
+	return resp, nil
+}
+
+func (r *MemoryCheckInRepository) LastCheckIn(_ context.Context, userID string) (*t.CheckIn, error) {
+	checks := r.find(func(check *t.CheckIn) bool {
+		return check.User != nil && check.User.ID == userID && check.Out == nil
+	})
+	if len(checks) == 0 {
+		return nil, memory.ErrNotFound
+	}
+	return checks[0], nil
+}
+
+// Create a check in, or return the user's check in created with the same
+// idempotency key if there is one
+func (r *MemoryCheckInRepository) Create(_ context.Context, checkIn *t.CheckIn) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
+	c.Lock()
+	defer c.Unlock()
+
+	if checkIn.InKey != "" && checkIn.User != nil {
+		var prior *t.CheckIn
+		c.Each(func(doc interface{}) bool {
+			check := doc.(*t.CheckIn)
+			if check.InKey == checkIn.InKey && check.User != nil && check.User.ID == checkIn.User.ID && check.ID != checkIn.ID {
+				prior = check
+			}
+			return prior == nil
+		})
+		if prior != nil {
+			return copyCheckIn(prior), nil
+		}
+	}
+	if checkIn.ID == "" {
+		checkIn.ID = uuid.New().String()
+	}
+	stored := copyCheckIn(checkIn)
+	c.Put(stored.ID, stored)
+	return copyCheckIn(stored), nil
+}
+
+func (r *MemoryCheckInRepository) CheckOut(_ context.Context, id string, out time.Time, idempotencyKey string) (*t.CheckIn, error) {
+	return r.update(id, false, func(check *t.CheckIn) {
+		check.Out = &out
+		if idempotencyKey != "" {
+			check.OutKey = idempotencyKey
+		}
+	})
+}
+
+// GetOverlapping gets a user's check ins overlapping start to end, or
+// anytime after start if end is nil
+func (r *MemoryCheckInRepository) GetOverlapping(_ context.Context, userID string, start time.Time, end *time.Time) ([]*t.CheckIn, error) {
+	return r.find(func(check *t.CheckIn) bool {
+		if check.User == nil || check.User.ID != userID {
+			return false
+		}
+		if check.Out != nil && !check.Out.After(start) {
+			return false
+		}
+		return end == nil || (check.In != nil && check.In.Before(*end))
+	}), nil
+}
+
+// FindByIdempotencyKey finds the check in created or checked out by a
+// request with the given key since the given time
+func (r *MemoryCheckInRepository) FindByIdempotencyKey(_ context.Context, userID, key string, since time.Time) (*t.CheckIn, error) {
+	checks := r.find(func(check *t.CheckIn) bool {
+		if check.User == nil || check.User.ID != userID {
+			return false
+		}
+		return (check.InKey == key && check.In != nil && !check.In.Before(since)) ||
+			(check.OutKey == key && check.Out != nil && !check.Out.Before(since))
+	})
+	if len(checks) == 0 {
+		return nil, memory.ErrNotFound
+	}
+	return checks[0], nil
+}
+
+// GetStale gets open check ins past their close by time, or for check ins
+// without one, that checked in before legacyBefore
+func (r *MemoryCheckInRepository) GetStale(_ context.Context, now, legacyBefore time.Time) ([]*t.CheckIn, error) {
+	return r.find(func(check *t.CheckIn) bool {
+		if check.Out != nil {
+			return false
+		}
+		if check.CloseBy != nil {
+			return !check.CloseBy.After(now)
+		}
+		return check.In != nil && !check.In.After(legacyBefore)
+	}), nil
+}
+
+func (r *MemoryCheckInRepository) AutoCheckOut(_ context.Context, id string, out time.Time) (*t.CheckIn, error) {
+	return r.update(id, true, func(check *t.CheckIn) {
+		check.Out = &out
+		check.AutoCheckout = true
+	})
+}
+
+// GetExpired gets up to limit check ins, by id after afterID, past their
+// place's purge after time or that checked in before before
+func (r *MemoryCheckInRepository) GetExpired(_ context.Context, now, before time.Time, afterID string, limit int) ([]*t.CheckIn, error) {
+	checks := r.find(func(check *t.CheckIn) bool {
+		if check.PurgeAfter != nil && !check.PurgeAfter.After(now) {
+			return true
+		}
+		return check.In != nil && check.In.Before(before)
+	})
+
+	resp := []*t.CheckIn{}
+	for _, i := range memory.Page(len(checks), func(i int) (string, string) {
+		return "", checks[i].ID
+	}, false, "", afterID, limit) {
+		resp = append(resp, checks[i])
+	}
+	return resp, nil
+}
+
+// DeleteAll deletes the check ins with ids, returning how many were deleted
+func (r *MemoryCheckInRepository) DeleteAll(_ context.Context, ids []string) (int, error) {
+	c := r.C(ColCheckIns)
+	c.Lock()
+	defer c.Unlock()
+
+	deleted := 0
+	for _, id := range ids {
+		if c.Delete(id) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryCheckInRepository) Delete(_ context.Context, id string) error {
+	c := r.C(ColCheckIns)
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.Delete(id) {
+		return memory.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryCheckInRepository) find(match func(check *t.CheckIn) bool) []*t.CheckIn {
+	c := r.C(ColCheckIns)
+	c.RLock()
+	defer c.RUnlock()
+
+	resp := []*t.CheckIn{}
+	c.Each(func(doc interface{}) bool {
+		if check := doc.(*t.CheckIn); match(check) {
+			resp = append(resp, copyCheckIn(check))
+		}
+		return true
+	})
+	return resp
+}
+
+// update applies change to a check in, which when onlyOpen is set must
+// not be checked out yet
+func (r *MemoryCheckInRepository) update(id string, onlyOpen bool, change func(check *t.CheckIn)) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
+	c.Lock()
+	defer c.Unlock()
+
+	doc, ok := c.Get(id)
+	if !ok || (onlyOpen && doc.(*t.CheckIn).Out != nil) {
+		return nil, memory.ErrNotFound
+	}
+	check := copyCheckIn(doc.(*t.CheckIn))
+	change(check)
+	c.Put(check.ID, check)
+	return copyCheckIn(check), nil
+}
+
+// withTentativeOut sets an open check in's out to in plus the tentative duration
+func (r *MemoryCheckInRepository) withTentativeOut(check *t.CheckIn) {
+	check.TentativeCheckout = check.Out == nil
+	if check.Out == nil && check.In != nil {
+		out := check.In.Add(r.tentative)
+		check.Out = &out
+	}
+}
+
+// overlaps returns true if a check in, with its out set, overlaps start to end
+func overlaps(check *t.CheckIn, start, end time.Time) bool {
+	return check.In != nil && check.Out != nil && !check.In.After(end) && !check.Out.Before(start)
+}
+
+func copyCheckIn(check *t.CheckIn) *t.CheckIn {
+	cp := *check
+	if check.User != nil {
+		user := *check.User
+		cp.User = &user
+	}
+	if check.Place != nil {
+		place := *check.Place
+		cp.Place = &place
+	}
+	return &cp
+}
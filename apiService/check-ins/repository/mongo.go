@@ -4,70 +4,151 @@ import (
 	"context"
 	"time"
 
-	"github.com/globalsign/mgo"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	t "github.com/contact-tracker/apiService/check-ins/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
 )
 
 var ColCheckIns = "checkIns"
 
+// MongoMigrations - indexes of the check ins collection
+var MongoMigrations = []m.Migration{
+	{
+		// a user's check ins by time, and their open check in
+		Version: "check-ins-0001-user-index",
+		Up:      m.CreateIndexes(ColCheckIns, m.IndexKey("user.id", "in", "out")),
+	},
+	{
+		// check ins at a place by time, to find contacts
+		Version: "check-ins-0002-place-index",
+		Up:      m.CreateIndexes(ColCheckIns, m.IndexKey("place.id", "in")),
+	},
+	{
+		// open check ins by when they are automatically checked out
+		Version: "check-ins-0003-close-by-index",
+		Up:      m.CreateIndexes(ColCheckIns, m.IndexKey("out", "closeBy")),
+	},
+	{
+		// check ins past their retention, by time or their place's purge after
+		Version: "check-ins-0004-retention-indexes",
+		Up:      m.CreateIndexes(ColCheckIns, m.IndexKey("in"), m.IndexKey("purgeAfter")),
+	},
+	{
+		// one check in per user and idempotency key, so concurrent retries
+		// can't both check in
+		Version: "check-ins-0005-in-key-index",
+		Up:      uniqueInKeys,
+	},
+}
+
+// hasInKey matches check ins created with an idempotency key
+var hasInKey = m.M{"inKey": m.M{"$gt": ""}}
+
+// uniqueInKeys clears the idempotency key of all but the first of a user's
+// check ins created with the same one, then indexes them as unique
+func uniqueInKeys(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection(ColCheckIns)
+
+	var dups []struct {
+		IDs []string `bson:"ids"`
+	}
+	if err := m.Aggregate(ctx, c, &dups, []m.M{
+		{"$match": hasInKey},
+		{"$sort": m.SortKeys("in", "_id")},
+		{"$group": m.M{"_id": m.M{"user": "$user.id", "key": "$inKey"}, "ids": m.M{"$push": "$_id"}}},
+		{"$match": m.M{"ids.1": m.M{"$exists": true}}},
+	}); err != nil {
+		return err
+	}
+	for _, dup := range dups {
+		if err := m.UpdateAll(ctx, c, m.M{"_id": m.M{"$in": dup.IDs[1:]}}, m.M{"$unset": m.M{"inKey": ""}}); err != nil {
+			return err
+		}
+	}
+	_, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    m.SortKeys("user.id", "inKey"),
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(hasInKey),
+	})
+	return err
+}
+
 // MongoCheckInRepository -
 type MongoCheckInRepository struct {
-	session *mgo.Session
-	dbname  string
+	client    *mongo.Client
+	dbname    string
+	tentative time.Duration
 }
 
-// NewMongoCheckInRepository -
-func NewMongoCheckInRepository(m *mgo.Session, dbname string) *MongoCheckInRepository {
-	return &MongoCheckInRepository{m, dbname}
+// NewMongoCheckInRepository - tentative is how long a check in without a
+// check out is assumed to last when computing contacts
+func NewMongoCheckInRepository(client *mongo.Client, dbname string, tentative time.Duration) *MongoCheckInRepository {
+	return &MongoCheckInRepository{client, dbname, tentative}
 }
 
-func (r *MongoCheckInRepository) DB() (*mgo.Session, *mgo.Database) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname)
+// Migrate creates or updates the check ins indexes
+func (r *MongoCheckInRepository) Migrate(ctx context.Context) error {
+	return m.Migrate(ctx, r.DB(), MongoMigrations)
 }
 
-func (r *MongoCheckInRepository) C(colName string) (*mgo.Session, *mgo.Collection) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname).C(colName)
+func (r *MongoCheckInRepository) DB() *mongo.Database {
+	return r.client.Database(r.dbname)
 }
 
-func (r *MongoCheckInRepository) Get(_ context.Context, id string) (resp *t.CheckIn, err error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+func (r *MongoCheckInRepository) C(colName string) *mongo.Collection {
+	return r.DB().Collection(colName)
+}
+
+func (r *MongoCheckInRepository) tentativeMillis() int64 {
+	return int64(r.tentative / time.Millisecond)
+}
+
+// mayOverlap matches check ins overlapping start to end, giving open check
+// ins their tentative check out, using only stored fields so the match is
+// made on the user and place indexes before the pipeline adds fields
+func (r *MongoCheckInRepository) mayOverlap(start, end time.Time) m.M {
+	return m.M{
+		"in": m.M{"$lte": end},
+		"$or": []m.M{
+			{"out": m.M{"$gte": start}},
+			{"out": nil, "in": m.M{"$gte": start.Add(-r.tentative)}},
+		},
+	}
+}
 
-	err = m.FindOne(c, &resp, m.M{"_id": id})
+func (r *MongoCheckInRepository) Get(ctx context.Context, id string) (resp *t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
+
+	err = m.FindOne(ctx, c, &resp, m.M{"_id": id})
 	return
 }
 
-func (r *MongoCheckInRepository) GetHistory(_ context.Context, userID string, start, end *time.Time) (resp []*t.CheckInHistory, err error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+// GetHistory gets check ins with their contacts: other users' check ins
+// at the same place overlapping each check in. Contacts are joined from a
+// single query for check ins at the history's places during its window.
+func (r *MongoCheckInRepository) GetHistory(ctx context.Context, req *t.GetHistory) (resp []*t.CheckInHistory, err error) {
+	c := r.C(ColCheckIns)
 
 	match := m.M{}
-	if len(userID) != 0 {
-		match["user.id"] = userID
+	if req.UserID != nil && len(*req.UserID) != 0 {
+		match["user.id"] = *req.UserID
+	}
+	if req.PlaceID != nil && len(*req.PlaceID) != 0 {
+		match["place.id"] = *req.PlaceID
+	}
+	start, end := req.Start, req.End
+	if start != nil && end != nil {
+		for key, val := range r.mayOverlap(*start, *end) {
+			match[key] = val
+		}
 	}
 
 	pipeline := []m.M{
 		{"$match": match},
-		{"$addFields": m.M{
-			"out": m.M{
-				"$ifNull": []interface{}{
-					"$out",
-					m.M{"$add": []interface{}{"$in", 60000 * 5}}, // add 5 mins to in if out is null
-				},
-			},
-			"tentative": m.M{
-				"$cond": m.M{
-					"if":   m.M{"$eq": []interface{}{"$out", nil}},
-					"then": true,
-					"else": false,
-				},
-			},
-		}},
+		r.tentativeOut(),
 	}
 
 	if start != nil && end != nil {
@@ -83,103 +164,256 @@ func (r *MongoCheckInRepository) GetHistory(_ context.Context, userID string, st
 	}
 
 	resp = []*t.CheckInHistory{}
-	if err = m.Aggregate(c, &resp, pipeline); err != nil {
+	if err = m.Aggregate(ctx, c, &resp, pipeline); err != nil || len(resp) == 0 {
 		return
 	}
+
+	// contacts are only people who were at the same place, so only check
+	// ins at the history's places during its window can be contacts
+	var windowStart, windowEnd *time.Time
+	placeIDs := []string{}
+	seen := map[string]bool{}
+	anyPlace := false
 	for _, check := range resp {
-		contacts := []*t.CheckIn{}
-		if err = m.Aggregate(c, &contacts, []m.M{
-			{"$match": m.M{
-				"user.id": m.M{"$ne": check.User.ID},
-			}},
-			{"$addFields": m.M{
-				"out": m.M{
-					"$ifNull": []interface{}{
-						"$out",
-						m.M{"$add": []interface{}{"$in", 60000 * 5}}, // add 5 mins to in if out is null
-					},
-				},
-				"tentative": m.M{
-					"$cond": m.M{
-						"if":   m.M{"$eq": []interface{}{"$out", nil}},
-						"then": true,
-						"else": false,
-					},
-				},
-			}},
-			{"$match": m.M{
-				"$or": []m.M{
-					m.M{"out": m.M{"$gte": check.In, "$lte": check.Out}},
-					m.M{"in": m.M{"$gte": check.In, "$lte": check.Out}},
-					m.M{"in": m.M{"$lte": check.In}, "out": m.M{"$gte": check.Out}},
-				},
-			}},
-		}); err != nil {
-			return
+		if check.In == nil || check.Out == nil {
+			continue
+		}
+		if windowStart == nil || check.In.Before(*windowStart) {
+			windowStart = check.In
 		}
-		check.Contacts = contacts
+		if windowEnd == nil || check.Out.After(*windowEnd) {
+			windowEnd = check.Out
+		}
+		if check.Place == nil {
+			anyPlace = true
+		} else if !seen[check.Place.ID] {
+			seen[check.Place.ID] = true
+			placeIDs = append(placeIDs, check.Place.ID)
+		}
+	}
+	if windowStart == nil {
+		JoinContacts(resp, nil)
+		return
+	}
+	contactMatch := r.mayOverlap(*windowStart, *windowEnd)
+	if !anyPlace {
+		contactMatch["place.id"] = m.M{"$in": placeIDs}
 	}
+
+	checkIns := []*t.CheckIn{}
+	if err = m.Aggregate(ctx, c, &checkIns, []m.M{
+		{"$match": contactMatch},
+		r.tentativeOut(),
+	}); err != nil {
+		return
+	}
+	JoinContacts(resp, checkIns)
 	return
 }
 
-func (r *MongoCheckInRepository) GetAll(_ context.Context, userID *string, start, end *time.Time) (resp []*t.CheckIn, err error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+// tentativeOut is a pipeline stage setting open check ins' out to in plus
+// the tentative duration
+func (r *MongoCheckInRepository) tentativeOut() m.M {
+	return m.M{"$addFields": m.M{
+		"out": m.M{
+			"$ifNull": []interface{}{
+				"$out",
+				m.M{"$add": []interface{}{"$in", r.tentativeMillis()}}, // add tentative duration to in if out is null
+			},
+		},
+		"tentative": m.M{
+			"$cond": m.M{
+				"if":   m.M{"$eq": []interface{}{"$out", nil}},
+				"then": true,
+				"else": false,
+			},
+		},
+	}}
+}
+
+func (r *MongoCheckInRepository) GetAll(ctx context.Context, req *t.GetCheckIns, page *api.PageQuery) (resp []*t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
 
 	query := m.M{}
-	if userID != nil {
-		query["user.id"] = *userID
+	if req.UserID != nil {
+		query["user.id"] = *req.UserID
 	}
-	if start != nil {
-		query["in"] = m.M{"$gte": *start}
+	if req.PlaceID != nil {
+		query["place.id"] = *req.PlaceID
 	}
-	if end != nil {
-		query["in"] = m.M{"$lt": *end}
+	if req.End != nil {
+		inOp := "$lt"
+		if req.IncludesEnd() {
+			inOp = "$lte"
+		}
+		query["in"] = m.M{inOp: *req.End}
+	}
+	outOp := "$gt"
+	if req.IncludesStart() {
+		outOp = "$gte"
+	}
+	switch {
+	case req.Status == t.StatusOpen:
+		query["out"] = nil
+	case req.Start != nil && req.Status == t.StatusClosed:
+		query["out"] = m.M{outOp: *req.Start}
+	case req.Start != nil:
+		query["$or"] = []m.M{{"out": nil}, {"out": m.M{outOp: *req.Start}}}
+	case req.Status == t.StatusClosed:
+		query["out"] = m.M{"$ne": nil}
 	}
+	var after interface{}
+	if page.After != nil {
+		if after, err = page.After.Time(); err != nil {
+			return
+		}
+	}
+	_, afterID := page.Start()
 
-	err = m.Find(c, &resp, query)
+	resp = []*t.CheckIn{}
+	err = m.FindPage(ctx, c, &resp, query, "in", page.Desc, after, afterID, page.Limit+1)
 	return
 }
 
-func (r *MongoCheckInRepository) LastCheckIn(_ context.Context, userID string) (resp *t.CheckIn, err error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+func (r *MongoCheckInRepository) LastCheckIn(ctx context.Context, userID string) (resp *t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
 
 	resp = &t.CheckIn{}
-	err = m.FindOne(c, resp, m.M{"user.id": userID, "out": m.M{"$eq": nil}})
+	err = m.FindOne(ctx, c, resp, m.M{"user.id": userID, "out": m.M{"$eq": nil}})
 	return
 }
 
-func (r *MongoCheckInRepository) Create(_ context.Context, checkIn *t.CheckIn) (*t.CheckIn, error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+// Create a check in, or return the user's check in created with the same
+// idempotency key if there is one
+func (r *MongoCheckInRepository) Create(ctx context.Context, checkIn *t.CheckIn) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
 
 	if checkIn.ID == "" {
 		checkIn.ID = uuid.New().String()
 	}
 
 	var resp t.CheckIn
-	err := m.Upsert(c, &resp, m.M{"_id": checkIn.ID}, m.M{"$set": checkIn})
+	err := m.Upsert(ctx, c, &resp, m.M{"_id": checkIn.ID}, m.M{"$set": checkIn})
+	if mongo.IsDuplicateKeyError(err) && checkIn.InKey != "" && checkIn.User != nil {
+		return r.FindByIdempotencyKey(ctx, checkIn.User.ID, checkIn.InKey, time.Time{})
+	}
+	return &resp, err
+}
+
+func (r *MongoCheckInRepository) CheckOut(ctx context.Context, id string, out time.Time, idempotencyKey string) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
+
+	set := m.M{"out": out}
+	if idempotencyKey != "" {
+		set["outKey"] = idempotencyKey
+	}
+
+	var resp t.CheckIn
+	err := m.Update(ctx, c, &resp, m.M{"_id": id}, m.M{"$set": set})
 	return &resp, err
 }
 
-func (r *MongoCheckInRepository) CheckOut(_ context.Context, id string) (*t.CheckIn, error) {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+// GetOverlapping gets a user's check ins overlapping start to end, or
+// anytime after start if end is nil
+func (r *MongoCheckInRepository) GetOverlapping(ctx context.Context, userID string, start time.Time, end *time.Time) (resp []*t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
+
+	query := m.M{
+		"user.id": userID,
+		"$or": []m.M{
+			{"out": m.M{"$eq": nil}},
+			{"out": m.M{"$gt": start}},
+		},
+	}
+	if end != nil {
+		query["in"] = m.M{"$lt": *end}
+	}
+
+	resp = []*t.CheckIn{}
+	err = m.Find(ctx, c, &resp, query)
+	return
+}
+
+// FindByIdempotencyKey finds the check in created or checked out by a
+// request with the given key since the given time
+func (r *MongoCheckInRepository) FindByIdempotencyKey(ctx context.Context, userID, key string, since time.Time) (resp *t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
+
+	resp = &t.CheckIn{}
+	err = m.FindOne(ctx, c, resp, m.M{
+		"user.id": userID,
+		"$or": []m.M{
+			{"inKey": key, "in": m.M{"$gte": since}},
+			{"outKey": key, "out": m.M{"$gte": since}},
+		},
+	})
+	return
+}
+
+// GetStale gets open check ins past their close by time, or for check ins
+// without one, that checked in before legacyBefore
+func (r *MongoCheckInRepository) GetStale(ctx context.Context, now, legacyBefore time.Time) (resp []*t.CheckIn, err error) {
+	c := r.C(ColCheckIns)
+
+	resp = []*t.CheckIn{}
+	err = m.Find(ctx, c, &resp, m.M{
+		"out": m.M{"$eq": nil},
+		"$or": []m.M{
+			{"closeBy": m.M{"$lte": now}},
+			{"closeBy": m.M{"$exists": false}, "in": m.M{"$lte": legacyBefore}},
+		},
+	})
+	return
+}
+
+func (r *MongoCheckInRepository) AutoCheckOut(ctx context.Context, id string, out time.Time) (*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
 
 	var resp t.CheckIn
-	err := m.Update(c, &resp, m.M{"_id": id}, m.M{"$set": m.M{"out": time.Now()}})
+	err := m.Update(ctx, c, &resp, m.M{"_id": id, "out": m.M{"$eq": nil}}, m.M{"$set": m.M{"out": out, "autoOut": true}})
 	return &resp, err
 }
 
-func (r *MongoCheckInRepository) Delete(_ context.Context, id string) error {
-	sess, c := r.C(ColCheckIns)
-	defer sess.Close()
+// GetExpired gets up to limit check ins, by id after afterID, past their
+// place's purge after time or that checked in before before
+func (r *MongoCheckInRepository) GetExpired(ctx context.Context, now, before time.Time, afterID string, limit int) ([]*t.CheckIn, error) {
+	c := r.C(ColCheckIns)
+
+	cur, err := c.Find(ctx, m.M{
+		"_id": m.M{"$gt": afterID},
+		"$or": []m.M{
+			{"in": m.M{"$lt": before}},
+			{"purgeAfter": m.M{"$lte": now}},
+		},
+	}, options.Find().SetSort(m.SortKeys("_id")).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	resp := []*t.CheckIn{}
+	if err = cur.All(ctx, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeleteAll deletes the check ins with ids, returning how many were deleted
+func (r *MongoCheckInRepository) DeleteAll(ctx context.Context, ids []string) (int, error) {
+	c := r.C(ColCheckIns)
+
+	res, err := c.DeleteMany(ctx, m.M{"_id": m.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+func (r *MongoCheckInRepository) Delete(ctx context.Context, id string) error {
+	c := r.C(ColCheckIns)
 
 	var out t.CheckIn
-	if err := m.FindOne(c, &out, m.M{"_id": id}); err != nil {
+	if err := m.FindOne(ctx, c, &out, m.M{"_id": id}); err != nil {
 		return err
 	}
 
-	return m.Remove(c, m.M{"_id": id})
+	return m.Remove(ctx, c, m.M{"_id": id})
 }
@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+)
+
+// PostgresMigrations - schema of the check ins table. Each check in's stay
+// is indexed as a range, unbounded while it is still open, so overlap
+// queries only scan check ins which could overlap.
+var PostgresMigrations = []pg.Migration{
+	{
+		Version: "check-ins-0001-create-check-ins",
+		SQL: `CREATE TABLE check_ins (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			user_name text NOT NULL DEFAULT '',
+			place_id text,
+			place_name text NOT NULL DEFAULT '',
+			check_in timestamptz NOT NULL,
+			check_out timestamptz,
+			close_by timestamptz,
+			auto_out boolean NOT NULL DEFAULT false,
+			device_id text NOT NULL DEFAULT '',
+			in_key text NOT NULL DEFAULT '',
+			out_key text NOT NULL DEFAULT '',
+			CHECK (check_out IS NULL OR check_out >= check_in)
+		);
+		CREATE INDEX check_ins_user_idx ON check_ins (user_id, check_in);
+		CREATE INDEX check_ins_place_idx ON check_ins (place_id, check_in);
+		CREATE INDEX check_ins_open_idx ON check_ins (user_id) WHERE check_out IS NULL;
+		CREATE INDEX check_ins_stay_idx ON check_ins USING gist (tstzrange(check_in, check_out, '[]'));`,
+	},
+	{
+		Version: "check-ins-0002-retention",
+		SQL: `ALTER TABLE check_ins ADD COLUMN purge_after timestamptz;
+		CREATE INDEX check_ins_in_idx ON check_ins (check_in);
+		CREATE INDEX check_ins_purge_idx ON check_ins (purge_after) WHERE purge_after IS NOT NULL;`,
+	},
+	{
+		// one check in per user and idempotency key, so concurrent retries
+		// can't both check in. Earlier duplicates keep only the first's key.
+		Version: "check-ins-0003-in-key-index",
+		SQL: `UPDATE check_ins SET in_key = '' WHERE id IN (
+			SELECT id FROM (
+				SELECT id, row_number() OVER (PARTITION BY user_id, in_key ORDER BY check_in, id) AS n
+				FROM check_ins WHERE in_key <> ''
+			) keyed WHERE n > 1
+		);
+		CREATE UNIQUE INDEX check_ins_in_key_idx ON check_ins (user_id, in_key) WHERE in_key <> '';`,
+	},
+}
+
+// inKeyIndex - unique index on a user's check ins by idempotency key
+const inKeyIndex = "check_ins_in_key_idx"
+
+var checkInColumns = []string{"id", "user_id", "user_name", "place_id", "place_name", "check_in", "check_out", "close_by", "auto_out", "device_id", "in_key", "out_key", "purge_after"}
+
+// columns returns the check in columns of the table aliased as alias
+func columns(alias string) string {
+	cols := make([]string, len(checkInColumns))
+	for i, col := range checkInColumns {
+		cols[i] = alias + "." + col
+	}
+	return strings.Join(cols, ", ")
+}
+
+// stayOverlaps matches check ins in alias which, with open ones lasting
+// the tentative duration in $1, overlap start to end. The range overlap
+// uses the stay index and the comparisons then apply the tentative duration.
+func stayOverlaps(alias, start, end string) string {
+	return "tstzrange(" + alias + ".check_in, " + alias + ".check_out, '[]') && tstzrange(" + start + ", " + end + ", '[]')" +
+		" AND " + alias + ".check_in <= " + end +
+		" AND coalesce(" + alias + ".check_out, " + alias + ".check_in + $1::interval) >= " + start
+}
+
+// PostgresCheckInRepository -
+type PostgresCheckInRepository struct {
+	db        *sql.DB
+	tentative time.Duration
+}
+
+// NewPostgresCheckInRepository - tentative is how long a check in without a
+// check out is assumed to last when computing contacts
+func NewPostgresCheckInRepository(db *sql.DB, tentative time.Duration) *PostgresCheckInRepository {
+	return &PostgresCheckInRepository{db, tentative}
+}
+
+// Migrate creates or updates the check ins table
+func (r *PostgresCheckInRepository) Migrate(ctx context.Context) error {
+	return pg.Migrate(ctx, r.db, PostgresMigrations)
+}
+
+func (r *PostgresCheckInRepository) Get(ctx context.Context, id string) (*t.CheckIn, error) {
+	return scanCheckIn(r.db.QueryRowContext(ctx, "SELECT "+columns("c")+" FROM check_ins c WHERE c.id = $1", id))
+}
+
+// GetHistory gets check ins with their contacts: other users' check ins at
+// the same place overlapping each one, with open check ins assumed to last
+// the tentative duration
+func (r *PostgresCheckInRepository) GetHistory(ctx context.Context, req *t.GetHistory) ([]*t.CheckInHistory, error) {
+	resp := []*t.CheckInHistory{}
+	if req.Start != nil && req.End != nil && req.Start.After(*req.End) {
+		return resp, nil
+	}
+
+	args := pg.Args{pg.Interval(r.tentative)}
+	where := []string{"true"}
+	if req.UserID != nil && len(*req.UserID) != 0 {
+		where = append(where, "c.user_id = "+args.Add(*req.UserID))
+	}
+	if req.PlaceID != nil && len(*req.PlaceID) != 0 {
+		where = append(where, "c.place_id = "+args.Add(*req.PlaceID))
+	}
+	if req.Start != nil && req.End != nil {
+		where = append(where, stayOverlaps("c", args.Add(*req.Start), args.Add(*req.End)))
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+columns("c")+", c.check_out IS NULL, coalesce(c.check_out, c.check_in + $1::interval)"+
+		" FROM check_ins c WHERE "+strings.Join(where, " AND ")+" ORDER BY c.check_in, c.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	byID := map[string]*t.CheckInHistory{}
+	for rows.Next() {
+		var (
+			tentative bool
+			out       time.Time
+		)
+		check, err := scanCheckIn(rows, &tentative, &out)
+		if err != nil {
+			return nil, err
+		}
+		history := &t.CheckInHistory{
+			ID:                check.ID,
+			In:                check.In,
+			Out:               &out,
+			User:              check.User,
+			Place:             check.Place,
+			Contacts:          []*t.Contact{},
+			TentativeCheckout: tentative,
+			AutoCheckout:      check.AutoCheckout,
+		}
+		ids = append(ids, check.ID)
+		byID[check.ID] = history
+		resp = append(resp, history)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+
+	// contacts are only people who were at the same place
+	contacts, err := r.db.QueryContext(ctx, "SELECT "+columns("o")+", o.check_out IS NULL, coalesce(o.check_out, o.check_in + $1::interval), c.id"+
+		" FROM check_ins c JOIN check_ins o ON o.user_id <> c.user_id AND (c.place_id IS NULL OR o.place_id = c.place_id)"+
+		" AND "+stayOverlaps("o", "c.check_in", "coalesce(c.check_out, c.check_in + $1::interval)")+
+		" WHERE c.id = ANY($2) ORDER BY o.check_in, o.id", pg.Interval(r.tentative), pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer contacts.Close()
+
+	for contacts.Next() {
+		var (
+			tentative bool
+			out       time.Time
+			checkID   string
+		)
+		check, err := scanCheckIn(contacts, &tentative, &out, &checkID)
+		if err != nil {
+			return nil, err
+		}
+		check.Out = &out
+		check.TentativeCheckout = tentative
+		byID[checkID].Contacts = append(byID[checkID].Contacts, &t.Contact{CheckIn: *check})
+	}
+	return resp, contacts.Err()
+}
+
+func (r *PostgresCheckInRepository) GetAll(ctx context.Context, req *t.GetCheckIns, page *api.PageQuery) ([]*t.CheckIn, error) {
+	args := pg.Args{}
+	where := []string{"true"}
+	if req.UserID != nil {
+		where = append(where, "c.user_id = "+args.Add(*req.UserID))
+	}
+	if req.PlaceID != nil {
+		where = append(where, "c.place_id = "+args.Add(*req.PlaceID))
+	}
+	if req.Start != nil || req.End != nil {
+		bounds := req.Bounds
+		if bounds == "" {
+			bounds = t.DefaultBounds
+		}
+		// open check ins have no upper bound, so overlap any range after they began
+		where = append(where, "tstzrange(c.check_in, c.check_out, '[]') && tstzrange("+args.Add(req.Start)+"::timestamptz, "+args.Add(req.End)+"::timestamptz, "+args.Add(bounds)+")")
+	}
+	switch req.Status {
+	case t.StatusOpen:
+		where = append(where, "c.check_out IS NULL")
+	case t.StatusClosed:
+		where = append(where, "c.check_out IS NOT NULL")
+	}
+	var after interface{}
+	if page.After != nil {
+		in, err := page.After.Time()
+		if err != nil {
+			return nil, err
+		}
+		after = in
+	}
+	_, afterID := page.Start()
+	order := pg.Page(&where, &args, "c.check_in", "c.id", page.Desc, after, afterID, page.Limit+1)
+	return r.find(ctx, strings.Join(where, " AND ")+order, args...)
+}
+
+func (r *PostgresCheckInRepository) LastCheckIn(ctx context.Context, userID string) (*t.CheckIn, error) {
+	return scanCheckIn(r.db.QueryRowContext(ctx, "SELECT "+columns("c")+" FROM check_ins c WHERE c.user_id = $1 AND c.check_out IS NULL LIMIT 1", userID))
+}
+
+// Create a check in, or return the user's check in created with the same
+// idempotency key if there is one
+func (r *PostgresCheckInRepository) Create(ctx context.Context, checkIn *t.CheckIn) (*t.CheckIn, error) {
+	if checkIn.ID == "" {
+		checkIn.ID = uuid.New().String()
+	}
+
+	var userID, userName, placeName string
+	var placeID *string
+	if checkIn.User != nil {
+		userID, userName = checkIn.User.ID, checkIn.User.Name
+	}
+	if checkIn.Place != nil {
+		placeID, placeName = &checkIn.Place.ID, checkIn.Place.Name
+	}
+	updates := make([]string, len(checkInColumns)-1)
+	for i, col := range checkInColumns[1:] {
+		updates[i] = col + " = EXCLUDED." + col
+	}
+	resp, err := scanCheckIn(r.db.QueryRowContext(ctx, "INSERT INTO check_ins AS c ("+strings.Join(checkInColumns, ", ")+")"+
+		" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)"+
+		" ON CONFLICT (id) DO UPDATE SET "+strings.Join(updates, ", ")+" RETURNING "+columns("c"),
+		checkIn.ID, userID, userName, placeID, placeName, checkIn.In, checkIn.Out, checkIn.CloseBy, checkIn.AutoCheckout, checkIn.DeviceID, checkIn.InKey, checkIn.OutKey, checkIn.PurgeAfter))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == inKeyIndex {
+		return r.FindByIdempotencyKey(ctx, userID, checkIn.InKey, time.Time{})
+	}
+	return resp, err
+}
+
+func (r *PostgresCheckInRepository) CheckOut(ctx context.Context, id string, out time.Time, idempotencyKey string) (*t.CheckIn, error) {
+	set := pg.Assignments{}
+	set.Set("check_out", out)
+	if idempotencyKey != "" {
+		set.Set("out_key", idempotencyKey)
+	}
+	return scanCheckIn(r.db.QueryRowContext(ctx, "UPDATE check_ins AS c SET "+set.String()+" WHERE c.id = "+set.Args.Add(id)+" RETURNING "+columns("c"), set.Args...))
+}
+
+// GetOverlapping gets a user's check ins overlapping start to end, or
+// anytime after start if end is nil
+func (r *PostgresCheckInRepository) GetOverlapping(ctx context.Context, userID string, start time.Time, end *time.Time) ([]*t.CheckIn, error) {
+	args := pg.Args{userID, start}
+	where := "c.user_id = $1 AND (c.check_out IS NULL OR c.check_out > $2)"
+	if end != nil {
+		where += " AND c.check_in < " + args.Add(*end)
+	}
+	return r.find(ctx, where, args...)
+}
+
+// FindByIdempotencyKey finds the check in created or checked out by a
+// request with the given key since the given time
+func (r *PostgresCheckInRepository) FindByIdempotencyKey(ctx context.Context, userID, key string, since time.Time) (*t.CheckIn, error) {
+	return scanCheckIn(r.db.QueryRowContext(ctx, "SELECT "+columns("c")+" FROM check_ins c WHERE c.user_id = $1"+
+		" AND ((c.in_key = $2 AND c.check_in >= $3) OR (c.out_key = $2 AND c.check_out >= $3)) LIMIT 1", userID, key, since))
+}
+
+// GetStale gets open check ins past their close by time, or for check ins
+// without one, that checked in before legacyBefore
+func (r *PostgresCheckInRepository) GetStale(ctx context.Context, now, legacyBefore time.Time) ([]*t.CheckIn, error) {
+	return r.find(ctx, "c.check_out IS NULL AND (c.close_by <= $1 OR (c.close_by IS NULL AND c.check_in <= $2))", now, legacyBefore)
+}
+
+func (r *PostgresCheckInRepository) AutoCheckOut(ctx context.Context, id string, out time.Time) (*t.CheckIn, error) {
+	return scanCheckIn(r.db.QueryRowContext(ctx, "UPDATE check_ins AS c SET check_out = $2, auto_out = true WHERE c.id = $1 AND c.check_out IS NULL RETURNING "+columns("c"), id, out))
+}
+
+// GetExpired gets up to limit check ins, by id after afterID, past their
+// place's purge after time or that checked in before before
+func (r *PostgresCheckInRepository) GetExpired(ctx context.Context, now, before time.Time, afterID string, limit int) ([]*t.CheckIn, error) {
+	return r.find(ctx, "(c.check_in < $1 OR c.purge_after <= $2) AND c.id > $3 ORDER BY c.id LIMIT $4", before, now, afterID, limit)
+}
+
+// DeleteAll deletes the check ins with ids, returning how many were deleted
+func (r *PostgresCheckInRepository) DeleteAll(ctx context.Context, ids []string) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM check_ins WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *PostgresCheckInRepository) Delete(ctx context.Context, id string) error {
+	return pg.CheckAffected(r.db.ExecContext(ctx, "DELETE FROM check_ins WHERE id = $1", id))
+}
+
+// find gets the check ins matching where, a condition on the table aliased c
+func (r *PostgresCheckInRepository) find(ctx context.Context, where string, args ...interface{}) ([]*t.CheckIn, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+columns("c")+" FROM check_ins c WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*t.CheckIn{}
+	for rows.Next() {
+		check, err := scanCheckIn(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, check)
+	}
+	return resp, rows.Err()
+}
+
+// scanCheckIn scans the check in columns followed by any extra columns
+func scanCheckIn(row pg.Scanner, extra ...interface{}) (*t.CheckIn, error) {
+	var (
+		check     t.CheckIn
+		user      t.User
+		placeID   sql.NullString
+		placeName string
+	)
+	dest := append([]interface{}{
+		&check.ID, &user.ID, &user.Name, &placeID, &placeName, &check.In, &check.Out, &check.CloseBy, &check.AutoCheckout, &check.DeviceID, &check.InKey, &check.OutKey, &check.PurgeAfter,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	check.User = &user
+	if placeID.Valid {
+		check.Place = &t.Place{ID: placeID.String, Name: placeName}
+	}
+	return &check, nil
+}
@@ -0,0 +1,159 @@
+package checkins
+
+import (
+	"context"
+	"log"
+	"time"
+
+	caseT "github.com/contact-tracker/apiService/cases/types"
+	t "github.com/contact-tracker/apiService/check-ins/types"
+
+	"github.com/pkg/errors"
+)
+
+// purgeBatchSize - most expired check ins read and deleted at a time
+const purgeBatchSize = 500
+
+// Purge deletes check ins past the deployment's retention or their place's
+// purge after time, a batch at a time. Check ins which cases still being
+// investigated may need are kept until their case is closed: those of the
+// case's user and notified contacts, and every check in at a place the
+// case's user visited overlapping their infectious window. A dry run only
+// reports what would be purged.
+func (u *Usecase) Purge(ctx context.Context, dryRun bool) (*t.PurgeReport, error) {
+	now := time.Now()
+	report := &t.PurgeReport{DryRun: dryRun, Places: map[string]int{}}
+	var before time.Time
+	if u.Retention > 0 {
+		before = now.Add(-u.Retention)
+		report.Before = &before
+	}
+
+	var exempt *exemptions
+	afterID := ""
+	for {
+		expired, err := u.Repository.GetExpired(ctx, now, before, afterID, purgeBatchSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching expired check ins")
+		}
+		if len(expired) == 0 {
+			return report, nil
+		}
+		afterID = expired[len(expired)-1].ID
+		if exempt == nil {
+			if exempt, err = u.openCaseExemptions(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		ids := []string{}
+		for _, checkIn := range expired {
+			if exempt.keeps(checkIn) {
+				report.Exempt++
+				continue
+			}
+			ids = append(ids, checkIn.ID)
+			if checkIn.Place != nil {
+				report.Places[checkIn.Place.ID]++
+			}
+		}
+		if dryRun || len(ids) == 0 {
+			report.Purged += len(ids)
+			continue
+		}
+		purged, err := u.Repository.DeleteAll(ctx, ids)
+		report.Purged += purged
+		if err != nil {
+			return nil, errors.Wrap(err, "error purging expired check ins")
+		}
+	}
+}
+
+// exemptions - the check ins open cases may need
+type exemptions struct {
+	users map[string]bool
+	// places maps the places case users visited to the infectious
+	// windows of their cases
+	places map[string][]window
+}
+
+type window struct {
+	start, end time.Time
+}
+
+// openCaseExemptions fetches the open cases and the places their users
+// visited while infectious
+func (u *Usecase) openCaseExemptions(ctx context.Context) (*exemptions, error) {
+	cases, err := u.RPC.GetOpenCases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting open cases")
+	}
+	exempt := &exemptions{users: map[string]bool{}, places: map[string][]window{}}
+	for _, cs := range cases {
+		exempt.users[cs.UserID] = true
+		for _, id := range cs.NotifiedContacts {
+			exempt.users[id] = true
+		}
+		if err = u.addCasePlaces(ctx, exempt, cs); err != nil {
+			return nil, err
+		}
+	}
+	return exempt, nil
+}
+
+// addCasePlaces exempts the places the case's user checked in to during
+// their infectious window
+func (u *Usecase) addCasePlaces(ctx context.Context, exempt *exemptions, cs *caseT.Case) error {
+	if cs.InfectiousStart == nil || cs.InfectiousEnd == nil {
+		return nil
+	}
+	stays, err := u.Repository.GetOverlapping(ctx, cs.UserID, *cs.InfectiousStart, cs.InfectiousEnd)
+	if err != nil {
+		return errors.Wrapf(err, "error getting check ins of case %s", cs.ID)
+	}
+	win := window{start: *cs.InfectiousStart, end: *cs.InfectiousEnd}
+	for _, stay := range stays {
+		if stay.Place != nil {
+			exempt.places[stay.Place.ID] = append(exempt.places[stay.Place.ID], win)
+		}
+	}
+	return nil
+}
+
+// keeps returns true if the check in belongs to a user in an open case,
+// or overlaps a case's infectious window at a place its user visited
+func (e *exemptions) keeps(checkIn *t.CheckIn) bool {
+	if checkIn.User != nil && e.users[checkIn.User.ID] {
+		return true
+	}
+	if checkIn.Place == nil || checkIn.In == nil {
+		return false
+	}
+	for _, win := range e.places[checkIn.Place.ID] {
+		if !checkIn.In.After(win.end) && (checkIn.Out == nil || !checkIn.Out.Before(win.start)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunPurger purges expired check ins every interval until ctx is done
+func RunPurger(ctx context.Context, svc CheckInService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := svc.Purge(ctx, false)
+			if err != nil {
+				log.Printf("Error purging expired check ins: %v\n", err)
+				continue
+			}
+			if report.Purged > 0 || report.Exempt > 0 {
+				log.Printf("Purged %d expired check in(s), kept %d in open cases\n", report.Purged, report.Exempt)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package checkins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	caseT "github.com/contact-tracker/apiService/cases/types"
+	repo "github.com/contact-tracker/apiService/check-ins/repository"
+	t "github.com/contact-tracker/apiService/check-ins/types"
+	"github.com/contact-tracker/apiService/pkg/memory"
+
+	"github.com/pkg/errors"
+)
+
+func TestPurgeExemptions(tt *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	daysAgo := func(days float64) *time.Time {
+		at := now.Add(-time.Duration(days * float64(24*time.Hour)))
+		return &at
+	}
+	// The open case's user was infectious from 12 to 8 days ago, and
+	// visited place-1 while infectious
+	cs := &caseT.Case{
+		ID:               "case-1",
+		UserID:           "case-user",
+		NotifiedContacts: []string{"contact"},
+		InfectiousStart:  daysAgo(12),
+		InfectiousEnd:    daysAgo(8),
+		Status:           caseT.StatusNotified,
+	}
+
+	tests := []struct {
+		name  string
+		user  string
+		place string
+		in    *time.Time
+		out   *time.Time
+		kept  bool
+	}{
+		{"case user", "case-user", "place-1", daysAgo(10), daysAgo(9.9), true},
+		{"case user elsewhere before the window", "case-user", "place-2", daysAgo(20), daysAgo(19.9), true},
+		{"notified contact", "contact", "place-3", daysAgo(30), daysAgo(29.9), true},
+		{"same place in the window", "visitor-1", "place-1", daysAgo(9), daysAgo(8.9), true},
+		{"same place still open in the window", "visitor-2", "place-1", daysAgo(13), nil, true},
+		{"same place before the window", "visitor-3", "place-1", daysAgo(20), daysAgo(19.9), false},
+		{"same place after the window", "visitor-4", "place-1", daysAgo(7.5), daysAgo(7.4), false},
+		{"other place in the window", "visitor-5", "place-4", daysAgo(10), daysAgo(9.9), false},
+		{"within retention", "visitor-6", "place-4", daysAgo(1), daysAgo(0.9), true},
+	}
+	repository := repo.NewMemoryCheckInRepository("purge-test", time.Hour)
+	ids := make([]string, len(tests))
+	for i, test := range tests {
+		checkIn, err := repository.Create(ctx, &t.CheckIn{
+			In:    test.in,
+			Out:   test.out,
+			User:  &t.User{ID: test.user},
+			Place: &t.Place{ID: test.place},
+		})
+		if err != nil {
+			tt.Fatalf("Error creating check in: %v", err)
+		}
+		ids[i] = checkIn.ID
+	}
+	u := &Usecase{
+		Repository: repository,
+		RPC:        &stubRPC{cases: []*caseT.Case{cs}},
+		Retention:  7 * 24 * time.Hour,
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		report, err := u.Purge(ctx, dryRun)
+		if err != nil {
+			tt.Fatalf("Error purging: %v", err)
+		}
+		if report.Purged != 3 || report.Exempt != 5 {
+			tt.Errorf("Purge dry run %t purged %d and kept %d, want 3 and 5", dryRun, report.Purged, report.Exempt)
+		}
+	}
+	for i, test := range tests {
+		_, err := repository.Get(ctx, ids[i])
+		if kept := errors.Cause(err) != memory.ErrNotFound; kept != test.kept {
+			tt.Errorf("%s: got kept %t, want %t: %v", test.name, kept, test.kept, err)
+		}
+	}
+}
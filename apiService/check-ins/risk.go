@@ -0,0 +1,53 @@
+package checkins
+
+import (
+	"strconv"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+
+	"github.com/pkg/errors"
+)
+
+// ParseRiskRules builds risk rules from config values, keeping the
+// default for any value left empty
+func ParseRiskRules(minOverlapMinutes, fullRiskMinutes, tentativeFactor, unknownPlaceFactor string) (rules t.RiskRules, err error) {
+	rules = t.DefaultRiskRules
+	for _, cfg := range []struct {
+		val string
+		out *float64
+	}{
+		{minOverlapMinutes, &rules.MinOverlapMinutes},
+		{fullRiskMinutes, &rules.FullRiskMinutes},
+		{tentativeFactor, &rules.TentativeFactor},
+		{unknownPlaceFactor, &rules.UnknownPlaceFactor},
+	} {
+		if cfg.val == "" {
+			continue
+		}
+		if *cfg.out, err = strconv.ParseFloat(cfg.val, 64); err != nil {
+			return rules, errors.Wrapf(err, "error parsing risk rule %s", cfg.val)
+		}
+	}
+	return rules, nil
+}
+
+// scoreContacts sets the overlap and risk score of each contact and drops
+// contacts with no risk, such as those overlapping for less than the minimum,
+// and any scoring below minScore
+func (u *Usecase) scoreContacts(history []*t.CheckInHistory, minScore float64) {
+	for _, check := range history {
+		self := &t.CheckIn{In: check.In, Out: check.Out, Place: check.Place}
+		contacts := []*t.Contact{}
+		for _, contact := range check.Contacts {
+			overlap := t.Overlap(self, &contact.CheckIn)
+			samePlace := check.Place != nil && contact.Place != nil && check.Place.ID == contact.Place.ID
+			contact.OverlapMinutes = overlap.Minutes()
+			tentative := check.TentativeCheckout || check.AutoCheckout || contact.TentativeCheckout || contact.AutoCheckout
+			contact.RiskScore = u.RiskRules.Score(overlap, tentative, samePlace)
+			if contact.RiskScore > 0 && contact.RiskScore >= minScore {
+				contacts = append(contacts, contact)
+			}
+		}
+		check.Contacts = contacts
+	}
+}
@@ -3,32 +3,41 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"net/url"
 
+	caseT "github.com/contact-tracker/apiService/cases/types"
 	apiHttp "github.com/contact-tracker/apiService/pkg/api/http"
 	apiRpc "github.com/contact-tracker/apiService/pkg/api/rpc"
+	"github.com/contact-tracker/apiService/pkg/auth"
 	pT "github.com/contact-tracker/apiService/places/types"
 	uT "github.com/contact-tracker/apiService/users/types"
 )
 
 // RPCClient - Make RPC calls via HTTP
 type RPCClient struct {
-	client         *apiRpc.HTTPRPCClient
+	placesClient   *apiRpc.HTTPRPCClient
+	usersClient    *apiRpc.HTTPRPCClient
+	casesClient    *apiRpc.HTTPRPCClient
 	placesHostName string
 	usersHostName  string
+	casesHostName  string
 }
 
 // NewRPCClient - Initializes new client
-func NewRPCClient(placesHostName, usersHostName, rpcPwd string) *RPCClient {
+func NewRPCClient(placesHostName, usersHostName, casesHostName string, tokens apiRpc.TokenSource) *RPCClient {
 	return &RPCClient{
-		client:         apiRpc.NewHTTPRPCClient(rpcPwd),
+		placesClient:   apiRpc.NewHTTPRPCClient(tokens, auth.ServicePlaces, auth.ScopeReadPlaces),
+		usersClient:    apiRpc.NewHTTPRPCClient(tokens, auth.ServiceUsers, auth.ScopeReadUsers),
+		casesClient:    apiRpc.NewHTTPRPCClient(tokens, auth.ServiceCases, auth.ScopeReadCases),
 		placesHostName: placesHostName,
 		usersHostName:  usersHostName,
+		casesHostName:  casesHostName,
 	}
 }
 
 func (c *RPCClient) GetPlace(ctx context.Context, id string) (*pT.Place, error) {
 	var place pT.Place
-	if code, err := c.client.HttpRequest("GET", fmt.Sprintf("%s/places/%s", c.placesHostName, id), nil, &place); err != nil {
+	if code, err := c.placesClient.HttpRequest("GET", fmt.Sprintf("%s/places/%s", c.placesHostName, id), nil, &place); err != nil {
 		apiHttp.CheckHTTPError(code, err)
 	}
 
@@ -37,9 +46,32 @@ func (c *RPCClient) GetPlace(ctx context.Context, id string) (*pT.Place, error)
 
 func (c *RPCClient) GetUser(ctx context.Context, id string) (*uT.User, error) {
 	var user uT.User
-	if code, err := c.client.HttpRequest("GET", fmt.Sprintf("%s/users/%s", c.usersHostName, id), nil, &user); err != nil {
+	if code, err := c.usersClient.HttpRequest("GET", fmt.Sprintf("%s/users/%s", c.usersHostName, id), nil, &user); err != nil {
 		apiHttp.CheckHTTPError(code, err)
 	}
 
 	return &user, nil
 }
+
+// GetOpenCases returns the cases still being investigated. Errors are
+// returned rather than aborting, so a failed call can't be mistaken for
+// there being no open cases.
+func (c *RPCClient) GetOpenCases(ctx context.Context) ([]*caseT.Case, error) {
+	cases := []*caseT.Case{}
+	for _, status := range caseT.OpenStatuses {
+		query := url.Values{}
+		query.Set("status", string(status))
+		for {
+			var page caseT.CasePage
+			if _, err := c.casesClient.HttpRequest("GET", fmt.Sprintf("%s/cases?%s", c.casesHostName, query.Encode()), nil, &page); err != nil {
+				return nil, err
+			}
+			cases = append(cases, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", page.NextCursor)
+		}
+	}
+	return cases, nil
+}
@@ -2,9 +2,11 @@ package checkins
 
 import (
 	"context"
+	"database/sql"
 	// "flag"
 	"io/ioutil"
 	"log"
+	"strconv"
 	"time"
 	// "os"
 
@@ -12,69 +14,230 @@ import (
 	chkRpc "github.com/contact-tracker/apiService/check-ins/rpc"
 	t "github.com/contact-tracker/apiService/check-ins/types"
 	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/contact-tracker/apiService/pkg/memory"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
+	"github.com/contact-tracker/apiService/pkg/postgres"
 	// "github.com/joho/godotenv"
 	// "go.uber.org/zap"
+
+	"github.com/pkg/errors"
 )
 
 // CheckInService - is the top level signature of this service
 type CheckInService interface {
 	Get(ctx context.Context, id string) (*t.CheckIn, error)
-	GetHistory(ctx context.Context, userID string, start, end *time.Time) ([]*t.CheckInHistory, error)
-	GetAll(ctx context.Context, req *t.GetCheckIns) ([]*t.CheckIn, error)
-	CheckIn(ctx context.Context, req *t.CreateCheckIn) (resp *t.CheckIn, err error)
+	GetHistory(ctx context.Context, req *t.GetHistory) ([]*t.CheckInHistory, error)
+	GetPlaceHistory(ctx context.Context, req *t.GetPlaceHistory) ([]*t.CheckInHistory, error)
+	TraceContacts(ctx context.Context, req *t.TraceContacts) (*t.ContactTrace, error)
+	GetAll(ctx context.Context, req *t.GetCheckIns) (*t.CheckInPage, error)
+	Toggle(ctx context.Context, req *t.CreateCheckIn) (*t.CheckIn, error)
+	CheckIn(ctx context.Context, req *t.CreateCheckIn) (*t.CheckIn, error)
+	CheckOut(ctx context.Context, req *t.CheckOut) (*t.CheckIn, error)
+	CloseStale(ctx context.Context) ([]*t.CheckIn, error)
+	Purge(ctx context.Context, dryRun bool) (*t.PurgeReport, error)
+	Sync(ctx context.Context, req *t.SyncCheckIns) (*t.SyncCheckInsResult, error)
+}
+
+// Config - settings for Init, as read from the environment. Empty
+// numbers and durations fall back to their defaults.
+type Config struct {
+	MongoDBName   string
+	MongoURI      string
+	MongoUser     string
+	MongoPwd      string
+	UsersHost     string
+	PlacesHost    string
+	CasesHost     string
+	JWTKeyPath    string
+	JWTSecretPath string
+	// Risk scoring, see ParseRiskRules
+	RiskMinOverlap         string
+	RiskFullOverlap        string
+	RiskTentativeFactor    string
+	RiskUnknownPlaceFactor string
+	// Check out, sync and history windows in minutes
+	TentativeMinutes         string
+	MaxDwellMinutes          string
+	IdempotencyWindowMinutes string
+	SyncMaxClockSkewMinutes  string
+	HistoryWindowMinutes     string
+	MaxHistoryWindowMinutes  string
+	// RetentionDays - how long check ins are kept, forever when empty or 0
+	RetentionDays string
+	// Storage picks the repositories, mongo by default
+	Storage     string
+	PostgresURL string
 }
 
 // Init sets up an instance of this domains
 // usecase, pre-configured with the dependencies.
-func Init(mongoDBName, mongoHost, mongoCheckIn, mongoPwd, usersHost, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd string) (CheckInService, *auth.JWTService, error) {
+func Init(cfg Config) (CheckInService, *auth.JWTService, error) {
 	// cfgPath := flag.String("config", "config.dev.yml", "path for yaml config")
 	// flag.Parse()
 	// godotenv.Load(*cfgPath)
 	// mongoDBName := os.Getenv("MONGO_DB_NAME")
-	// mongoHost := os.Getenv("MONGO_HOST")
+	// mongoURI := os.Getenv("MONGO_URI")
 	// mongoCheckIn := os.Getenv("MONGO_USER")
 	// mongoPwd := os.Getenv("MONGO_PWD")
 	// usersHost := os.Getenv("USERS_HOST")
 	// placesHost := os.Getenv("PLACES_HOST")
 	// jwtKeyPath := os.Getenv("JWT_KEY_PATH")
 	// jwtSecretPath := os.Getenv("JWT_SECRET_PATH")
-	// rpcPwd := os.Getenv("RPC_AUTH_PWD")
 
-	// Init mongo repo
-	mc, err := m.NewClient(mongoHost, mongoCheckIn, mongoPwd)
+	// Check out config
+	tentative, err := parseMinutes(cfg.TentativeMinutes, defaultTentativeMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing tentative checkout minutes: %v\n", err)
+	}
+	maxDwell, err := parseMinutes(cfg.MaxDwellMinutes, defaultMaxDwellMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing max dwell minutes: %v\n", err)
+	}
+	idempotencyWindow, err := parseMinutes(cfg.IdempotencyWindowMinutes, defaultIdempotencyWindowMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing idempotency window minutes: %v\n", err)
+	}
+	maxClockSkew, err := parseMinutes(cfg.SyncMaxClockSkewMinutes, defaultSyncMaxClockSkewMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing sync max clock skew minutes: %v\n", err)
+	}
+	historyWindow, err := parseMinutes(cfg.HistoryWindowMinutes, defaultHistoryWindowMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing history window minutes: %v\n", err)
+	}
+	maxHistoryWindow, err := parseMinutes(cfg.MaxHistoryWindowMinutes, defaultMaxHistoryWindowMinutes)
 	if err != nil {
-		log.Fatalf("Error starting mongo client: Error: %v\n", err)
+		log.Fatalf("Error parsing max history window minutes: %v\n", err)
+	}
+	if historyWindow > maxHistoryWindow {
+		log.Fatalf("Error history window of %s is longer than the max of %s\n", historyWindow, maxHistoryWindow)
 	}
-	repository := repo.NewMongoCheckInRepository(mc, mongoDBName)
 
-	// Init rpc client
-	rpcClient := chkRpc.NewRPCClient(placesHost, usersHost, rpcPwd)
+	// Retention config, keeping check ins forever by default
+	retention, err := parseDays(cfg.RetentionDays, defaultRetentionDays)
+	if err != nil || retention < 0 {
+		log.Fatalf("Error parsing retention days: %s %v\n", cfg.RetentionDays, err)
+	}
+
+	// Init repositories in the configured storage, mongo by default
+	var (
+		repository  repository
+		revocations auth.RevocationStore
+	)
+	switch cfg.Storage {
+	case memory.Storage:
+		repository = repo.NewMemoryCheckInRepository(cfg.MongoDBName, tentative)
+		revocations = authRepo.NewMemoryRevocationRepository(cfg.MongoDBName)
+	case postgres.Storage:
+		db, err := postgres.NewClient(cfg.PostgresURL)
+		if err != nil {
+			log.Fatalf("Error starting postgres client: Error: %v\n", err)
+		}
+		pgRepo := repo.NewPostgresCheckInRepository(db, tentative)
+		if err = pgRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating postgres schema: %v\n", err)
+		}
+		if err = authRepo.MigratePostgres(context.Background(), db); err != nil {
+			log.Fatalf("Error migrating postgres auth schema: %v\n", err)
+		}
+		repository = pgRepo
+		revocations = authRepo.NewPostgresRevocationRepository(db)
+	default:
+		mc, err := m.NewClient(cfg.MongoURI, cfg.MongoUser, cfg.MongoPwd)
+		if err != nil {
+			log.Fatalf("Error starting mongo client: Error: %v\n", err)
+		}
+		mongoRepo := repo.NewMongoCheckInRepository(mc, cfg.MongoDBName, tentative)
+		if err = mongoRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating mongo indexes: %v\n", err)
+		}
+		if err = authRepo.MigrateMongo(context.Background(), mc, cfg.MongoDBName); err != nil {
+			log.Fatalf("Error migrating mongo auth indexes: %v\n", err)
+		}
+		repository = mongoRepo
+		revocations = authRepo.NewMongoRevocationRepository(mc, cfg.MongoDBName)
+	}
 
 	// Init jwt service
-	jwtKey, err := ioutil.ReadFile(jwtKeyPath)
+	jwtKey, err := ioutil.ReadFile(cfg.JWTKeyPath)
 	if err != nil {
-		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", jwtKeyPath, err)
+		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", cfg.JWTKeyPath, err)
 	}
-	jwtSecret, err := ioutil.ReadFile(jwtSecretPath)
+	jwtSecret, err := ioutil.ReadFile(cfg.JWTSecretPath)
 	if err != nil {
-		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", jwtSecretPath, err)
+		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", cfg.JWTSecretPath, err)
 	}
 	j, err := auth.NewJWTService(auth.JWTServiceConfig{
-		Key:    jwtKey,
-		Secret: jwtSecret,
-		RPCPwd: rpcPwd,
+		Key:         jwtKey,
+		Secret:      jwtSecret,
+		Service:     auth.ServiceCheckIns,
+		Revocations: revocations,
 	})
 	if err != nil {
 		log.Fatalf("Error creating jwt service: %v\n", err)
 	}
 
+	// Init rpc client, signing its calls with service tokens
+	rpcClient := chkRpc.NewRPCClient(cfg.PlacesHost, cfg.UsersHost, cfg.CasesHost, j)
+
+	// Init risk scoring
+	riskRules, err := ParseRiskRules(cfg.RiskMinOverlap, cfg.RiskFullOverlap, cfg.RiskTentativeFactor, cfg.RiskUnknownPlaceFactor)
+	if err != nil {
+		log.Fatalf("Error parsing risk rules: %v\n", err)
+	}
+
 	// Init logger
 	// logger, _ := zap.NewProduction()
 
 	usecase := &Usecase{
-		Repository: repository,
-		RPC:        rpcClient,
+		Repository:        repository,
+		RPC:               rpcClient,
+		RiskRules:         riskRules,
+		MaxDwell:          maxDwell,
+		IdempotencyWindow: idempotencyWindow,
+		MaxClockSkew:      maxClockSkew,
+		HistoryWindow:     historyWindow,
+		MaxHistoryWindow:  maxHistoryWindow,
+		Retention:         retention,
 	}
 	return usecase, j, nil
 }
+
+const (
+	defaultTentativeMinutes         = 5
+	defaultMaxDwellMinutes          = 240
+	defaultIdempotencyWindowMinutes = 1440
+	defaultSyncMaxClockSkewMinutes  = 10
+	defaultHistoryWindowMinutes     = 1440
+	defaultMaxHistoryWindowMinutes  = 20160
+	defaultRetentionDays            = 0
+)
+
+// parseMinutes parses a config value in minutes, using def when empty
+func parseMinutes(val string, def int) (time.Duration, error) {
+	minutes := def
+	if val != "" {
+		var err error
+		if minutes, err = strconv.Atoi(val); err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// parseDays parses a config value in days, using def when empty
+func parseDays(val string, def int) (time.Duration, error) {
+	minutes, err := parseMinutes(val, def)
+	return minutes * 24 * 60, err
+}
+
+// isNotFound returns true if err is any storage's error for a record that
+// doesn't exist
+func isNotFound(err error) bool {
+	switch errors.Cause(err) {
+	case m.ErrNotFound, memory.ErrNotFound, sql.ErrNoRows:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package checkins
+
+import (
+	"context"
+	"log"
+	"time"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+
+	"github.com/pkg/errors"
+)
+
+// CloseStale automatically checks out every open check in that has been
+// open past its place's maximum dwell or closing time. Check ins closed
+// since they were fetched are skipped, and one failing to close is logged
+// so the rest are still closed.
+func (u *Usecase) CloseStale(ctx context.Context) ([]*t.CheckIn, error) {
+	now := time.Now()
+	stale, err := u.Repository.GetStale(ctx, now, now.Add(-u.MaxDwell))
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching stale check ins")
+	}
+
+	closed := []*t.CheckIn{}
+	for _, checkIn := range stale {
+		resp, err := u.Repository.AutoCheckOut(ctx, checkIn.ID, u.closeBy(checkIn))
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error closing stale check in %s: %v\n", checkIn.ID, err)
+			continue
+		}
+		closed = append(closed, resp)
+	}
+	return closed, nil
+}
+
+// closeBy returns when an open check in is considered stale. Check ins
+// created before places had dwell limits fall back to the default.
+func (u *Usecase) closeBy(checkIn *t.CheckIn) time.Time {
+	if checkIn.CloseBy != nil {
+		return *checkIn.CloseBy
+	}
+	return checkIn.In.Add(u.MaxDwell)
+}
+
+// RunSweeper closes stale check ins every interval until ctx is done
+func RunSweeper(ctx context.Context, svc CheckInService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			closed, err := svc.CloseStale(ctx)
+			if err != nil {
+				log.Printf("Error closing stale check ins: %v\n", err)
+			}
+			if len(closed) > 0 {
+				log.Printf("Automatically checked out %d stale check in(s)\n", len(closed))
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package checkins
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+	pT "github.com/contact-tracker/apiService/places/types"
+	uT "github.com/contact-tracker/apiService/users/types"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// syncStay - an offline check in and, if the batch has one, its check out
+type syncStay struct {
+	in, out *syncEvent
+}
+
+type syncEvent struct {
+	*t.SyncEvent
+	at     time.Time
+	key    string
+	result *t.SyncResult
+}
+
+func (e *syncEvent) reject(reason string, args ...interface{}) {
+	e.result.Accepted = false
+	e.result.Reason = fmt.Sprintf(reason, args...)
+}
+
+func (e *syncEvent) accept(checkInID string) {
+	e.result.Accepted = true
+	e.result.CheckInID = checkInID
+}
+
+// Sync merges a batch of check ins and outs recorded by a device while it
+// was offline. Each event is accepted or rejected on its own. Events already
+// synced by the same device are accepted again without being reapplied.
+func (u *Usecase) Sync(ctx context.Context, req *t.SyncCheckIns) (*t.SyncCheckInsResult, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
+	now := time.Now()
+	skew := now.Sub(*req.SentAt)
+	skewTooLarge := skew > u.MaxClockSkew || -skew > u.MaxClockSkew
+
+	events := make([]*syncEvent, len(req.Events))
+	pending := []*syncEvent{}
+	for i, event := range req.Events {
+		e := &syncEvent{SyncEvent: event, result: &t.SyncResult{}}
+		events[i] = e
+		if event == nil {
+			e.reject("event is empty")
+			continue
+		}
+		e.result.EventID = event.EventID
+		if err := validate.Struct(*event); err != nil {
+			e.reject("invalid event: %s", err.Error())
+			continue
+		}
+		if skewTooLarge {
+			e.reject("device clock is off by %s which is more than %s", skew.Round(time.Second), u.MaxClockSkew)
+			continue
+		}
+		e.at = event.At.Add(skew)
+		if e.at.After(now) {
+			e.reject("event is in the future")
+			continue
+		}
+		e.key = fmt.Sprintf("%s:%s", req.DeviceID, event.EventID)
+		prior, err := u.Repository.FindByIdempotencyKey(ctx, event.UserID, e.key, time.Time{})
+		if err == nil {
+			e.accept(prior.ID)
+			e.result.Duplicate = true
+			continue
+		}
+		if !isNotFound(err) {
+			e.reject("error finding prior sync: %s", err.Error())
+			continue
+		}
+		pending = append(pending, e)
+	}
+
+	// Pair each user's check ins with the check out that follows them
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].at.Before(pending[j].at) })
+	stays := []*syncStay{}
+	open := map[string]*syncStay{}
+	for _, e := range pending {
+		stay := open[e.UserID]
+		switch e.Type {
+		case t.SyncEventIn:
+			if stay != nil {
+				e.reject("user is already checked in by event %s", stay.in.EventID)
+				continue
+			}
+			stay = &syncStay{in: e}
+			open[e.UserID] = stay
+			stays = append(stays, stay)
+		case t.SyncEventOut:
+			if stay == nil {
+				stays = append(stays, &syncStay{out: e})
+				continue
+			}
+			if stay.in.PlaceID != e.PlaceID {
+				e.reject("user is checked in at a different place by event %s", stay.in.EventID)
+				continue
+			}
+			stay.out = e
+			delete(open, e.UserID)
+		}
+	}
+
+	places := map[string]*pT.Place{}
+	users := map[string]*uT.User{}
+	for _, stay := range stays {
+		if stay.in == nil {
+			u.syncCheckOut(ctx, req.DeviceID, stay.out)
+		} else {
+			u.syncStay(ctx, req.DeviceID, stay, places, users)
+		}
+	}
+
+	resp := &t.SyncCheckInsResult{Results: make([]*t.SyncResult, len(events))}
+	for i, e := range events {
+		resp.Results[i] = e.result
+		if e.result.Accepted {
+			resp.Accepted++
+		} else {
+			resp.Rejected++
+		}
+	}
+	return resp, nil
+}
+
+// syncStay creates a check in for an offline stay that does not overlap
+// any of the user's existing stays
+func (u *Usecase) syncStay(ctx context.Context, deviceID string, stay *syncStay, places map[string]*pT.Place, users map[string]*uT.User) {
+	reject := func(reason string, args ...interface{}) {
+		stay.in.reject(reason, args...)
+		if stay.out != nil {
+			stay.out.reject(reason, args...)
+		}
+	}
+
+	var end *time.Time
+	if stay.out != nil {
+		end = &stay.out.at
+	}
+	overlapping, err := u.Repository.GetOverlapping(ctx, stay.in.UserID, stay.in.at, end)
+	if err != nil {
+		reject("error checking for overlapping check ins: %s", err.Error())
+		return
+	}
+	if len(overlapping) > 0 {
+		reject("overlaps check in %s", overlapping[0].ID)
+		return
+	}
+
+	place, ok := places[stay.in.PlaceID]
+	if !ok {
+		if place, err = u.RPC.GetPlace(ctx, stay.in.PlaceID); err != nil {
+			reject("error getting place: %s", err.Error())
+			return
+		}
+		places[stay.in.PlaceID] = place
+	}
+	user, ok := users[stay.in.UserID]
+	if !ok {
+		if user, err = u.RPC.GetUser(ctx, stay.in.UserID); err != nil {
+			reject("error getting user: %s", err.Error())
+			return
+		}
+		users[stay.in.UserID] = user
+	}
+	if place.RequireConfirmed && !user.Confirmed {
+		reject(ErrUnconfirmed.Error())
+		return
+	}
+
+	in := stay.in.at
+	closeBy := place.CloseBy(in, u.MaxDwell)
+	checkIn := &t.CheckIn{
+		In:         &in,
+		CloseBy:    &closeBy,
+		PurgeAfter: place.PurgeAfter(in),
+		DeviceID:   deviceID,
+		InKey:      stay.in.key,
+		User: &t.User{
+			ID:   user.ID,
+			Name: user.Name,
+		},
+		Place: &t.Place{
+			ID:   place.ID,
+			Name: place.Name,
+		},
+	}
+	if stay.out != nil {
+		out := stay.out.at
+		checkIn.Out = &out
+		checkIn.OutKey = stay.out.key
+	}
+	resp, err := u.Repository.Create(ctx, checkIn)
+	if err != nil {
+		reject("error creating check in: %s", err.Error())
+		return
+	}
+	stay.in.accept(resp.ID)
+	if stay.out != nil {
+		stay.out.accept(resp.ID)
+	}
+}
+
+// syncCheckOut closes the user's open check in with an offline check out
+func (u *Usecase) syncCheckOut(ctx context.Context, deviceID string, e *syncEvent) {
+	open, err := u.Repository.LastCheckIn(ctx, e.UserID)
+	if isNotFound(err) {
+		e.reject("user is not checked in")
+		return
+	}
+	if err != nil {
+		e.reject("error getting open check in: %s", err.Error())
+		return
+	}
+	if open.Place == nil || open.Place.ID != e.PlaceID {
+		e.reject("user is checked in at a different place")
+		return
+	}
+	if open.In != nil && e.at.Before(*open.In) {
+		e.reject("check out is before check in %s", open.ID)
+		return
+	}
+	resp, err := u.Repository.CheckOut(ctx, open.ID, e.at, e.key)
+	if err != nil {
+		e.reject("error checking out: %s", err.Error())
+		return
+	}
+	e.accept(resp.ID)
+}
@@ -0,0 +1,70 @@
+package checkins
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	repo "github.com/contact-tracker/apiService/check-ins/repository"
+	t "github.com/contact-tracker/apiService/check-ins/types"
+)
+
+func TestSyncClockSkew(tt *testing.T) {
+	const maxSkew = 10 * time.Minute
+
+	tests := []struct {
+		name     string
+		skew     time.Duration
+		accepted bool
+	}{
+		{"in sync", 0, true},
+		{"device behind", -9 * time.Minute, true},
+		{"device ahead", 9 * time.Minute, true},
+		{"device too far behind", -11 * time.Minute, false},
+		{"device too far ahead", 11 * time.Minute, false},
+	}
+	for i, test := range tests {
+		repository := repo.NewMemoryCheckInRepository(fmt.Sprintf("sync-test-%d", i), time.Hour)
+		u := &Usecase{
+			Repository:   repository,
+			RPC:          &stubRPC{},
+			MaxDwell:     12 * time.Hour,
+			MaxClockSkew: maxSkew,
+		}
+		// The device's clock reads skew from the server's
+		sentAt := time.Now().Add(test.skew)
+		at := sentAt.Add(-time.Hour)
+		resp, err := u.Sync(context.Background(), &t.SyncCheckIns{
+			DeviceID: "device-1",
+			SentAt:   &sentAt,
+			Events: []*t.SyncEvent{
+				{EventID: "in-1", UserID: "user-1", PlaceID: "place-1", Type: t.SyncEventIn, At: &at},
+			},
+		})
+		if err != nil {
+			tt.Fatalf("%s: error syncing: %v", test.name, err)
+		}
+
+		result := resp.Results[0]
+		if result.Accepted != test.accepted {
+			tt.Errorf("%s: got accepted %t, want %t: %s", test.name, result.Accepted, test.accepted, result.Reason)
+			continue
+		}
+		if !test.accepted {
+			if !strings.Contains(result.Reason, "clock") {
+				tt.Errorf("%s: got reason %q, want the device clock", test.name, result.Reason)
+			}
+			continue
+		}
+		checkIn, err := repository.Get(context.Background(), result.CheckInID)
+		if err != nil {
+			tt.Fatalf("%s: error getting check in: %v", test.name, err)
+		}
+		// Stored in server time, an hour before the batch was sent
+		if d := time.Since(*checkIn.In) - time.Hour; d < 0 || d > time.Minute {
+			tt.Errorf("%s: check in at %s is not corrected for the skew", test.name, checkIn.In)
+		}
+	}
+}
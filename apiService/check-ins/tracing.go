@@ -0,0 +1,84 @@
+package checkins
+
+import (
+	"context"
+	"time"
+
+	t "github.com/contact-tracker/apiService/check-ins/types"
+
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type traceStep struct {
+	node       *t.ContactTrace
+	start, end time.Time
+}
+
+// TraceContacts walks the contact graph outwards from an index user up to
+// req.Depth degrees. Each person appears once in the resulting tree, under
+// the contact they were first linked through.
+func (u *Usecase) TraceContacts(ctx context.Context, req *t.TraceContacts) (*t.ContactTrace, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	if !req.Start.Before(*req.End) {
+		return nil, errors.New("error trace start must be before end")
+	}
+
+	hopOffset := req.End.Sub(*req.Start)
+	if req.HopOffsetMinutes > 0 {
+		hopOffset = time.Duration(req.HopOffsetMinutes) * time.Minute
+	}
+
+	root := &t.ContactTrace{User: &t.User{ID: req.UserID}, Contacts: []*t.ContactTrace{}}
+	visited := map[string]bool{req.UserID: true}
+	queue := []*traceStep{{node: root, start: *req.Start, end: *req.End}}
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		if step.node.Degree >= req.Depth {
+			continue
+		}
+
+		userID := step.node.User.ID
+		histories, err := u.GetHistory(ctx, &t.GetHistory{
+			UserID:       &userID,
+			Start:        &step.start,
+			End:          &step.end,
+			MinRiskScore: req.MinRiskScore,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching history for contact trace")
+		}
+		for _, history := range histories {
+			if step.node.Degree == 0 && history.User != nil {
+				root.User = history.User
+			}
+			for _, contact := range history.Contacts {
+				if contact.User == nil || visited[contact.User.ID] {
+					continue
+				}
+				visited[contact.User.ID] = true
+
+				exposedAt := *history.In
+				if contact.In != nil && contact.In.After(exposedAt) {
+					exposedAt = *contact.In
+				}
+				child := &t.ContactTrace{
+					User:      contact.User,
+					Degree:    step.node.Degree + 1,
+					Place:     history.Place,
+					ExposedAt: &exposedAt,
+					RiskScore: contact.RiskScore,
+					Contacts:  []*t.ContactTrace{},
+				}
+				step.node.Contacts = append(step.node.Contacts, child)
+				queue = append(queue, &traceStep{node: child, start: exposedAt, end: exposedAt.Add(hopOffset)})
+			}
+		}
+	}
+	return root, nil
+}
@@ -2,16 +2,116 @@ package types
 
 import (
 	"time"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+)
+
+// CheckInSorts - fields check ins may be sorted by, the first by default
+var CheckInSorts = []string{"-in"}
+
+// Statuses of check ins listed by GetCheckIns
+const (
+	StatusOpen   = "open"
+	StatusClosed = "closed"
 )
 
+// DefaultBounds - Start is included and End excluded unless otherwise requested
+const DefaultBounds = "[)"
+
+// GetCheckIns - filters and page of a list of check ins. Check ins match
+// when their stay overlaps Start to End, with open check ins lasting until
+// now and beyond, so stays which began before Start are included.
 type GetCheckIns struct {
-	UserID *string    `bson:"userId" json:"userId"`
-	Start  *time.Time `bson:"start" json:"start"`
-	End    *time.Time `bson:"end" json:"end"`
+	api.PageRequest
+	UserID  *string    `bson:"userId" json:"userId"`
+	PlaceID *string    `bson:"placeId" json:"placeId"`
+	Start   *time.Time `bson:"start" json:"start"`
+	End     *time.Time `bson:"end" json:"end"`
+	// Bounds includes Start with "[" or excludes it with "(", and includes
+	// End with "]" or excludes it with ")", as postgres ranges do
+	Bounds string `bson:"bounds" json:"bounds" validate:"omitempty,oneof=[] [) (] ()"`
+	// Status is StatusOpen or StatusClosed, or empty for both
+	Status string `bson:"status" json:"status" validate:"omitempty,oneof=open closed"`
+}
+
+// IncludesStart returns true if check ins ending at Start match
+func (g GetCheckIns) IncludesStart() bool {
+	return g.Bounds == "" || g.Bounds[0] == '['
+}
+
+// IncludesEnd returns true if check ins starting at End match
+func (g GetCheckIns) IncludesEnd() bool {
+	return g.Bounds != "" && g.Bounds[1] == ']'
+}
+
+// Empty returns true if no stay can overlap Start to End
+func (g GetCheckIns) Empty() bool {
+	if g.Start == nil || g.End == nil {
+		return false
+	}
+	if g.Start.Equal(*g.End) {
+		return !g.IncludesStart() || !g.IncludesEnd()
+	}
+	return g.Start.After(*g.End)
+}
+
+// Matches returns true if the check in's stay overlaps Start to End and it
+// has the requested status
+func (g GetCheckIns) Matches(check *CheckIn) bool {
+	if check.In == nil {
+		return false
+	}
+	if g.Status == StatusOpen && check.Out != nil || g.Status == StatusClosed && check.Out == nil {
+		return false
+	}
+	if g.End != nil && (check.In.After(*g.End) || !g.IncludesEnd() && check.In.Equal(*g.End)) {
+		return false
+	}
+	if g.Start != nil && check.Out != nil && (check.Out.Before(*g.Start) || !g.IncludesStart() && check.Out.Equal(*g.Start)) {
+		return false
+	}
+	return true
+}
+
+// CheckInPage - a page of check ins
+type CheckInPage struct {
+	Items []*CheckIn `json:"items"`
+	api.PageInfo
+}
+
+type GetHistory struct {
+	UserID       *string    `bson:"userId" json:"userId"`
+	PlaceID      *string    `bson:"placeId" json:"placeId"`
+	Start        *time.Time `bson:"start" json:"start"`
+	End          *time.Time `bson:"end" json:"end"`
+	MinRiskScore float64    `bson:"minRiskScore" json:"minRiskScore" validate:"gte=0,lte=1"`
+}
+
+// GetPlaceHistory - query of the history endpoint, for a window of a place's
+// check ins. The window is Start to End, or the Since duration up to End,
+// such as "90m", "12h" or "7d". End defaults to now, and the window to the
+// configured default.
+type GetPlaceHistory struct {
+	PlaceID      string     `bson:"placeId" json:"-" validate:"required"`
+	Start        *time.Time `bson:"start" json:"start"`
+	End          *time.Time `bson:"end" json:"end"`
+	Since        string     `bson:"since" json:"since"`
+	MinRiskScore float64    `bson:"minRiskScore" json:"minRiskScore" validate:"gte=0,lte=1"`
 }
 
+// IdempotencyKeyHeader - header clients set so retried requests are only applied once
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type CreateCheckIn struct {
-	UserID string `bson:"userId" json:"userId" validate:"required"`
+	UserID         string `bson:"userId" json:"userId" validate:"required"`
+	PlaceID        string `bson:"placeId" json:"placeId" validate:"required"`
+	IdempotencyKey string `bson:"-" json:"-" validate:"lte=255"`
+}
+
+type CheckOut struct {
+	UserID         string `bson:"userId" json:"userId" validate:"required"`
+	PlaceID        string `bson:"placeId" json:"placeId"`
+	IdempotencyKey string `bson:"-" json:"-" validate:"lte=255"`
 }
 
 type CheckInHistory struct {
@@ -19,8 +119,10 @@ type CheckInHistory struct {
 	In                *time.Time `bson:"in" json:"in"`
 	Out               *time.Time `bson:"out" json:"out"`
 	User              *User      `bson:"user" json:"user"`
-	Contacts          []*CheckIn `bson:"contacts" json:"contacts"`
+	Place             *Place     `bson:"place" json:"place"`
+	Contacts          []*Contact `bson:"contacts" json:"contacts"`
 	TentativeCheckout bool       `bson:"tentative,omitempty" json:"tentativeCheckout,omitempty"`
+	AutoCheckout      bool       `bson:"autoOut,omitempty" json:"autoCheckout,omitempty"`
 }
 
 // CheckIn - check in and out
@@ -29,7 +131,49 @@ type CheckIn struct {
 	In                *time.Time `bson:"in" json:"in" validate:"required"`
 	Out               *time.Time `bson:"out" json:"out"`
 	User              *User      `bson:"user" json:"user" validate:"required"`
+	Place             *Place     `bson:"place" json:"place" validate:"required"`
+	CloseBy           *time.Time `bson:"closeBy,omitempty" json:"closeBy,omitempty"`
+	PurgeAfter        *time.Time `bson:"purgeAfter,omitempty" json:"purgeAfter,omitempty"`
 	TentativeCheckout bool       `bson:"tentative,omitempty" json:"tentativeCheckout,omitempty"`
+	AutoCheckout      bool       `bson:"autoOut,omitempty" json:"autoCheckout,omitempty"`
+	DeviceID          string     `bson:"device,omitempty" json:"deviceId,omitempty"`
+	InKey             string     `bson:"inKey,omitempty" json:"-"`
+	OutKey            string     `bson:"outKey,omitempty" json:"-"`
+}
+
+// SortValue returns the value of one of CheckInSorts
+func (c CheckIn) SortValue(field string) string {
+	return api.TimeValue(c.In)
+}
+
+// AsCheckedIn returns the check in as it was before being checked out
+func (c CheckIn) AsCheckedIn() *CheckIn {
+	c.Out = nil
+	c.AutoCheckout = false
+	c.OutKey = ""
+	return &c
+}
+
+// PurgeReport - check ins purged past their retention, or that would be
+// on a dry run
+type PurgeReport struct {
+	DryRun bool `json:"dryRun"`
+	// Before is the deployment's retention cutoff, nil when check ins are
+	// kept unless their place has its own retention
+	Before *time.Time `json:"before,omitempty"`
+	Purged int        `json:"purged"`
+	// Exempt check ins are past their retention but kept, as cases still
+	// being investigated may need them
+	Exempt int `json:"exempt"`
+	// Places counts the purged check ins by place id
+	Places map[string]int `json:"places"`
+}
+
+// Contact - another check in overlapping a check in history and its exposure risk
+type Contact struct {
+	CheckIn        `bson:",inline"`
+	OverlapMinutes float64 `bson:"overlapMinutes" json:"overlapMinutes"`
+	RiskScore      float64 `bson:"riskScore" json:"riskScore"`
 }
 
 // Place - place from user view
@@ -43,3 +187,77 @@ type User struct {
 	ID   string `bson:"id" json:"id"`
 	Name string `bson:"nm" json:"name"`
 }
+
+// TraceContacts - request to trace an index user's contacts to a given depth
+type TraceContacts struct {
+	UserID string     `bson:"userId" json:"userId" validate:"required"`
+	Start  *time.Time `bson:"start" json:"start" validate:"required"`
+	End    *time.Time `bson:"end" json:"end" validate:"required"`
+	Depth  int        `bson:"depth" json:"depth" validate:"gte=1,lte=5"`
+	// MinRiskScore skips contacts who were not meaningfully exposed
+	MinRiskScore float64 `bson:"minRiskScore" json:"minRiskScore" validate:"gte=0,lte=1"`
+	// HopOffsetMinutes is how long after being exposed a contact's own
+	// contacts are searched for. Defaults to the length of the index window.
+	HopOffsetMinutes int `bson:"hopOffsetMinutes" json:"hopOffsetMinutes" validate:"gte=0"`
+}
+
+// ContactTrace - a person in a contact trace and how they are linked to the index user
+type ContactTrace struct {
+	User      *User           `bson:"user" json:"user"`
+	Degree    int             `bson:"degree" json:"degree"`
+	Place     *Place          `bson:"place,omitempty" json:"place,omitempty"`
+	ExposedAt *time.Time      `bson:"exposedAt,omitempty" json:"exposedAt,omitempty"`
+	RiskScore float64         `bson:"riskScore" json:"riskScore"`
+	Contacts  []*ContactTrace `bson:"contacts" json:"contacts"`
+}
+
+// UserIDs returns the ids of everyone traced below this node
+func (c ContactTrace) UserIDs() (ids []string) {
+	ids = []string{}
+	for _, contact := range c.Contacts {
+		ids = append(ids, contact.User.ID)
+		ids = append(ids, contact.UserIDs()...)
+	}
+	return
+}
+
+// SyncEventType - whether an offline event checked a user in or out
+type SyncEventType string
+
+const (
+	SyncEventIn  SyncEventType = "in"
+	SyncEventOut SyncEventType = "out"
+)
+
+// SyncEvent - a check in or out recorded by a device while offline
+type SyncEvent struct {
+	EventID string        `json:"eventId" validate:"required,lte=255"`
+	UserID  string        `json:"userId" validate:"required"`
+	PlaceID string        `json:"placeId" validate:"required"`
+	Type    SyncEventType `json:"type" validate:"required,oneof=in out"`
+	At      *time.Time    `json:"at" validate:"required"`
+}
+
+// SyncCheckIns - a batch of offline events from a single device. SentAt is
+// the device's clock when sending and is used to correct for clock skew.
+type SyncCheckIns struct {
+	DeviceID string       `json:"deviceId" validate:"required,lte=255"`
+	SentAt   *time.Time   `json:"sentAt" validate:"required"`
+	Events   []*SyncEvent `json:"events" validate:"required,gte=1,lte=500"`
+}
+
+// SyncResult - whether a single offline event was accepted
+type SyncResult struct {
+	EventID   string `json:"eventId"`
+	Accepted  bool   `json:"accepted"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	CheckInID string `json:"checkInId,omitempty"`
+}
+
+// SyncCheckInsResult - results for each event in a batch in request order
+type SyncCheckInsResult struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []*SyncResult `json:"results"`
+}
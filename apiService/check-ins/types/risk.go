@@ -0,0 +1,65 @@
+package types
+
+import (
+	"math"
+	"time"
+)
+
+// RiskRules - configures how exposure risk is scored between two check ins
+type RiskRules struct {
+	// MinOverlapMinutes is the shortest overlap that counts as an exposure
+	MinOverlapMinutes float64 `json:"minOverlapMinutes"`
+	// FullRiskMinutes is the overlap at which the score reaches 1
+	FullRiskMinutes float64 `json:"fullRiskMinutes"`
+	// TentativeFactor scales the score when either checkout was not
+	// confirmed by the user
+	TentativeFactor float64 `json:"tentativeFactor"`
+	// UnknownPlaceFactor scales the score when the check ins can not be
+	// confirmed to be at the same place
+	UnknownPlaceFactor float64 `json:"unknownPlaceFactor"`
+}
+
+// DefaultRiskRules - rules used when none are configured
+var DefaultRiskRules = RiskRules{
+	MinOverlapMinutes:  15,
+	FullRiskMinutes:    60,
+	TentativeFactor:    0.5,
+	UnknownPlaceFactor: 0.5,
+}
+
+// Overlap returns how long two check ins overlapped
+func Overlap(a, b *CheckIn) time.Duration {
+	if a.In == nil || a.Out == nil || b.In == nil || b.Out == nil {
+		return 0
+	}
+	start, end := *a.In, *a.Out
+	if b.In.After(start) {
+		start = *b.In
+	}
+	if b.Out.Before(end) {
+		end = *b.Out
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Score returns an exposure risk between 0 and 1
+func (r RiskRules) Score(overlap time.Duration, tentative, samePlace bool) float64 {
+	minutes := overlap.Minutes()
+	if minutes < r.MinOverlapMinutes || minutes <= 0 {
+		return 0
+	}
+	score := 1.0
+	if r.FullRiskMinutes > 0 {
+		score = math.Min(minutes/r.FullRiskMinutes, 1)
+	}
+	if tentative {
+		score *= r.TentativeFactor
+	}
+	if !samePlace {
+		score *= r.UnknownPlaceFactor
+	}
+	return score
+}
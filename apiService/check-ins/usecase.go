@@ -2,9 +2,14 @@ package checkins
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"time"
 
+	caseT "github.com/contact-tracker/apiService/cases/types"
 	t "github.com/contact-tracker/apiService/check-ins/types"
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
 	pT "github.com/contact-tracker/apiService/places/types"
 	uT "github.com/contact-tracker/apiService/users/types"
 
@@ -15,27 +20,62 @@ import (
 
 var (
 	validate *validator.Validate
+
+	// ErrAlreadyCheckedIn - user has an open check in
+	ErrAlreadyCheckedIn = errors.New("user is already checked in")
+	// ErrNotCheckedIn - user has no open check in
+	ErrNotCheckedIn = errors.New("user is not checked in")
+	// ErrUnconfirmed - the place only checks in users who confirmed their email
+	ErrUnconfirmed = errors.New("user has not confirmed their email")
+	// ErrInvalidWindow - a history window is malformed or longer than allowed
+	ErrInvalidWindow = errors.New("invalid history window")
 )
 
 type repository interface {
 	Get(ctx context.Context, id string) (*t.CheckIn, error)
-	GetHistory(_ context.Context, userID string, start, end *time.Time) ([]*t.CheckInHistory, error)
-	GetAll(ctx context.Context, userID *string, start, end *time.Time) ([]*t.CheckIn, error)
+	GetHistory(ctx context.Context, req *t.GetHistory) ([]*t.CheckInHistory, error)
+	GetAll(ctx context.Context, req *t.GetCheckIns, page *api.PageQuery) ([]*t.CheckIn, error)
 	LastCheckIn(ctx context.Context, userID string) (*t.CheckIn, error)
 	Create(ctx context.Context, checkIn *t.CheckIn) (*t.CheckIn, error)
-	CheckOut(ctx context.Context, id string) (*t.CheckIn, error)
+	CheckOut(ctx context.Context, id string, out time.Time, idempotencyKey string) (*t.CheckIn, error)
+	GetOverlapping(ctx context.Context, userID string, start time.Time, end *time.Time) ([]*t.CheckIn, error)
+	FindByIdempotencyKey(ctx context.Context, userID, key string, since time.Time) (*t.CheckIn, error)
+	GetStale(ctx context.Context, now, legacyBefore time.Time) ([]*t.CheckIn, error)
+	AutoCheckOut(ctx context.Context, id string, out time.Time) (*t.CheckIn, error)
+	GetExpired(ctx context.Context, now, before time.Time, afterID string, limit int) ([]*t.CheckIn, error)
+	DeleteAll(ctx context.Context, ids []string) (int, error)
 	Delete(ctx context.Context, id string) error
 }
 
 type rpc interface {
 	GetPlace(ctx context.Context, id string) (*pT.Place, error)
 	GetUser(ctx context.Context, id string) (*uT.User, error)
+	GetOpenCases(ctx context.Context) ([]*caseT.Case, error)
 }
 
 // Usecase for interacting with users
 type Usecase struct {
 	Repository repository
 	RPC        rpc
+	RiskRules  t.RiskRules
+	// MaxDwell is how long a check in may stay open when its place
+	// does not set its own maximum
+	MaxDwell time.Duration
+	// IdempotencyWindow is how long a retried request with the same
+	// idempotency key returns the original response
+	IdempotencyWindow time.Duration
+	// MaxClockSkew is how far a syncing device's clock may drift from
+	// the server's before its batch is rejected
+	MaxClockSkew time.Duration
+	// HistoryWindow is how far back a place's history goes when the
+	// request doesn't say
+	HistoryWindow time.Duration
+	// MaxHistoryWindow is the longest window of a place's history that
+	// may be requested
+	MaxHistoryWindow time.Duration
+	// Retention is how long check ins are kept before being purged, or
+	// zero to keep them unless their place has its own retention
+	Retention time.Duration
 }
 
 // Get a single check ins
@@ -47,60 +87,275 @@ func (u *Usecase) Get(ctx context.Context, id string) (*t.CheckIn, error) {
 	return checkIn, nil
 }
 
-// GetHistory gets a place's check in history and contacts
-func (u *Usecase) GetHistory(ctx context.Context, placeID string, start, end *time.Time) (history []*t.CheckInHistory, err error) {
+// GetHistory gets a user's or place's check in history and contacts
+func (u *Usecase) GetHistory(ctx context.Context, req *t.GetHistory) (history []*t.CheckInHistory, err error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
 	history = []*t.CheckInHistory{}
-	if history, err = u.Repository.GetHistory(ctx, placeID, start, end); err != nil {
+	if history, err = u.Repository.GetHistory(ctx, req); err != nil {
 		return nil, errors.Wrap(err, "error fetching check ins")
 	}
+	u.scoreContacts(history, req.MinRiskScore)
 	return
 }
 
-// GetAll gets all check ins
-func (u *Usecase) GetAll(ctx context.Context, req *t.GetCheckIns) ([]*t.CheckIn, error) {
+// GetPlaceHistory gets a place's check in history and contacts over the
+// requested window
+func (u *Usecase) GetPlaceHistory(ctx context.Context, req *t.GetPlaceHistory) ([]*t.CheckInHistory, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	start, end, err := u.historyWindow(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return u.GetHistory(ctx, &t.GetHistory{
+		PlaceID:      &req.PlaceID,
+		Start:        &start,
+		End:          &end,
+		MinRiskScore: req.MinRiskScore,
+	})
+}
+
+// historyWindow returns the start and end of a place history request,
+// defaulting to the HistoryWindow up to now
+func (u *Usecase) historyWindow(req *t.GetPlaceHistory, now time.Time) (start, end time.Time, err error) {
+	end = now
+	if req.End != nil {
+		end = *req.End
+	}
+	switch {
+	case req.Start != nil && req.Since != "":
+		return start, end, errors.Wrap(ErrInvalidWindow, "start and since can't both be set")
+	case req.Start != nil:
+		start = *req.Start
+	case req.Since != "":
+		since, err := parseSince(req.Since)
+		if err != nil {
+			return start, end, err
+		}
+		start = end.Add(-since)
+	default:
+		start = end.Add(-u.HistoryWindow)
+	}
+
+	if end.Before(start) {
+		return start, end, errors.Wrap(ErrInvalidWindow, "end is before start")
+	}
+	if end.Sub(start) > u.MaxHistoryWindow {
+		return start, end, errors.Wrapf(ErrInvalidWindow, "window is longer than %s", u.MaxHistoryWindow)
+	}
+	return start, end, nil
+}
+
+// parseSince parses a positive duration such as "90m" or "12h", or a
+// number of days such as "7d"
+func parseSince(since string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	if strings.HasSuffix(since, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(since, "d"))
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(since)
+	}
+	if err != nil || d <= 0 {
+		return 0, errors.Wrapf(ErrInvalidWindow, "can't parse since %q", since)
+	}
+	return d, nil
+}
+
+// GetAll gets a page of check ins
+func (u *Usecase) GetAll(ctx context.Context, req *t.GetCheckIns) (*t.CheckInPage, error) {
 	validate = validator.New()
 	if err := validate.Struct(*req); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
 		return nil, validationErrors
 	}
+	page, err := req.Query(t.CheckInSorts...)
+	if err != nil {
+		return nil, err
+	}
+	if req.Empty() {
+		return &t.CheckInPage{Items: []*t.CheckIn{}, PageInfo: api.PageInfo{Limit: page.Limit}}, nil
+	}
 
-	checkIns, err := u.Repository.GetAll(ctx, req.UserID, req.Start, req.End)
+	checkIns, err := u.Repository.GetAll(ctx, req, page)
 	if err != nil {
 		return nil, errors.Wrap(err, "error fetching check ins")
 	}
-	return checkIns, nil
+	resp := &t.CheckInPage{Items: checkIns, PageInfo: api.PageInfo{Limit: page.Limit}}
+	if len(checkIns) > page.Limit {
+		resp.Items = checkIns[:page.Limit]
+		last := resp.Items[page.Limit-1]
+		resp.NextCursor = page.NextCursor(last.SortValue(page.Field), last.ID)
+	}
+	return resp, nil
 }
 
-// CheckIn or CheckOut based on user and place ID
-func (u *Usecase) CheckIn(ctx context.Context, req *t.CreateCheckIn) (resp *t.CheckIn, err error) {
+// Toggle checks a user in or out based on user and place ID. An open check
+// in at a different place is checked out before checking in at the new place.
+func (u *Usecase) Toggle(ctx context.Context, req *t.CreateCheckIn) (resp *t.CheckIn, err error) {
 	validate = validator.New()
 	if err := validate.Struct(*req); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
 		return nil, validationErrors
 	}
 
-	var checkIn *t.CheckIn
-	if last, err := u.Repository.LastCheckIn(ctx, req.UserID); err == nil {
-		resp, err = u.Repository.CheckOut(ctx, last.ID)
-		if err != nil {
-			return nil, err
+	now := time.Now()
+	if prior, err := u.priorResponse(ctx, req.UserID, req.IdempotencyKey, now); prior != nil || err != nil {
+		return prior, err
+	}
+	open, err := u.openCheckIn(ctx, req.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		if open.Place == nil || open.Place.ID == req.PlaceID {
+			return u.Repository.CheckOut(ctx, open.ID, now, req.IdempotencyKey)
 		}
-	} else {
-		user, err := u.RPC.GetUser(ctx, req.UserID)
-		if err != nil {
+		if _, err = u.Repository.CheckOut(ctx, open.ID, now, ""); err != nil {
 			return nil, err
 		}
-		now := time.Now()
-		checkIn = &t.CheckIn{
-			In: &now,
-			User: &t.User{
-				ID:   user.ID,
-				Name: user.Name,
-			},
+	}
+	return u.create(ctx, req, now)
+}
+
+// CheckIn a user at a place. Fails if the user is already checked in.
+func (u *Usecase) CheckIn(ctx context.Context, req *t.CreateCheckIn) (*t.CheckIn, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
+	now := time.Now()
+	if prior, err := u.priorResponse(ctx, req.UserID, req.IdempotencyKey, now); prior != nil || err != nil {
+		return prior, err
+	}
+	open, err := u.openCheckIn(ctx, req.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		if open.Place != nil {
+			return nil, errors.Wrapf(ErrAlreadyCheckedIn, "user is checked in at %s", open.Place.Name)
+		}
+		return nil, ErrAlreadyCheckedIn
+	}
+	return u.create(ctx, req, now)
+}
+
+// CheckOut a user. Fails if the user is not checked in, or is checked in
+// at a different place than the one given.
+func (u *Usecase) CheckOut(ctx context.Context, req *t.CheckOut) (*t.CheckIn, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
+	now := time.Now()
+	if prior, err := u.priorResponse(ctx, req.UserID, req.IdempotencyKey, now); prior != nil || err != nil {
+		return prior, err
+	}
+	open, err := u.openCheckIn(ctx, req.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil {
+		return nil, ErrNotCheckedIn
+	}
+	if req.PlaceID != "" && (open.Place == nil || open.Place.ID != req.PlaceID) {
+		return nil, errors.Wrap(ErrNotCheckedIn, "user is checked in at a different place")
+	}
+	return u.Repository.CheckOut(ctx, open.ID, now, req.IdempotencyKey)
+}
+
+// priorResponse returns the check in as it was returned to an earlier
+// request with the same idempotency key, or nil if there was none
+func (u *Usecase) priorResponse(ctx context.Context, userID, key string, now time.Time) (*t.CheckIn, error) {
+	if key == "" {
+		return nil, nil
+	}
+	prior, err := u.Repository.FindByIdempotencyKey(ctx, userID, key, now.Add(-u.IdempotencyWindow))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding prior request")
+	}
+	if prior.InKey == key && prior.OutKey != key {
+		return prior.AsCheckedIn(), nil
+	}
+	return prior, nil
+}
+
+// openCheckIn returns a user's open check in, or nil if they are not checked
+// in. A stale one the user forgot to check out of is closed automatically.
+func (u *Usecase) openCheckIn(ctx context.Context, userID string, now time.Time) (*t.CheckIn, error) {
+	last, err := u.Repository.LastCheckIn(ctx, userID)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting open check in")
+	}
+	if closeBy := u.closeBy(last); now.After(closeBy) {
+		if _, err = u.Repository.AutoCheckOut(ctx, last.ID, closeBy); err != nil {
+			return nil, errors.Wrap(err, "error closing stale check in")
 		}
-		resp, err = u.Repository.Create(ctx, checkIn)
+		return nil, nil
 	}
-	return
+	return last, nil
+}
+
+func (u *Usecase) create(ctx context.Context, req *t.CreateCheckIn, now time.Time) (*t.CheckIn, error) {
+	place, err := u.RPC.GetPlace(ctx, req.PlaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting place for check in")
+	}
+	user, err := u.RPC.GetUser(ctx, req.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting user for check in")
+	}
+	if place.RequireConfirmed && !isConfirmed(ctx, user) {
+		return nil, ErrUnconfirmed
+	}
+	closeBy := place.CloseBy(now, u.MaxDwell)
+	checkIn := &t.CheckIn{
+		In:         &now,
+		CloseBy:    &closeBy,
+		PurgeAfter: place.PurgeAfter(now),
+		InKey:      req.IdempotencyKey,
+		User: &t.User{
+			ID:   user.ID,
+			Name: user.Name,
+		},
+		Place: &t.Place{
+			ID:   place.ID,
+			Name: place.Name,
+		},
+	}
+	return u.Repository.Create(ctx, checkIn)
+}
+
+// isConfirmed reports whether the user has confirmed their email, trusting
+// the caller's claims when users check themselves in
+func isConfirmed(ctx context.Context, user *uT.User) bool {
+	if _, claims := auth.ClaimsFromContext(ctx); claims != nil && claims.Subject == user.ID {
+		return claims.IsConfirmed
+	}
+	return user.Confirmed
 }
 
 func (u *Usecase) newID() string {
@@ -0,0 +1,70 @@
+package checkins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	caseT "github.com/contact-tracker/apiService/cases/types"
+	t "github.com/contact-tracker/apiService/check-ins/types"
+	pT "github.com/contact-tracker/apiService/places/types"
+	uT "github.com/contact-tracker/apiService/users/types"
+
+	"github.com/pkg/errors"
+)
+
+// stubRPC - places and users that exist for any id, and the open cases
+type stubRPC struct {
+	cases []*caseT.Case
+}
+
+func (s *stubRPC) GetPlace(_ context.Context, id string) (*pT.Place, error) {
+	return &pT.Place{ID: id, Name: id}, nil
+}
+
+func (s *stubRPC) GetUser(_ context.Context, id string) (*uT.User, error) {
+	return &uT.User{ID: id, Name: id}, nil
+}
+
+func (s *stubRPC) GetOpenCases(_ context.Context) ([]*caseT.Case, error) {
+	return s.cases, nil
+}
+
+func TestHistoryWindow(tt *testing.T) {
+	u := &Usecase{HistoryWindow: 14 * 24 * time.Hour, MaxHistoryWindow: 21 * 24 * time.Hour}
+	now := time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		day := now.AddDate(0, 0, -days)
+		return &day
+	}
+
+	tests := []struct {
+		name  string
+		req   *t.GetPlaceHistory
+		start time.Time
+		end   time.Time
+		err   error
+	}{
+		{"default window", &t.GetPlaceHistory{}, *at(14), now, nil},
+		{"start and end", &t.GetPlaceHistory{Start: at(10), End: at(3)}, *at(10), *at(3), nil},
+		{"since hours", &t.GetPlaceHistory{Since: "36h"}, now.Add(-36 * time.Hour), now, nil},
+		{"since days before end", &t.GetPlaceHistory{Since: "7d", End: at(1)}, *at(8), *at(1), nil},
+		{"longest window", &t.GetPlaceHistory{Since: "21d"}, *at(21), now, nil},
+		{"longer than the max", &t.GetPlaceHistory{Since: "22d"}, time.Time{}, time.Time{}, ErrInvalidWindow},
+		{"start longer than the max", &t.GetPlaceHistory{Start: at(30)}, time.Time{}, time.Time{}, ErrInvalidWindow},
+		{"end before start", &t.GetPlaceHistory{Start: at(3), End: at(5)}, time.Time{}, time.Time{}, ErrInvalidWindow},
+		{"start and since", &t.GetPlaceHistory{Start: at(3), Since: "1d"}, time.Time{}, time.Time{}, ErrInvalidWindow},
+		{"negative since", &t.GetPlaceHistory{Since: "-1d"}, time.Time{}, time.Time{}, ErrInvalidWindow},
+		{"malformed since", &t.GetPlaceHistory{Since: "week"}, time.Time{}, time.Time{}, ErrInvalidWindow},
+	}
+	for _, test := range tests {
+		start, end, err := u.historyWindow(test.req, now)
+		if errors.Cause(err) != test.err {
+			tt.Errorf("%s: got %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err == nil && (!start.Equal(test.start) || !end.Equal(test.end)) {
+			tt.Errorf("%s: got %s to %s, want %s to %s", test.name, start, end, test.start, test.end)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Command migrate applies the pending storage migrations of every service
+// using the same config as cmd/server, ie.
+//
+//	go run ./cmd/migrate -config cmd/server/config.dev.yml -status
+//
+// Services also apply their migrations when they start.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	casesRepo "github.com/contact-tracker/apiService/cases/repository"
+	checkInsRepo "github.com/contact-tracker/apiService/check-ins/repository"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/contact-tracker/apiService/pkg/memory"
+	m "github.com/contact-tracker/apiService/pkg/mongo"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+	placesRepo "github.com/contact-tracker/apiService/places/repository"
+	usersRepo "github.com/contact-tracker/apiService/users/repository"
+)
+
+// service - the migrations of a service, and the prefix of its config
+type service struct {
+	name     string
+	prefix   string
+	mongo    []m.Migration
+	postgres []pg.Migration
+}
+
+var services = []service{
+	{"check-ins", "CHECK_INS", checkInsRepo.MongoMigrations, checkInsRepo.PostgresMigrations},
+	{"places", "PLACES", placesRepo.MongoMigrations, placesRepo.PostgresMigrations},
+	{"users", "USERS", usersRepo.MongoMigrations, usersRepo.PostgresMigrations},
+	{"cases", "CASES", casesRepo.MongoMigrations, casesRepo.PostgresMigrations},
+}
+
+func main() {
+	cfgPath := flag.String("config", "config.dev.yml", "path for yaml config")
+	status := flag.Bool("status", false, "list pending migrations without applying them")
+	flag.Parse()
+	godotenv.Load(*cfgPath)
+
+	storage := os.Getenv("STORAGE")
+	if storage == memory.Storage {
+		fmt.Println("memory storage has nothing to migrate")
+		return
+	}
+
+	ctx := context.Background()
+	for _, svc := range services {
+		var (
+			pending []string
+			err     error
+		)
+		if storage == pg.Storage {
+			pending, err = migratePostgres(ctx, svc, !*status)
+		} else {
+			pending, err = migrateMongo(ctx, svc, !*status)
+		}
+		if err != nil {
+			log.Fatalf("Error migrating %s: %v\n", svc.name, err)
+		}
+
+		if len(pending) == 0 {
+			fmt.Printf("%s: up to date\n", svc.name)
+		}
+		for _, version := range pending {
+			if *status {
+				fmt.Printf("%s: pending %s\n", svc.name, version)
+			} else {
+				fmt.Printf("%s: applied %s\n", svc.name, version)
+			}
+		}
+	}
+}
+
+// migrateMongo returns the versions of the service's pending mongo
+// migrations, applying them if apply is true
+func migrateMongo(ctx context.Context, svc service, apply bool) ([]string, error) {
+	client, err := m.NewClient(os.Getenv(svc.prefix+"_MONGO_URI"), os.Getenv(svc.prefix+"_MONGO_USER"), os.Getenv(svc.prefix+"_MONGO_PWD"))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database(os.Getenv(svc.prefix + "_MONGO_DB_NAME"))
+	migrations := append(append([]m.Migration{}, svc.mongo...), authRepo.MongoMigrations...)
+	pending, err := m.Pending(ctx, db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, migration := range pending {
+		versions = append(versions, migration.Version)
+	}
+	if !apply {
+		return versions, nil
+	}
+	return versions, m.Migrate(ctx, db, migrations)
+}
+
+// migratePostgres returns the versions of the service's pending postgres
+// migrations, applying them if apply is true
+func migratePostgres(ctx context.Context, svc service, apply bool) ([]string, error) {
+	db, err := pg.NewClient(os.Getenv(svc.prefix + "_POSTGRES_URL"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	migrations := append(append([]pg.Migration{}, svc.postgres...), authRepo.PostgresMigrations...)
+	pending, err := pg.Pending(ctx, db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, migration := range pending {
+		versions = append(versions, migration.Version)
+	}
+	if !apply {
+		return versions, nil
+	}
+	return versions, pg.Migrate(ctx, db, migrations)
+}
@@ -12,7 +12,7 @@ import (
 	api "github.com/contact-tracker/apiService/pkg/api/http"
 )
 
-func NewServer(port, checkInsPort, placesPort, usersPort string) (server *api.Server) {
+func NewServer(port, checkInsPort, placesPort, usersPort, casesPort string) (server *api.Server) {
 	fmt.Printf("Listening for apigateway on %s...\n", port)
 
 	server = api.NewServer(port)
@@ -20,6 +20,7 @@ func NewServer(port, checkInsPort, placesPort, usersPort string) (server *api.Se
 	r.Mount("/check-ins", newProxyRouter("http://localhost:"+checkInsPort, true))
 	r.Mount("/places", newProxyRouter("http://localhost:"+placesPort, true))
 	r.Mount("/users", newProxyRouter("http://localhost:"+usersPort, true))
+	r.Mount("/cases", newProxyRouter("http://localhost:"+casesPort, true))
 
 	return
 }
@@ -34,7 +35,7 @@ func newProxyRouter(targetHost string, useCors bool) http.Handler {
 		corsHandler := cors.New(cors.Options{
 			AllowedOrigins:   []string{"*"},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key"},
 			ExposedHeaders:   []string{"Link"},
 			AllowCredentials: true,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
@@ -13,9 +13,17 @@ import (
 
 	"github.com/joho/godotenv"
 
+	"github.com/contact-tracker/apiService/cases"
+	casesHttp "github.com/contact-tracker/apiService/cases/deliveries/http"
+	casesT "github.com/contact-tracker/apiService/cases/types"
+	checkIns "github.com/contact-tracker/apiService/check-ins"
 	checkHttp "github.com/contact-tracker/apiService/check-ins/deliveries/http"
+	chkT "github.com/contact-tracker/apiService/check-ins/types"
 	gatewayHttp "github.com/contact-tracker/apiService/cmd/server/apigateway"
+	"github.com/contact-tracker/apiService/pkg/auth"
+	"github.com/contact-tracker/apiService/places"
 	placesHttp "github.com/contact-tracker/apiService/places/deliveries/http"
+	pT "github.com/contact-tracker/apiService/places/types"
 	users "github.com/contact-tracker/apiService/users"
 	usersHttp "github.com/contact-tracker/apiService/users/deliveries/http"
 	uT "github.com/contact-tracker/apiService/users/types"
@@ -29,85 +37,168 @@ func main() {
 	var (
 		checkInsPort        = os.Getenv("CHECK_INS_PORT")
 		checkInsMongoDBName = os.Getenv("CHECK_INS_MONGO_DB_NAME")
-		checkInsMongoHost   = os.Getenv("CHECK_INS_MONGO_HOST")
+		checkInsMongoURI    = os.Getenv("CHECK_INS_MONGO_URI")
 		checkInsMongo       = os.Getenv("CHECK_INS_MONGO_USER")
 		checkInsMongoPwd    = os.Getenv("CHECK_INS_MONGO_PWD")
+		checkInsPostgresURL = os.Getenv("CHECK_INS_POSTGRES_URL")
 		placesPort          = os.Getenv("PLACES_PORT")
 		placesMongoDBName   = os.Getenv("PLACES_MONGO_DB_NAME")
-		placesMongoHost     = os.Getenv("PLACES_MONGO_HOST")
+		placesMongoURI      = os.Getenv("PLACES_MONGO_URI")
 		placesMongo         = os.Getenv("PLACES_MONGO_USER")
 		placesMongoPwd      = os.Getenv("PLACES_MONGO_PWD")
+		placesPostgresURL   = os.Getenv("PLACES_POSTGRES_URL")
 		usersPort           = os.Getenv("USERS_PORT")
 		usersMongoDBName    = os.Getenv("USERS_MONGO_DB_NAME")
-		usersMongoHost      = os.Getenv("USERS_MONGO_HOST")
+		usersMongoURI       = os.Getenv("USERS_MONGO_URI")
 		usersMongo          = os.Getenv("USERS_MONGO_USER")
 		usersMongoPwd       = os.Getenv("USERS_MONGO_PWD")
+		usersPostgresURL    = os.Getenv("USERS_POSTGRES_URL")
+		casesPort           = os.Getenv("CASES_PORT")
+		casesMongoDBName    = os.Getenv("CASES_MONGO_DB_NAME")
+		casesMongoURI       = os.Getenv("CASES_MONGO_URI")
+		casesMongo          = os.Getenv("CASES_MONGO_USER")
+		casesMongoPwd       = os.Getenv("CASES_MONGO_PWD")
+		casesPostgresURL    = os.Getenv("CASES_POSTGRES_URL")
+		casesTraceDepth     = os.Getenv("CASES_TRACE_DEPTH")
+		casesMinRiskScore   = os.Getenv("CASES_MIN_RISK_SCORE")
 		apigatewayPort      = os.Getenv("APIGATEWAY_PORT")
 		jwtKeyPath          = os.Getenv("JWT_KEY_PATH")
 		jwtSecretPath       = os.Getenv("JWT_SECRET_PATH")
+		jwtAccessExpir      = os.Getenv("JWT_ACCESS_EXPIR")
+		jwtRefreshExpir     = os.Getenv("JWT_REFRESH_EXPIR")
+		jwtConfirmExpir     = os.Getenv("JWT_CONFIRM_EXPIR")
+		jwtResetExpir       = os.Getenv("JWT_RESET_EXPIR")
+		loginMaxAttempts    = os.Getenv("LOGIN_MAX_ATTEMPTS")
+		loginMaxIPAttempts  = os.Getenv("LOGIN_MAX_IP_ATTEMPTS")
+		loginLockoutMinutes = os.Getenv("LOGIN_LOCKOUT_MINUTES")
 		fromEmail           = os.Getenv("NOTIFICATIONS_FROM_EMAIL")
 		emailPwd            = os.Getenv("EMAIL_PWD")
 		smtpHost            = os.Getenv("SMTP_HOST")
 		smtpPort            = os.Getenv("SMTP_PORT")
-		rpcPwd              = os.Getenv("RPC_AUTH_PWD")
-		storePwd            = os.Getenv("STORE_PWD")
 		timezone            = os.Getenv("TIMEZONE")
+		riskMinOverlap      = os.Getenv("RISK_MIN_OVERLAP_MINUTES")
+		riskFullOverlap     = os.Getenv("RISK_FULL_OVERLAP_MINUTES")
+		riskTentative       = os.Getenv("RISK_TENTATIVE_FACTOR")
+		riskUnknownPlace    = os.Getenv("RISK_UNKNOWN_PLACE_FACTOR")
+		tentativeMinutes    = os.Getenv("TENTATIVE_CHECKOUT_MINUTES")
+		maxDwellMinutes     = os.Getenv("MAX_DWELL_MINUTES")
+		idempotencyWindow   = os.Getenv("IDEMPOTENCY_WINDOW_MINUTES")
+		syncMaxClockSkew    = os.Getenv("SYNC_MAX_CLOCK_SKEW_MINUTES")
+		historyWindow       = os.Getenv("HISTORY_WINDOW_MINUTES")
+		maxHistoryWindow    = os.Getenv("MAX_HISTORY_WINDOW_MINUTES")
+		retentionDays       = os.Getenv("RETENTION_DAYS")
+		sweepInterval       = os.Getenv("SWEEP_INTERVAL_MINUTES")
+		purgeInterval       = os.Getenv("PURGE_INTERVAL_MINUTES")
+		storage             = os.Getenv("STORAGE")
 	)
 
-	chkServer, checkService, err := checkHttp.NewServer(
-		checkInsPort,
-		checkInsMongoDBName,
-		checkInsMongoHost,
-		checkInsMongo,
-		checkInsMongoPwd,
-		"http://localhost:"+usersPort,
-		"http://localhost:"+placesPort,
-		jwtKeyPath,
-		jwtSecretPath,
-		rpcPwd,
-	)
+	chkServer, checkService, err := checkHttp.NewServer(checkInsPort, checkHttp.Config{
+		Config: checkIns.Config{
+			MongoDBName:              checkInsMongoDBName,
+			MongoURI:                 checkInsMongoURI,
+			MongoUser:                checkInsMongo,
+			MongoPwd:                 checkInsMongoPwd,
+			UsersHost:                "http://localhost:" + usersPort,
+			PlacesHost:               "http://localhost:" + placesPort,
+			CasesHost:                "http://localhost:" + casesPort,
+			JWTKeyPath:               jwtKeyPath,
+			JWTSecretPath:            jwtSecretPath,
+			RiskMinOverlap:           riskMinOverlap,
+			RiskFullOverlap:          riskFullOverlap,
+			RiskTentativeFactor:      riskTentative,
+			RiskUnknownPlaceFactor:   riskUnknownPlace,
+			TentativeMinutes:         tentativeMinutes,
+			MaxDwellMinutes:          maxDwellMinutes,
+			IdempotencyWindowMinutes: idempotencyWindow,
+			SyncMaxClockSkewMinutes:  syncMaxClockSkew,
+			HistoryWindowMinutes:     historyWindow,
+			MaxHistoryWindowMinutes:  maxHistoryWindow,
+			RetentionDays:            retentionDays,
+			Storage:                  storage,
+			PostgresURL:              checkInsPostgresURL,
+		},
+		SweepIntervalMinutes: sweepInterval,
+		PurgeIntervalMinutes: purgeInterval,
+	})
 	if err != nil {
 		log.Panic(err)
 	}
 	go chkServer.Start()
 
-	placesServer, _, err := placesHttp.NewServer(
-		placesPort,
-		placesMongoDBName,
-		placesMongoHost,
-		placesMongo,
-		placesMongoPwd,
-		"http://localhost:"+placesPort,
-		jwtKeyPath,
-		jwtSecretPath,
-		rpcPwd,
-		storePwd,
-	)
+	placesServer, placesService, err := placesHttp.NewServer(placesPort, places.Config{
+		MongoDBName:         placesMongoDBName,
+		MongoURI:            placesMongoURI,
+		MongoUser:           placesMongo,
+		MongoPwd:            placesMongoPwd,
+		PlacesHost:          "http://localhost:" + placesPort,
+		JWTKeyPath:          jwtKeyPath,
+		JWTSecretPath:       jwtSecretPath,
+		FromEmail:           fromEmail,
+		EmailPwd:            emailPwd,
+		SMTPHost:            smtpHost,
+		SMTPPort:            smtpPort,
+		JWTAccessExpir:      jwtAccessExpir,
+		JWTRefreshExpir:     jwtRefreshExpir,
+		JWTConfirmExpir:     jwtConfirmExpir,
+		JWTResetExpir:       jwtResetExpir,
+		LoginMaxAttempts:    loginMaxAttempts,
+		LoginMaxIPAttempts:  loginMaxIPAttempts,
+		LoginLockoutMinutes: loginLockoutMinutes,
+		Storage:             storage,
+		PostgresURL:         placesPostgresURL,
+	})
 	if err != nil {
 		log.Panic(err)
 	}
 	go placesServer.Start()
 
-	usersServer, usersService, err := usersHttp.NewServer(
-		usersPort,
-		usersMongoDBName,
-		usersMongoHost,
-		usersMongo,
-		usersMongoPwd,
-		jwtKeyPath,
-		jwtSecretPath,
-		fromEmail,
-		emailPwd,
-		smtpHost,
-		smtpPort,
-		rpcPwd,
-	)
+	usersServer, usersService, err := usersHttp.NewServer(usersPort, users.Config{
+		MongoDBName:         usersMongoDBName,
+		MongoURI:            usersMongoURI,
+		MongoUser:           usersMongo,
+		MongoPwd:            usersMongoPwd,
+		UsersHost:           "http://localhost:" + usersPort,
+		JWTKeyPath:          jwtKeyPath,
+		JWTSecretPath:       jwtSecretPath,
+		FromEmail:           fromEmail,
+		EmailPwd:            emailPwd,
+		SMTPHost:            smtpHost,
+		SMTPPort:            smtpPort,
+		JWTAccessExpir:      jwtAccessExpir,
+		JWTRefreshExpir:     jwtRefreshExpir,
+		JWTConfirmExpir:     jwtConfirmExpir,
+		JWTResetExpir:       jwtResetExpir,
+		LoginMaxAttempts:    loginMaxAttempts,
+		LoginMaxIPAttempts:  loginMaxIPAttempts,
+		LoginLockoutMinutes: loginLockoutMinutes,
+		Storage:             storage,
+		PostgresURL:         usersPostgresURL,
+	})
 	if err != nil {
 		log.Panic(err)
 	}
 	go usersServer.Start()
 
-	apigatewayServer := gatewayHttp.NewServer(apigatewayPort, checkInsPort, placesPort, usersPort)
+	casesServer, casesService, err := casesHttp.NewServer(casesPort, cases.Config{
+		MongoDBName:   casesMongoDBName,
+		MongoURI:      casesMongoURI,
+		MongoUser:     casesMongo,
+		MongoPwd:      casesMongoPwd,
+		UsersHost:     "http://localhost:" + usersPort,
+		CheckInsHost:  "http://localhost:" + checkInsPort,
+		JWTKeyPath:    jwtKeyPath,
+		JWTSecretPath: jwtSecretPath,
+		TraceDepth:    casesTraceDepth,
+		MinRiskScore:  casesMinRiskScore,
+		Storage:       storage,
+		PostgresURL:   casesPostgresURL,
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	go casesServer.Start()
+
+	apigatewayServer := gatewayHttp.NewServer(apigatewayPort, checkInsPort, placesPort, usersPort, casesPort)
 	go apigatewayServer.Start()
 
 	// CLI
@@ -127,15 +218,24 @@ func main() {
 		command = cleanCommand(command)
 
 		if strings.Compare("customers", command) == 0 {
-			users, err := (*usersService).GetAll(ctx)
-			if err != nil {
-				fmt.Printf("Error: %s\n", err)
-				continue
-			}
-			fmt.Printf("%d customer(s)\n", len(users))
-			for _, user := range users {
-				fmt.Printf("%s - %s\n", user.Name, user.Email)
+			count := 0
+			req := &uT.GetUsers{}
+			for {
+				page, err := (*usersService).GetAll(ctx, req)
+				if err != nil {
+					fmt.Printf("Error: %s\n", err)
+					break
+				}
+				for _, user := range page.Items {
+					fmt.Printf("%s - %s\n", user.Name, user.Email)
+				}
+				count += len(page.Items)
+				if page.NextCursor == "" {
+					break
+				}
+				req.Cursor = page.NextCursor
 			}
+			fmt.Printf("%d customer(s)\n", count)
 		} else if strings.Compare("histories", command) == 0 {
 			userID := ""
 			fmt.Printf("Type A to search all users histories otherwise any other response will prompt you to search for a specific user:\n\n-> ")
@@ -148,7 +248,7 @@ func main() {
 				}
 				userID = user.ID
 			}
-			histories, err := (*checkService).GetHistory(ctx, userID, nil, nil)
+			histories, err := (*checkService).GetHistory(ctx, &chkT.GetHistory{UserID: &userID})
 			if err != nil {
 				log.Panic(err)
 			}
@@ -171,38 +271,157 @@ func main() {
 						contactOut = fmt.Sprintf("(Tentative) %s", contactOut)
 					}
 					fmt.Printf(
-						"\t%s From: %s To: %s\n",
+						"\t%s From: %s To: %s (%.0f mins, risk %.2f)\n",
 						contact.User.Name,
 						contact.In.In(loc).Format("Jan 2 3:04 PM"),
 						contactOut,
+						contact.OverlapMinutes,
+						contact.RiskScore,
 					)
 				}
 			}
-		} else if strings.Compare("test", command) == 0 {
+		} else if strings.Compare("trace", command) == 0 {
 			user := searchUser(ctx, usersService, reader)
 			if user == nil {
 				continue
 			}
-			now := time.Now()
-			start := now.Add(time.Hour * -24)
-			histories, err := (*checkService).GetHistory(ctx, user.ID, &start, &now)
+			trace, err := traceContacts(ctx, checkService, reader, user.ID)
 			if err != nil {
-				log.Panic(err)
+				fmt.Printf("Error: %s\n", err)
+				continue
 			}
-			contactsMap := make(map[string]bool)
-			for _, history := range histories {
-				for _, contact := range history.Contacts {
-					contactsMap[contact.User.ID] = true
-				}
+			printTrace(trace, loc)
+		} else if strings.Compare("test", command) == 0 {
+			user := searchUser(ctx, usersService, reader)
+			if user == nil {
+				continue
 			}
-			contacts := []string{}
-			for userID := range contactsMap {
-				contacts = append(contacts, userID)
+			trace, err := traceContacts(ctx, checkService, reader, user.ID)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
 			}
+			contacts := trace.UserIDs()
 			if err = (*usersService).AlertUsers(ctx, contacts); err != nil {
 				log.Panic(err)
 			}
 			fmt.Printf("%d contacts have been notified!\n\n", len(contacts))
+		} else if strings.Compare("report", command) == 0 {
+			user := searchUser(ctx, usersService, reader)
+			if user == nil {
+				continue
+			}
+			testDate := readDate(reader, loc, "Enter the test date as YYYY-MM-DD (default today):")
+			if testDate == nil {
+				now := time.Now()
+				testDate = &now
+			}
+			onset := readDate(reader, loc, "Enter the symptom onset date as YYYY-MM-DD or leave blank if asymptomatic:")
+			cs, err := (*casesService).Report(ctx, &casesT.ReportCase{
+				UserID:       user.ID,
+				ReportedBy:   "cli",
+				TestDate:     testDate,
+				SymptomOnset: onset,
+			})
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			fmt.Printf("Case %s is %s, %d contacts have been notified!\n\n", cs.ID, cs.Status, len(cs.NotifiedContacts))
+		} else if strings.Compare("cases", command) == 0 {
+			count := 0
+			req := &casesT.GetCases{}
+			for {
+				page, err := (*casesService).GetAll(ctx, req)
+				if err != nil {
+					fmt.Printf("Error: %s\n", err)
+					break
+				}
+				for _, cs := range page.Items {
+					fmt.Printf(
+						"%s - user %s tested %s, infectious %s to %s, %s (%d notified)\n",
+						cs.ID,
+						cs.UserID,
+						cs.TestDate.In(loc).Format("Jan 2"),
+						cs.InfectiousStart.In(loc).Format("Jan 2"),
+						cs.InfectiousEnd.In(loc).Format("Jan 2"),
+						cs.Status,
+						len(cs.NotifiedContacts),
+					)
+				}
+				count += len(page.Items)
+				if page.NextCursor == "" {
+					break
+				}
+				req.Cursor = page.NextCursor
+			}
+			fmt.Printf("%d case(s)\n", count)
+		} else if strings.Compare("purge", command) == 0 {
+			report, err := (*checkService).Purge(ctx, true)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			for placeID, count := range report.Places {
+				fmt.Printf("%s - %d check in(s)\n", placeID, count)
+			}
+			fmt.Printf("%d expired check in(s) will be purged, %d kept for open cases\n", report.Purged, report.Exempt)
+			if report.Purged == 0 {
+				continue
+			}
+			fmt.Printf("Type Y to purge them:\n\n-> ")
+			confirm, _ := reader.ReadString('\n')
+			if !strings.EqualFold("y", cleanCommand(confirm)) {
+				continue
+			}
+			if report, err = (*checkService).Purge(ctx, false); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			fmt.Printf("%d check in(s) have been purged\n", report.Purged)
+		} else if strings.Compare("roles", command) == 0 {
+			user := searchUser(ctx, usersService, reader)
+			if user == nil {
+				continue
+			}
+			fmt.Printf("Enter the user's roles separated by commas (customer, placeStaff, placeAdmin, healthOfficial, admin):\n\n-> ")
+			rolesInput, _ := reader.ReadString('\n')
+			roles := []auth.Role{}
+			for _, role := range strings.Split(cleanCommand(rolesInput), ",") {
+				roles = append(roles, auth.Role(strings.TrimSpace(role)))
+			}
+			fmt.Printf("Enter the place id for place staff or admins, or leave blank:\n\n-> ")
+			placeInput, _ := reader.ReadString('\n')
+			updated, err := (*usersService).UpdateRoles(ctx, &uT.UpdateRoles{
+				ID:      user.ID,
+				Roles:   roles,
+				PlaceID: cleanCommand(placeInput),
+			})
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			fmt.Printf("%s now has roles %v\n", updated.Name, updated.Roles)
+		} else if strings.Compare("revoke", command) == 0 {
+			user := searchUser(ctx, usersService, reader)
+			if user == nil {
+				continue
+			}
+			if err := (*usersService).RevokeSessions(ctx, user.ID); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			fmt.Printf("%s has been signed out of every session\n", user.Name)
+		} else if strings.Compare("revoke-place", command) == 0 {
+			place := searchPlace(ctx, placesService, reader)
+			if place == nil {
+				continue
+			}
+			if err := (*placesService).RevokeSessions(ctx, place.ID); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				continue
+			}
+			fmt.Printf("%s has been signed out of every session\n", place.Name)
 		} else if strings.Compare("help", command) == 0 {
 			printCommands()
 		} else {
@@ -255,11 +474,114 @@ func searchUser(ctx context.Context, usersService *users.UserService, reader *bu
 	return
 }
 
+func searchPlace(ctx context.Context, placesService *places.PlaceService, reader *bufio.Reader) (resp *pT.Place) {
+	searchingPlace := true
+	for searchingPlace {
+		fmt.Printf("Type in part of the place's name or email to search for a place or q to exit:\n\n-> ")
+		placeSearch, _ := reader.ReadString('\n')
+		placeSearch = cleanCommand(placeSearch)
+		if strings.Compare("q", placeSearch) == 0 {
+			searchingPlace = false
+			continue
+		}
+		page, err := (*placesService).GetAll(ctx, &pT.GetPlaces{Search: placeSearch})
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("%d match(es)\nEnter number next to the place's name or press any key to search again\n", len(page.Items))
+		for i, place := range page.Items {
+			fmt.Printf("%d) %s - %s\n", i+1, place.Name, place.Email)
+		}
+		fmt.Printf("\n-> ")
+		placeSelect, _ := reader.ReadString('\n')
+		placeIdx, err := strconv.Atoi(cleanCommand(placeSelect))
+		if err != nil || placeIdx > len(page.Items) || placeIdx < 1 {
+			continue
+		}
+		place := page.Items[placeIdx-1]
+
+		fmt.Printf("\nIs this place correct? %s - %s\nY - Yes\nAny other key - No\n\n-> ", place.Name, place.Email)
+		placeConfirm, _ := reader.ReadString('\n')
+		placeConfirm = cleanCommand(placeConfirm)
+		if strings.Compare("Y", placeConfirm) == 0 || strings.Compare("y", placeConfirm) == 0 {
+			resp = place
+			searchingPlace = false
+		}
+	}
+	return
+}
+
+func traceContacts(ctx context.Context, checkService *checkIns.CheckInService, reader *bufio.Reader, userID string) (*chkT.ContactTrace, error) {
+	fmt.Printf("Enter how many degrees of contacts to trace (default 1):\n\n-> ")
+	depthInput, _ := reader.ReadString('\n')
+	depth, err := strconv.Atoi(cleanCommand(depthInput))
+	if err != nil {
+		depth = 1
+	}
+	fmt.Printf("Enter the minimum risk score between 0 and 1 to include a contact (default 0):\n\n-> ")
+	scoreInput, _ := reader.ReadString('\n')
+	minScore, err := strconv.ParseFloat(cleanCommand(scoreInput), 64)
+	if err != nil {
+		minScore = 0
+	}
+	now := time.Now()
+	start := now.Add(time.Hour * -24)
+	return (*checkService).TraceContacts(ctx, &chkT.TraceContacts{
+		UserID:       userID,
+		Start:        &start,
+		End:          &now,
+		Depth:        depth,
+		MinRiskScore: minScore,
+	})
+}
+
+func printTrace(trace *chkT.ContactTrace, loc *time.Location) {
+	indent := strings.Repeat("\t", trace.Degree)
+	if trace.Degree == 0 {
+		fmt.Printf("%s (index case, %d contacts)\n", trace.User.Name, len(trace.UserIDs()))
+	} else {
+		place := ""
+		if trace.Place != nil {
+			place = fmt.Sprintf(" at %s", trace.Place.Name)
+		}
+		fmt.Printf(
+			"%s%s (degree %d, risk %.2f) exposed %s%s\n",
+			indent,
+			trace.User.Name,
+			trace.Degree,
+			trace.RiskScore,
+			trace.ExposedAt.In(loc).Format("Jan 2 3:04 PM"),
+			place,
+		)
+	}
+	for _, contact := range trace.Contacts {
+		printTrace(contact, loc)
+	}
+}
+
+func readDate(reader *bufio.Reader, loc *time.Location, prompt string) *time.Time {
+	fmt.Printf("%s\n\n-> ", prompt)
+	input, _ := reader.ReadString('\n')
+	date, err := time.ParseInLocation("2006-01-02", cleanCommand(input), loc)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
 func printCommands() {
 	fmt.Printf("Commands:\n")
 	// fmt.Printf("stores : prints all available store locations\n")
 	fmt.Printf("customers : prints all prior customers\n")
 	fmt.Printf("histories : prints contact histories for a customer you search by or all customers\n")
+	fmt.Printf("trace : prints a customer's contacts and their contacts up to a number of degrees\n")
 	fmt.Printf("test : test contact alert system by simulating a positive case and notifying all users contacts\n")
+	fmt.Printf("report : report a positive case for a customer and notify their contacts\n")
+	fmt.Printf("cases : prints all reported cases and their status\n")
+	fmt.Printf("purge : deletes check ins past their retention, except those of users in open cases\n")
+	fmt.Printf("roles : grants a user roles such as health official or place staff\n")
+	fmt.Printf("revoke : signs a user out of every session\n")
+	fmt.Printf("revoke-place : signs a place out of every session\n")
 	fmt.Printf("help : prints these commands again\n")
 }
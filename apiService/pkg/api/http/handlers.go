@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -62,6 +63,15 @@ func ParseHTTPParams(r *http.Request, out interface{}) {
 	}
 }
 
+// GetClientIP returns the ip address of the client, which the RealIP
+// middleware reads from proxy headers when present
+func GetClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
 func WriteJSON(w http.ResponseWriter, code int, payload interface{}) {
 	b, err := json.Marshal(payload)
 	if err != nil {
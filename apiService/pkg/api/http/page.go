@@ -0,0 +1,145 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	// DefaultPageLimit - page size of list requests without a limit
+	DefaultPageLimit = 50
+	// MaxPageLimit - largest page a list request may ask for
+	MaxPageLimit = 200
+)
+
+// ErrInvalidPage - the page request's sort or cursor can't be used
+var ErrInvalidPage = errors.New("invalid page request")
+
+// PageRequest - cursor pagination and sort order shared by list requests.
+// Sort names a field, descending when prefixed with "-". Cursor is the
+// NextCursor of the previous page, requested with the same sort.
+type PageRequest struct {
+	Limit  int    `bson:"limit" json:"limit" validate:"gte=0,lte=200"`
+	Cursor string `bson:"cursor" json:"cursor"`
+	Sort   string `bson:"sort" json:"sort"`
+}
+
+// Query validates the sort and cursor against the fields the list may be
+// sorted by, the first of which is the default
+func (p PageRequest) Query(sorts ...string) (*PageQuery, error) {
+	sort := p.Sort
+	if sort == "" {
+		sort = sorts[0]
+	}
+	field := strings.TrimPrefix(sort, "-")
+	allowed := false
+	for _, s := range sorts {
+		allowed = allowed || strings.TrimPrefix(s, "-") == field
+	}
+	if !allowed {
+		return nil, errors.Wrapf(ErrInvalidPage, "can't sort by %s", field)
+	}
+
+	query := &PageQuery{
+		Field: field,
+		Desc:  strings.HasPrefix(sort, "-"),
+		Limit: p.Limit,
+		sort:  sort,
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultPageLimit
+	}
+	if p.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidPage, "malformed cursor")
+		}
+		var after Cursor
+		if err = json.Unmarshal(b, &after); err != nil {
+			return nil, errors.Wrap(ErrInvalidPage, "malformed cursor")
+		}
+		if after.Sort != sort {
+			return nil, errors.Wrapf(ErrInvalidPage, "cursor was made sorting by %s", after.Sort)
+		}
+		query.After = &after
+	}
+	return query, nil
+}
+
+// PageQuery - a validated page request, as passed to repositories.
+// Repositories return up to one more than Limit items, sorted by Field
+// then id, so the page can tell whether there is a next one.
+type PageQuery struct {
+	Field string
+	Desc  bool
+	// After is the position to start after, nil for the first page
+	After *Cursor
+	Limit int
+	sort  string
+}
+
+// Start returns the sort value and id of the item the page starts after,
+// which are empty for the first page
+func (q PageQuery) Start() (value, id string) {
+	if q.After == nil {
+		return "", ""
+	}
+	return q.After.Value, q.After.ID
+}
+
+// NextCursor returns the cursor of the page starting after the item with
+// the sort value and id, the last of this page
+func (q PageQuery) NextCursor(value, id string) string {
+	b, _ := json.Marshal(Cursor{Sort: q.sort, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Cursor - the position after the last item of a page, by its sort value
+// and id
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// cursorTimeFormat - fixed width, so times sort the same as their values
+const cursorTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// TimeValue formats a time as a sort value
+func TimeValue(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(cursorTimeFormat)
+}
+
+// Time returns the cursor value of a time sort field
+func (c Cursor) Time() (time.Time, error) {
+	t, err := time.Parse(cursorTimeFormat, c.Value)
+	if err != nil {
+		return t, errors.Wrapf(ErrInvalidPage, "malformed cursor time %s", c.Value)
+	}
+	return t, nil
+}
+
+// PageInfo - metadata of a page of a list
+type PageInfo struct {
+	// NextCursor requests the next page, and is empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+	Limit      int    `json:"limit"`
+}
+
+// PageErrorCode returns the status for an error listing a page: invalid
+// page requests and filters are unprocessable, anything else is internal
+func PageErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok || errors.Cause(err) == ErrInvalidPage {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/schema"
 )
 
 type Request events.APIGatewayProxyRequest
@@ -91,3 +92,33 @@ func GetPathParam(param string, req *Request) (string, error) {
 	}
 	return res, nil
 }
+
+// GetHeader returns a request header value, matching the name case insensitively
+func GetHeader(name string, req *Request) string {
+	if val, ok := req.Headers[name]; ok {
+		return val
+	}
+	for key, val := range req.Headers {
+		if strings.EqualFold(key, name) {
+			return val
+		}
+	}
+	return ""
+}
+
+// GetClientIP returns the ip address of the client calling the api gateway
+func GetClientIP(req *Request) string {
+	return req.RequestContext.Identity.SourceIP
+}
+
+var decoder = schema.NewDecoder()
+
+// ParseQuery decodes the request's query string parameters into out, the
+// same as the http deliveries parse GET requests
+func ParseQuery(req *Request, out interface{}) error {
+	parsed := make(map[string][]string)
+	for k, v := range req.QueryStringParameters {
+		parsed[k] = []string{v}
+	}
+	return decoder.Decode(out, parsed)
+}
@@ -8,13 +8,25 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 )
 
-// HTTPRPCClient - Make RPC calls via HTTP
+// TokenSource - signs short lived tokens for calls to another service
+type TokenSource interface {
+	GenServiceToken(audience string, scopes ...string) (token string, expiresAt time.Time, err error)
+}
+
+// HTTPRPCClient - Make RPC calls via HTTP to a single service
 type HTTPRPCClient struct {
-	c      *http.Client
-	rpcPwd string
+	c        *http.Client
+	tokens   TokenSource
+	audience string
+	scopes   []string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
 }
 
 type gzreadCloser struct {
@@ -26,18 +38,37 @@ func (gz gzreadCloser) Close() error {
 	return gz.Closer.Close()
 }
 
-// NewHTTPRPCClient - Initializes new client
-func NewHTTPRPCClient(rpcPwd string) *HTTPRPCClient {
+// NewHTTPRPCClient - Initializes new client calling the audience service
+// with tokens granting scopes
+func NewHTTPRPCClient(tokens TokenSource, audience string, scopes ...string) *HTTPRPCClient {
 	return &HTTPRPCClient{
-		&http.Client{
+		c: &http.Client{
 			Transport: &http.Transport{
 				IdleConnTimeout:    60 * time.Second,
 				DisableCompression: true,
 			},
 			Timeout: 60 * time.Second,
 		},
-		rpcPwd,
+		tokens:   tokens,
+		audience: audience,
+		scopes:   scopes,
+	}
+}
+
+// serviceToken returns the current service token, signing a new one
+// shortly before it expires
+func (c *HTTPRPCClient) serviceToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == "" || time.Now().Add(30*time.Second).After(c.expiresAt) {
+		token, expiresAt, err := c.tokens.GenServiceToken(c.audience, c.scopes...)
+		if err != nil {
+			return "", err
+		}
+		c.token, c.expiresAt = token, expiresAt
 	}
+	return c.token, nil
 }
 
 func (c *HTTPRPCClient) HttpRequest(method, path string, data, out interface{}) (int, error) {
@@ -56,8 +87,12 @@ func (c *HTTPRPCClient) HttpRequest(method, path string, data, out interface{})
 		return http.StatusInternalServerError, err
 	}
 
+	token, err := c.serviceToken()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", c.rpcPwd)
+	req.Header.Set("Authorization", "Bearer "+token)
 
 	resp, err := c.c.Do(req)
 	if err != nil {
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidActionToken - an emailed token is malformed, expired, for
+	// another action, or has been revoked
+	ErrInvalidActionToken = errors.New("Invalid or expired token")
+	// ErrTokenUsed - a single use token has already been used
+	ErrTokenUsed = errors.New("Token has already been used")
+)
+
+// Default emailed token lifetimes in minutes
+const (
+	DefaultConfirmExpirationMinutes = 2880
+	DefaultResetExpirationMinutes   = 60
+)
+
+// GenActionToken signs a token letting its holder perform a single action,
+// such as confirming an email address, on subject's account
+func (j *JWTService) GenActionToken(subject, use string, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	return j.Encode(&CustomClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Audience:  j.service,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(expiresIn).Unix(),
+			Issuer:    tokenIssuer,
+			Subject:   subject,
+		},
+		TokenUse: use,
+	})
+}
+
+// UseActionToken validates a token for the use action and revokes it, so
+// each emailed link only works once. When subject is set the token must be
+// for that account, and one for another account is left unused.
+func (j *JWTService) UseActionToken(ctx context.Context, token, use, subject string) (*CustomClaims, error) {
+	if j.revocations == nil {
+		return nil, errors.New("error token revocation is not configured")
+	}
+	claims, err := j.parse(token)
+	if err != nil || claims.TokenUse != use || claims.Subject == "" || !claims.VerifyAudience(j.service, true) {
+		return nil, ErrInvalidActionToken
+	}
+	if subject != "" && claims.Subject != subject {
+		return nil, ErrInvalidActionToken
+	}
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	revoked, err := j.revocations.IsRevoked(ctx, claims.Id, claims.Subject, issuedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking token revocation")
+	}
+	if revoked {
+		return nil, ErrInvalidActionToken
+	}
+	// Revoking is atomic, so of two requests using the token at once only
+	// one succeeds and the other gets ErrTokenUsed
+	err = j.revocations.Revoke(ctx, claims.Id, claims.Subject, time.Unix(claims.ExpiresAt, 0))
+	if err == ErrTokenUsed {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error using token")
+	}
+	return claims, nil
+}
+
+// ForgotPasswordReq - request to email a password reset token
+type ForgotPasswordReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordReq - request to set a new password with an emailed reset token
+type ResetPasswordReq struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"gte=3,lte=50"`
+}
+
+// ChangePasswordReq - request to change a signed in account's password
+type ChangePasswordReq struct {
+	ID          string `json:"-"`
+	OldPassword string `json:"oldPassword" validate:"required"`
+	Password    string `json:"password" validate:"gte=3,lte=50"`
+}
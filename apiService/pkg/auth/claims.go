@@ -1,37 +1,80 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const tokenIssuer = "api.contact-tracker.com"
+
+// Token uses, so a refresh or emailed token is never accepted as an access token
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+	TokenUseService = "service"
+	TokenUseConfirm = "confirm"
+	TokenUseReset   = "reset"
 )
 
 type CustomClaims struct {
 	jwt.StandardClaims
-	Email       string `json:"email"`
-	IsConfirmed bool   `json:"isConfirmed"`
+	Email       string   `json:"email"`
+	IsConfirmed bool     `json:"isConfirmed"`
+	Roles       []Role   `json:"roles,omitempty"`
+	PlaceID     string   `json:"placeId,omitempty"`
+	TokenUse    string   `json:"use,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
 }
 
+// Valid checks the token's expiry, issued at, and not before times,
+// rejecting tokens which never expire
 func (c CustomClaims) Valid() error {
-	return nil
+	if c.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+	return c.StandardClaims.Valid()
 }
 
 type Authable interface {
 	GetAuthables() (id string, email string, conf bool)
 }
 
+// RoleAuthable - an Authable with roles other than customer, and the
+// place it acts for when it has place roles
+type RoleAuthable interface {
+	Authable
+	GetAuthRoles() (roles []Role, placeID string)
+}
+
 func (j *JWTService) GenAccessToken(a Authable) (accessToken string, err error) {
+	if j.accessExpirationMinutes <= 0 {
+		return "", errors.New("error access tokens need an expiration")
+	}
 	now := time.Now()
 	id, email, conf := a.GetAuthables()
 	claims := &CustomClaims{
-		jwt.StandardClaims{
-			IssuedAt: now.Unix(),
-			Issuer:   "api.contact-tracker.com",
-			Subject:  id,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(time.Duration(j.accessExpirationMinutes) * time.Minute).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    tokenIssuer,
+			Subject:   id,
 		},
-		email,
-		conf,
+		Email:       email,
+		IsConfirmed: conf,
+		Roles:       []Role{RoleCustomer},
+		TokenUse:    TokenUseAccess,
+	}
+	if roled, ok := a.(RoleAuthable); ok {
+		if roles, placeID := roled.GetAuthRoles(); len(roles) > 0 {
+			claims.Roles = roles
+			claims.PlaceID = placeID
+		}
 	}
 
 	return j.Encode(claims)
@@ -44,7 +87,32 @@ func (j *JWTService) Encode(claims *CustomClaims) (string, error) {
 	return t, err
 }
 
-func (j *JWTService) Decode(tokenStr string) (claims *CustomClaims, err error) {
+// Decode an access or service token, rejecting tokens that have been revoked
+func (j *JWTService) Decode(ctx context.Context, tokenStr string) (claims *CustomClaims, err error) {
+	if claims, err = j.parse(tokenStr); err != nil {
+		return claims, err
+	}
+	if claims.TokenUse != TokenUseAccess && claims.TokenUse != TokenUseService {
+		return nil, fmt.Errorf("Unauthorized access")
+	}
+	if claims.TokenUse == TokenUseService {
+		if err = j.verifyServiceClaims(claims); err != nil {
+			return nil, err
+		}
+	}
+	if j.revocations != nil {
+		revoked, err := j.revocations.IsRevoked(ctx, claims.Id, claims.Subject, time.Unix(claims.IssuedAt, 0))
+		if err != nil {
+			return nil, errors.Wrap(err, "error checking token revocation")
+		}
+		if revoked {
+			return nil, fmt.Errorf("Token revoked")
+		}
+	}
+	return claims, nil
+}
+
+func (j *JWTService) parse(tokenStr string) (claims *CustomClaims, err error) {
 	decodedToken, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, func(decodedToken *jwt.Token) (interface{}, error) {
 		if _, ok := decodedToken.Method.(*jwt.SigningMethodRSA); !ok {
 			msg := fmt.Errorf("Unexpected signing method: %v", decodedToken.Header["alg"])
@@ -60,3 +128,28 @@ func (j *JWTService) Decode(tokenStr string) (claims *CustomClaims, err error) {
 	}
 	return decodedToken.Claims.(*CustomClaims), nil
 }
+
+// GetRoles returns the claimed roles, treating tokens issued
+// before roles existed as customers
+func (c *CustomClaims) GetRoles() []Role {
+	if len(c.Roles) == 0 {
+		return []Role{RoleCustomer}
+	}
+	return c.Roles
+}
+
+// Owns reports whether the record owned by id belongs to the claimant,
+// either as the claimant themselves or as the place they act for
+func (c *CustomClaims) Owns(id string) bool {
+	return id != "" && (c.Subject == id || c.PlaceID == id)
+}
+
+// AuthorizePlace checks that a caller acting for a place only acts on
+// that place's own data
+func AuthorizePlace(ctx context.Context, placeID string) error {
+	_, claims := ClaimsFromContext(ctx)
+	if claims != nil && claims.PlaceID != "" && claims.PlaceID != placeID {
+		return errors.Wrapf(ErrForbidden, "access to place %s", placeID)
+	}
+	return nil
+}
@@ -12,28 +12,43 @@ import (
 	apiLambda "github.com/contact-tracker/apiService/pkg/api/lambda"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi"
 )
 
 type JWTService struct {
 	key                      *rsa.PublicKey
 	secret                   *rsa.PrivateKey
-	rpcPwd                   string
+	service                  string
 	accessExpirationMinutes  int
 	refreshExpirationMinutes int
+	refreshTokens            RefreshTokenStore
+	revocations              RevocationStore
 }
 
 type JWTServiceConfig struct {
-	Key                      []byte
-	Secret                   []byte
-	RPCPwd                   string
+	Key    []byte
+	Secret []byte
+	// Service names the service using the jwt service, which issues its
+	// service tokens and is the audience of tokens sent to it
+	Service                  string
 	AccessExpirationMinutes  int
 	RefreshExpirationMinutes int
+	// RefreshTokens stores refresh tokens, only needed by services that sign in
+	RefreshTokens RefreshTokenStore
+	// Revocations stores revoked tokens, checked when decoding access tokens
+	Revocations RevocationStore
 }
 
+// Default token lifetimes in minutes
+const (
+	DefaultAccessExpirationMinutes  = 60
+	DefaultRefreshExpirationMinutes = 20160
+)
+
 // AccessTokenKey - cookie and context key for jwt claims authorization
 const AccessTokenKey = "accessToken"
 
-// RPCAccessTokenKey - cookie and context key for rpc authorization
+// RPCAccessTokenKey - context key for the claims of a calling service
 const RPCAccessTokenKey = "rpcAccessToken"
 
 func NewJWTService(config JWTServiceConfig) (*JWTService, error) {
@@ -56,7 +71,9 @@ func NewJWTService(config JWTServiceConfig) (*JWTService, error) {
 		secret:                   jSecret,
 		accessExpirationMinutes:  config.AccessExpirationMinutes,
 		refreshExpirationMinutes: config.RefreshExpirationMinutes,
-		rpcPwd:                   config.RPCPwd,
+		service:                  config.Service,
+		refreshTokens:            config.RefreshTokens,
+		revocations:              config.Revocations,
 	}, nil
 }
 
@@ -68,27 +85,35 @@ func (j *JWTService) RefreshExpirationMinutes() int {
 	return j.refreshExpirationMinutes
 }
 
-func (j *JWTService) AuthorizeHandler(next http.Handler) http.HandlerFunc {
+// AuthorizeHandler authenticates the request and checks the caller against
+// the route's policy, reading the record's owner id from the route or query
+func (j *JWTService) AuthorizeHandler(policy Policy, next http.Handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
 		authHeaders, ok := r.Header["Authorization"]
 		if !ok || len(authHeaders) == 0 {
-			apiHttp.CheckHTTPError(http.StatusUnauthorized, fmt.Errorf("Unauthorized access"))
+			apiHttp.CheckHTTPError(http.StatusUnauthorized, ErrUnauthorized)
 		}
 
 		for _, header := range authHeaders {
-			if rpcMatch, _ := regexp.MatchString(RPCAccessTokenKey, header); rpcMatch {
-				ctx = context.WithValue(ctx, RPCAccessTokenKey, strings.ReplaceAll(header, fmt.Sprintf("%s=", AccessTokenKey), ""))
-			}
-			if authMatch, _ := regexp.MatchString(AccessTokenKey, header); authMatch {
+			if authMatch, _ := regexp.MatchString(AccessTokenKey, header); authMatch || strings.HasPrefix(header, "Bearer ") {
 				authCode := strings.ReplaceAll(header, fmt.Sprintf("%s=", AccessTokenKey), "")
 				authCode = strings.TrimPrefix(authCode, "Bearer ")
-				claims, err := j.Decode(authCode)
+				claims, err := j.Decode(ctx, authCode)
 				apiHttp.CheckHTTPError(http.StatusUnauthorized, err)
-				ctx = context.WithValue(ctx, AccessTokenKey, claims)
+				ctx = withClaims(ctx, claims)
+			}
+		}
+
+		ownerID := ""
+		if policy.Owner != "" {
+			if ownerID = chi.URLParam(r, policy.Owner); ownerID == "" {
+				ownerID = r.URL.Query().Get(policy.Owner)
 			}
 		}
+		err := policy.Authorize(ctx, ownerID)
+		apiHttp.CheckHTTPError(StatusCode(err), err)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -99,23 +124,28 @@ func (j *JWTService) IncludeLambdaAuth(ctx context.Context, req *apiLambda.Reque
 	if val, ok := req.Headers["Cookie"]; ok {
 		authVal = strings.ReplaceAll(val, fmt.Sprintf("%s=", AccessTokenKey), "")
 	} else if val, ok := req.Headers["Authorization"]; ok {
-		if val == j.rpcPwd {
-			ctx = context.WithValue(ctx, RPCAccessTokenKey, val)
-		} else {
-			authVal = strings.TrimPrefix(val, "Bearer ")
-		}
+		authVal = strings.TrimPrefix(val, "Bearer ")
 	}
 	if authVal != "" {
 		var err error
 		var claims *CustomClaims
-		if claims, err = j.Decode(authVal); err != nil {
+		if claims, err = j.Decode(ctx, authVal); err != nil {
 			return ctx, err
 		}
-		ctx = context.WithValue(ctx, AccessTokenKey, claims)
+		ctx = withClaims(ctx, claims)
 	}
 	return ctx, nil
 }
 
+// withClaims adds decoded claims to the context, keeping service callers
+// apart from signed in users
+func withClaims(ctx context.Context, claims *CustomClaims) context.Context {
+	if claims.TokenUse == TokenUseService {
+		return context.WithValue(ctx, RPCAccessTokenKey, claims)
+	}
+	return context.WithValue(ctx, AccessTokenKey, claims)
+}
+
 func ClaimsFromContext(ctx context.Context) (authorized bool, claims *CustomClaims) {
 	if ctx.Value(AccessTokenKey) != nil {
 		return true, ctx.Value(AccessTokenKey).(*CustomClaims)
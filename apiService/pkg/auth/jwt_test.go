@@ -0,0 +1,240 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testKey - signing key shared by every test's jwt services, generated once
+var (
+	testKey     *rsa.PrivateKey
+	testKeyOnce sync.Once
+)
+
+// newTestJWTService returns a jwt service for service, keeping its tokens
+// in memory repositories named dbname
+func newTestJWTService(tt *testing.T, service, dbname string) *auth.JWTService {
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			tt.Fatalf("Error generating key: %v", err)
+		}
+		testKey = key
+	})
+	pub, err := x509.MarshalPKIXPublicKey(&testKey.PublicKey)
+	if err != nil {
+		tt.Fatalf("Error encoding public key: %v", err)
+	}
+	j, err := auth.NewJWTService(auth.JWTServiceConfig{
+		Key:                      pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}),
+		Secret:                   pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)}),
+		Service:                  service,
+		AccessExpirationMinutes:  5,
+		RefreshExpirationMinutes: 60,
+		RefreshTokens:            authRepo.NewMemoryRefreshTokenRepository(dbname),
+		Revocations:              authRepo.NewMemoryRevocationRepository(dbname),
+	})
+	if err != nil {
+		tt.Fatalf("Error creating jwt service: %v", err)
+	}
+	return j
+}
+
+// account - a signed in account for generating tokens
+type account string
+
+func (a account) GetAuthables() (string, string, bool) {
+	return string(a), string(a) + "@example.com", true
+}
+
+func loadAccount(_ context.Context, id string) (auth.Authable, error) {
+	return account(id), nil
+}
+
+func TestRefreshReuse(tt *testing.T) {
+	ctx := context.Background()
+	j := newTestJWTService(tt, auth.ServiceUsers, "refresh-test")
+	_, first, err := j.GenTokens(ctx, account("user-1"))
+	if err != nil {
+		tt.Fatalf("Error generating tokens: %v", err)
+	}
+	_, other, err := j.GenTokens(ctx, account("user-1"))
+	if err != nil {
+		tt.Fatalf("Error generating tokens: %v", err)
+	}
+	var second string
+
+	tests := []struct {
+		name  string
+		token func() string
+		err   error
+	}{
+		{"first use", func() string { return first }, nil},
+		{"rotated token", func() string { return second }, nil},
+		{"reused token", func() string { return first }, auth.ErrRefreshTokenReused},
+		{"token rotated from the reused one", func() string { return second }, auth.ErrInvalidRefreshToken},
+		{"token from another sign in", func() string { return other }, nil},
+		{"malformed token", func() string { return "not-a-token" }, auth.ErrInvalidRefreshToken},
+	}
+	for _, test := range tests {
+		_, next, err := j.Refresh(ctx, test.token(), loadAccount)
+		if err != test.err {
+			tt.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+		if second == "" {
+			second = next
+		}
+	}
+}
+
+func TestUseActionToken(tt *testing.T) {
+	ctx := context.Background()
+	j := newTestJWTService(tt, auth.ServiceUsers, "action-test")
+	gen := func(subject, use string, expiresIn time.Duration) string {
+		token, err := j.GenActionToken(subject, use, expiresIn)
+		if err != nil {
+			tt.Fatalf("Error generating action token: %v", err)
+		}
+		return token
+	}
+	confirm := gen("user-1", auth.TokenUseConfirm, time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		use     string
+		subject string
+		err     error
+	}{
+		{"another account's token", confirm, auth.TokenUseConfirm, "user-2", auth.ErrInvalidActionToken},
+		{"first use", confirm, auth.TokenUseConfirm, "user-1", nil},
+		{"second use", confirm, auth.TokenUseConfirm, "user-1", auth.ErrInvalidActionToken},
+		{"token for another action", gen("user-1", auth.TokenUseReset, time.Hour), auth.TokenUseConfirm, "", auth.ErrInvalidActionToken},
+		{"expired token", gen("user-1", auth.TokenUseReset, -time.Minute), auth.TokenUseReset, "", auth.ErrInvalidActionToken},
+		{"any account", gen("user-1", auth.TokenUseReset, time.Hour), auth.TokenUseReset, "", nil},
+	}
+	for _, test := range tests {
+		if _, err := j.UseActionToken(ctx, test.token, test.use, test.subject); err != test.err {
+			tt.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestUseActionTokenConcurrently(tt *testing.T) {
+	ctx := context.Background()
+	j := newTestJWTService(tt, auth.ServiceUsers, "action-concurrent-test")
+	token, err := j.GenActionToken("user-1", auth.TokenUseReset, time.Hour)
+	if err != nil {
+		tt.Fatalf("Error generating action token: %v", err)
+	}
+
+	const requests = 20
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := j.UseActionToken(ctx, token, auth.TokenUseReset, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	used := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			used++
+		case auth.ErrTokenUsed, auth.ErrInvalidActionToken:
+		default:
+			tt.Errorf("Error using token: %v", err)
+		}
+	}
+	if used != 1 {
+		tt.Errorf("Token was used %d times, want once", used)
+	}
+}
+
+func TestServiceScopes(tt *testing.T) {
+	ctx := context.Background()
+	checkIns := newTestJWTService(tt, auth.ServiceCheckIns, "scopes-test")
+	users := newTestJWTService(tt, auth.ServiceUsers, "scopes-test")
+
+	tests := []struct {
+		name   string
+		issuer string
+		scopes []string
+		ok     bool
+	}{
+		{"allowed scope", auth.ServiceCheckIns, []string{auth.ScopeReadUsers}, true},
+		{"no scopes", auth.ServiceCheckIns, nil, true},
+		{"scope of another service", auth.ServiceCheckIns, []string{auth.ScopeAlertUsers}, false},
+		{"one scope outside the allowlist", auth.ServiceCases, []string{auth.ScopeTraceContacts, auth.ScopeReadUsers}, false},
+		{"unknown service", "billing", []string{auth.ScopeReadUsers}, false},
+	}
+	for _, test := range tests {
+		// Mint the token directly, as anyone holding the signing key could
+		now := time.Now()
+		token, err := checkIns.Encode(&auth.CustomClaims{
+			StandardClaims: jwt.StandardClaims{
+				Id:        test.name,
+				Audience:  auth.ServiceUsers,
+				IssuedAt:  now.Unix(),
+				ExpiresAt: now.Add(time.Minute).Unix(),
+				Issuer:    test.issuer,
+				Subject:   test.issuer,
+			},
+			Roles:    []auth.Role{auth.RoleSystem},
+			Scopes:   test.scopes,
+			TokenUse: auth.TokenUseService,
+		})
+		if err != nil {
+			tt.Fatalf("%s: error encoding token: %v", test.name, err)
+		}
+		if _, err = users.Decode(ctx, token); (err == nil) != test.ok {
+			tt.Errorf("%s: decoding got %v, want ok %t", test.name, err, test.ok)
+		}
+	}
+
+	if _, _, err := checkIns.GenServiceToken(auth.ServiceUsers, auth.ScopeAlertUsers); err == nil {
+		tt.Errorf("Check ins generated a token with the alert scope")
+	}
+}
+
+func TestRevokeAll(tt *testing.T) {
+	ctx := context.Background()
+	j := newTestJWTService(tt, auth.ServiceUsers, "revoke-all-test")
+	access, refresh, err := j.GenTokens(ctx, account("user-1"))
+	if err != nil {
+		tt.Fatalf("Error generating tokens: %v", err)
+	}
+	other, _, err := j.GenTokens(ctx, account("user-2"))
+	if err != nil {
+		tt.Fatalf("Error generating tokens: %v", err)
+	}
+	if err = j.RevokeAll(ctx, "user-1"); err != nil {
+		tt.Fatalf("Error revoking tokens: %v", err)
+	}
+
+	if _, err = j.Decode(ctx, access); err == nil {
+		tt.Errorf("Access token issued in the same second as revoking all is still valid")
+	}
+	if _, _, err = j.Refresh(ctx, refresh, loadAccount); err != auth.ErrInvalidRefreshToken {
+		tt.Errorf("Refreshing a revoked token got %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+	if _, err = j.Decode(ctx, other); err != nil {
+		tt.Errorf("Error decoding another account's token: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	apiLambda "github.com/contact-tracker/apiService/pkg/api/lambda"
+
+	"github.com/pkg/errors"
+)
+
+// Role - a kind of caller, which routes grant access to
+type Role string
+
+const (
+	RoleCustomer       Role = "customer"
+	RolePlaceStaff     Role = "placeStaff"
+	RolePlaceAdmin     Role = "placeAdmin"
+	RoleHealthOfficial Role = "healthOfficial"
+	// RoleAdmin - operators, who grant roles and sign out compromised accounts
+	RoleAdmin Role = "admin"
+	// RoleSystem - another service, which also needs the route's scope
+	RoleSystem Role = "system"
+)
+
+// AllRoles - every role, for routes open to any signed in caller
+var AllRoles = []Role{RoleCustomer, RolePlaceStaff, RolePlaceAdmin, RoleHealthOfficial, RoleAdmin, RoleSystem}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role Role) bool {
+	return hasRole(AllRoles, role)
+}
+
+var (
+	// ErrUnauthorized - the caller is not signed in
+	ErrUnauthorized = errors.New("Unauthorized access")
+	// ErrForbidden - the caller is signed in but their roles do not allow the call
+	ErrForbidden = errors.New("Forbidden access")
+)
+
+// Policy declares which roles may call a route
+type Policy struct {
+	// Roles may call the route for any record
+	Roles []Role
+	// OwnRoles may call the route only for records they own
+	OwnRoles []Role
+	// Owner is the path or query param holding the id of the record's owner
+	Owner string
+	// Scope a calling service's token must grant
+	Scope string
+}
+
+// Allow returns a policy letting roles call a route for any record
+func Allow(roles ...Role) Policy {
+	return Policy{Roles: roles}
+}
+
+// Own returns a copy of the policy also letting roles call the route
+// for records whose owner id, read from param, they own
+func (p Policy) Own(param string, roles ...Role) Policy {
+	p.Owner = param
+	p.OwnRoles = roles
+	return p
+}
+
+// WithScope returns a copy of the policy letting services granted scope call the route
+func (p Policy) WithScope(scope string) Policy {
+	p.Scope = scope
+	return p
+}
+
+// Authorize checks the caller in ctx against the policy for the record
+// owned by ownerID. Calling services have the system role, and must also
+// be granted the policy's scope.
+func (p Policy) Authorize(ctx context.Context, ownerID string) error {
+	authorized, claims := ClaimsFromContext(ctx)
+	if !authorized {
+		return ErrUnauthorized
+	}
+	if claims == nil {
+		service := ServiceClaimsFromContext(ctx)
+		if service != nil && hasRole(p.Roles, RoleSystem) && p.Scope != "" && service.HasScope(p.Scope) {
+			return nil
+		}
+		return ErrForbidden
+	}
+	for _, role := range claims.GetRoles() {
+		if hasRole(p.Roles, role) {
+			return nil
+		}
+		if hasRole(p.OwnRoles, role) && claims.Owns(ownerID) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// OwnerOnly returns the caller's id when the policy only lets them call
+// the route for records they own, so a route creating a record can make
+// the caller its owner
+func (p Policy) OwnerOnly(ctx context.Context) (string, bool) {
+	_, claims := ClaimsFromContext(ctx)
+	if claims == nil {
+		return "", false
+	}
+	for _, role := range claims.GetRoles() {
+		if hasRole(p.Roles, role) {
+			return "", false
+		}
+	}
+	return claims.Subject, true
+}
+
+// AuthorizeLambda checks the caller against the policy, reading the
+// record's owner id from the request's path or query params
+func (p Policy) AuthorizeLambda(ctx context.Context, req *apiLambda.Request) error {
+	ownerID := ""
+	if p.Owner != "" {
+		if id, err := apiLambda.GetPathParam(p.Owner, req); err == nil {
+			ownerID = id
+		} else {
+			ownerID = req.QueryStringParameters[p.Owner]
+		}
+	}
+	return p.Authorize(ctx, ownerID)
+}
+
+// StatusCode returns the http status for an authorization error
+func StatusCode(err error) int {
+	switch errors.Cause(err) {
+	case ErrUnauthorized, ErrInvalidRefreshToken, ErrRefreshTokenReused, ErrInvalidCredentials:
+		return http.StatusUnauthorized
+	case ErrLoginThrottled:
+		return http.StatusTooManyRequests
+	case ErrInvalidActionToken, ErrTokenUsed:
+		return http.StatusUnprocessableEntity
+	case ErrForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func hasRole(roles []Role, role Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
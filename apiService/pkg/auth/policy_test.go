@@ -0,0 +1,84 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// signedIn returns a context of a caller signed in with roles, acting for
+// placeID when set
+func signedIn(id, placeID string, roles ...auth.Role) context.Context {
+	return context.WithValue(context.Background(), auth.AccessTokenKey, &auth.CustomClaims{
+		StandardClaims: jwt.StandardClaims{Subject: id},
+		Roles:          roles,
+		PlaceID:        placeID,
+	})
+}
+
+// calledBy returns a context of a service calling with scopes
+func calledBy(service string, scopes ...string) context.Context {
+	return context.WithValue(context.Background(), auth.RPCAccessTokenKey, &auth.CustomClaims{
+		StandardClaims: jwt.StandardClaims{Subject: service, Issuer: service},
+		Roles:          []auth.Role{auth.RoleSystem},
+		Scopes:         scopes,
+	})
+}
+
+func TestPolicyAuthorize(tt *testing.T) {
+	own := auth.Allow(auth.RoleHealthOfficial).Own("id", auth.RoleCustomer, auth.RolePlaceAdmin)
+	scoped := auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).WithScope(auth.ScopeReadUsers)
+
+	tests := []struct {
+		name   string
+		policy auth.Policy
+		ctx    context.Context
+		owner  string
+		err    error
+	}{
+		{"signed out", own, context.Background(), "user-1", auth.ErrUnauthorized},
+		{"owner", own, signedIn("user-1", "", auth.RoleCustomer), "user-1", nil},
+		{"tokens from before roles are customers", own, signedIn("user-1", ""), "user-1", nil},
+		{"other customer", own, signedIn("user-2", "", auth.RoleCustomer), "user-1", auth.ErrForbidden},
+		{"customer without an owner", own, signedIn("user-1", "", auth.RoleCustomer), "", auth.ErrForbidden},
+		{"place admin of the place", own, signedIn("admin-1", "place-1", auth.RolePlaceAdmin), "place-1", nil},
+		{"place admin of another place", own, signedIn("admin-1", "place-2", auth.RolePlaceAdmin), "place-1", auth.ErrForbidden},
+		{"place staff are not owners", own, signedIn("staff-1", "place-1", auth.RolePlaceStaff), "place-1", auth.ErrForbidden},
+		{"role for any record", own, signedIn("official-1", "", auth.RoleHealthOfficial), "user-1", nil},
+		{"service without the system role", own, calledBy(auth.ServiceCheckIns, auth.ScopeReadUsers), "user-1", auth.ErrForbidden},
+		{"service with the scope", scoped, calledBy(auth.ServiceCheckIns, auth.ScopeReadUsers), "", nil},
+		{"service with another scope", scoped, calledBy(auth.ServiceCases, auth.ScopeAlertUsers), "", auth.ErrForbidden},
+		{"system role without a scope", auth.Allow(auth.RoleSystem), calledBy(auth.ServiceCheckIns, auth.ScopeReadUsers), "", auth.ErrForbidden},
+		{"user claiming the system role", scoped, signedIn("user-1", "", auth.RoleCustomer), "", auth.ErrForbidden},
+	}
+	for _, test := range tests {
+		if err := test.policy.Authorize(test.ctx, test.owner); err != test.err {
+			tt.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestPolicyOwnerOnly(tt *testing.T) {
+	policy := auth.Allow(auth.RoleHealthOfficial).Own("userId", auth.RoleCustomer)
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		id    string
+		owner bool
+	}{
+		{"customer", signedIn("user-1", "", auth.RoleCustomer), "user-1", true},
+		{"role for any record", signedIn("official-1", "", auth.RoleHealthOfficial), "", false},
+		{"customer and role for any record", signedIn("official-1", "", auth.RoleCustomer, auth.RoleHealthOfficial), "", false},
+		{"service", calledBy(auth.ServiceCases, auth.ScopeReadCases), "", false},
+		{"signed out", context.Background(), "", false},
+	}
+	for _, test := range tests {
+		id, owner := policy.OwnerOnly(test.ctx)
+		if id != test.id || owner != test.owner {
+			tt.Errorf("%s: got %q %t, want %q %t", test.name, id, owner, test.id, test.owner)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidRefreshToken - the refresh token is malformed, expired, or revoked
+	ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+	// ErrRefreshTokenReused - a refresh token was used twice, so every
+	// token rotated from the same sign in has been revoked
+	ErrRefreshTokenReused = errors.New("Refresh token reused")
+)
+
+// RefreshToken - a stored refresh token. Each sign in starts a family of
+// tokens, and each refresh uses up one token and adds the next.
+type RefreshToken struct {
+	ID        string    `bson:"_id"`
+	Family    string    `bson:"fam"`
+	Subject   string    `bson:"sub"`
+	Used      bool      `bson:"used"`
+	Revoked   bool      `bson:"rvk"`
+	ExpiresAt time.Time `bson:"exp"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// RefreshTokenStore - persists refresh tokens to detect reuse
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	// Use marks a token used, returning it as it was before
+	Use(ctx context.Context, id string) (*RefreshToken, error)
+	RevokeFamily(ctx context.Context, family string) error
+	RevokeSubject(ctx context.Context, subject string) error
+}
+
+// RefreshReq - request to exchange a refresh token for new tokens
+type RefreshReq struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// GenTokens issues an access token and the first refresh token of a new
+// family for a signed in account
+func (j *JWTService) GenTokens(ctx context.Context, a Authable) (accessToken, refreshToken string, err error) {
+	if accessToken, err = j.GenAccessToken(a); err != nil {
+		return "", "", err
+	}
+	if j.refreshTokens == nil {
+		return accessToken, "", nil
+	}
+	id, _, _ := a.GetAuthables()
+	if refreshToken, err = j.genRefreshToken(ctx, id, uuid.New().String()); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Refresh rotates a refresh token, issuing new tokens for the account load
+// returns. Using a token a second time revokes its whole family, since
+// either the user or an attacker holds a stolen copy.
+func (j *JWTService) Refresh(ctx context.Context, refreshToken string, load func(ctx context.Context, id string) (Authable, error)) (accessToken, newRefreshToken string, err error) {
+	if j.refreshTokens == nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+	claims, err := j.parse(refreshToken)
+	if err != nil || claims.TokenUse != TokenUseRefresh {
+		return "", "", ErrInvalidRefreshToken
+	}
+	stored, err := j.refreshTokens.Use(ctx, claims.Id)
+	if err != nil || stored.Revoked || stored.Subject != claims.Subject {
+		return "", "", ErrInvalidRefreshToken
+	}
+	if stored.Used {
+		if err = j.refreshTokens.RevokeFamily(ctx, stored.Family); err != nil {
+			return "", "", errors.Wrap(err, "error revoking reused refresh token family")
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+
+	a, err := load(ctx, stored.Subject)
+	if err != nil {
+		return "", "", errors.Wrap(err, "error loading refresh token account")
+	}
+	if accessToken, err = j.GenAccessToken(a); err != nil {
+		return "", "", err
+	}
+	if newRefreshToken, err = j.genRefreshToken(ctx, stored.Subject, stored.Family); err != nil {
+		return "", "", err
+	}
+	return accessToken, newRefreshToken, nil
+}
+
+func (j *JWTService) genRefreshToken(ctx context.Context, subject, family string) (string, error) {
+	now := time.Now()
+	stored := &RefreshToken{
+		ID:        uuid.New().String(),
+		Family:    family,
+		Subject:   subject,
+		ExpiresAt: now.Add(time.Duration(j.refreshExpirationMinutes) * time.Minute),
+		CreatedAt: now,
+	}
+	if err := j.refreshTokens.Create(ctx, stored); err != nil {
+		return "", errors.Wrap(err, "error storing refresh token")
+	}
+	return j.Encode(&CustomClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        stored.ID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: stored.ExpiresAt.Unix(),
+			Issuer:    tokenIssuer,
+			Subject:   subject,
+		},
+		TokenUse: TokenUseRefresh,
+	})
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	"github.com/contact-tracker/apiService/pkg/memory"
+)
+
+// MemoryRefreshTokenRepository - keeps refresh tokens in memory
+type MemoryRefreshTokenRepository struct {
+	dbname string
+}
+
+// NewMemoryRefreshTokenRepository -
+func NewMemoryRefreshTokenRepository(dbname string) *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{dbname}
+}
+
+func (r *MemoryRefreshTokenRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryRefreshTokenRepository) Create(_ context.Context, token *auth.RefreshToken) error {
+	c := r.C(ColRefreshTokens)
+	c.Lock()
+	defer c.Unlock()
+
+	stored := *token
+	c.Put(stored.ID, &stored)
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) Use(_ context.Context, id string) (*auth.RefreshToken, error) {
+	c := r.C(ColRefreshTokens)
+	c.Lock()
+	defer c.Unlock()
+
+	doc, ok := c.Get(id)
+	if !ok || doc.(*auth.RefreshToken).ExpiresAt.Before(time.Now()) {
+		return nil, memory.ErrNotFound
+	}
+	token := doc.(*auth.RefreshToken)
+	resp := *token
+	token.Used = true
+	return &resp, nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(_ context.Context, family string) error {
+	return r.revoke(func(token *auth.RefreshToken) bool { return token.Family == family })
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeSubject(_ context.Context, subject string) error {
+	return r.revoke(func(token *auth.RefreshToken) bool { return token.Subject == subject })
+}
+
+func (r *MemoryRefreshTokenRepository) revoke(match func(token *auth.RefreshToken) bool) error {
+	c := r.C(ColRefreshTokens)
+	c.Lock()
+	defer c.Unlock()
+
+	c.Each(func(doc interface{}) bool {
+		if token := doc.(*auth.RefreshToken); match(token) {
+			token.Revoked = true
+		}
+		return true
+	})
+	return nil
+}
+
+// MemoryRevocationRepository - keeps revoked tokens in memory
+type MemoryRevocationRepository struct {
+	dbname string
+}
+
+// NewMemoryRevocationRepository -
+func NewMemoryRevocationRepository(dbname string) *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{dbname}
+}
+
+func (r *MemoryRevocationRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryRevocationRepository) Revoke(_ context.Context, id, subject string, expiresAt time.Time) error {
+	c := r.C(ColRevokedTokens)
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.Get(id); ok {
+		return auth.ErrTokenUsed
+	}
+	c.Put(id, &revokedToken{ID: id, Subject: subject, ExpiresAt: expiresAt})
+	return nil
+}
+
+func (r *MemoryRevocationRepository) RevokeSubject(_ context.Context, subject string, before, expiresAt time.Time) error {
+	c := r.C(ColRevokedTokens)
+	c.Lock()
+	defer c.Unlock()
+
+	id := subjectRevocationID(subject)
+	c.Put(id, &revokedToken{ID: id, Subject: subject, Before: &before, ExpiresAt: expiresAt})
+	return nil
+}
+
+// IsRevoked matches the mongo repository, ignoring revocations which would
+// have expired from its ttl index
+func (r *MemoryRevocationRepository) IsRevoked(_ context.Context, id, subject string, issuedAt time.Time) (bool, error) {
+	c := r.C(ColRevokedTokens)
+	c.RLock()
+	defer c.RUnlock()
+
+	now := time.Now()
+	if doc, ok := c.Get(subjectRevocationID(subject)); ok {
+		revoked := doc.(*revokedToken)
+		if revoked.ExpiresAt.After(now) && revoked.Before != nil && revoked.Before.After(issuedAt) {
+			return true, nil
+		}
+	}
+	if id == "" {
+		return false, nil
+	}
+	doc, ok := c.Get(id)
+	return ok && doc.(*revokedToken).ExpiresAt.After(now), nil
+}
+
+// MemoryLoginAttemptRepository - keeps failed sign ins and lockouts in memory
+type MemoryLoginAttemptRepository struct {
+	dbname string
+}
+
+// NewMemoryLoginAttemptRepository -
+func NewMemoryLoginAttemptRepository(dbname string) *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{dbname}
+}
+
+func (r *MemoryLoginAttemptRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryLoginAttemptRepository) Get(_ context.Context, key string) (*auth.LoginAttempts, error) {
+	c := r.C(ColLoginAttempts)
+	c.RLock()
+	defer c.RUnlock()
+
+	doc, ok := c.Get(key)
+	if !ok || doc.(*auth.LoginAttempts).ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	resp := *doc.(*auth.LoginAttempts)
+	return &resp, nil
+}
+
+func (r *MemoryLoginAttemptRepository) AddFailure(_ context.Context, key string, now, expiresAt time.Time) (*auth.LoginAttempts, error) {
+	c := r.C(ColLoginAttempts)
+	c.Lock()
+	defer c.Unlock()
+
+	stored := &auth.LoginAttempts{Key: key}
+	if doc, ok := c.Get(key); ok && doc.(*auth.LoginAttempts).ExpiresAt.After(now) {
+		stored = doc.(*auth.LoginAttempts)
+	}
+	stored.Failures++
+	stored.LastFailure = now
+	stored.ExpiresAt = expiresAt
+	c.Put(key, stored)
+	resp := *stored
+	return &resp, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(_ context.Context, key string, until time.Time) error {
+	c := r.C(ColLoginAttempts)
+	c.Lock()
+	defer c.Unlock()
+
+	if doc, ok := c.Get(key); ok {
+		doc.(*auth.LoginAttempts).LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Clear(_ context.Context, key string) error {
+	c := r.C(ColLoginAttempts)
+	c.Lock()
+	defer c.Unlock()
+
+	c.Delete(key)
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordLockout(_ context.Context, lockout *auth.Lockout) error {
+	c := r.C(ColLockouts)
+	c.Lock()
+	defer c.Unlock()
+
+	stored := *lockout
+	c.Put(stored.ID, &stored)
+	return nil
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	m "github.com/contact-tracker/apiService/pkg/mongo"
+)
+
+var ColRefreshTokens = "refreshTokens"
+var ColRevokedTokens = "revokedTokens"
+var ColLoginAttempts = "loginAttempts"
+var ColLockouts = "lockouts"
+
+// MongoMigrations - indexes of the auth collections, shared by every
+// service using the same database
+var MongoMigrations = []m.Migration{
+	{
+		// expire refresh tokens once they are past their expiry
+		Version: "auth-0001-refresh-tokens-indexes",
+		Up: m.CreateIndexes(ColRefreshTokens,
+			m.TTLIndex("exp", time.Second),
+			m.IndexKey("fam"),
+			m.IndexKey("sub"),
+		),
+	},
+	{
+		// drop revocations once the tokens they cover have expired
+		Version: "auth-0002-revoked-tokens-indexes",
+		Up:      m.CreateIndexes(ColRevokedTokens, m.TTLIndex("exp", time.Second)),
+	},
+	{
+		// forget failed sign ins once they no longer count towards a
+		// lockout. Lockout audit records are kept.
+		Version: "auth-0003-login-attempts-indexes",
+		Up:      m.CreateIndexes(ColLoginAttempts, m.TTLIndex("exp", time.Second)),
+	},
+	{
+		Version: "auth-0004-lockouts-indexes",
+		Up:      m.CreateIndexes(ColLockouts, m.IndexKey("svc", "val", "-at")),
+	},
+}
+
+// MigrateMongo creates or updates the auth indexes
+func MigrateMongo(ctx context.Context, client *mongo.Client, dbname string) error {
+	return m.Migrate(ctx, client.Database(dbname), MongoMigrations)
+}
+
+// MongoRefreshTokenRepository -
+type MongoRefreshTokenRepository struct {
+	client *mongo.Client
+	dbname string
+}
+
+// NewMongoRefreshTokenRepository -
+func NewMongoRefreshTokenRepository(client *mongo.Client, dbname string) *MongoRefreshTokenRepository {
+	return &MongoRefreshTokenRepository{client, dbname}
+}
+
+func (r *MongoRefreshTokenRepository) C(colName string) *mongo.Collection {
+	return r.client.Database(r.dbname).Collection(colName)
+}
+
+func (r *MongoRefreshTokenRepository) Create(ctx context.Context, token *auth.RefreshToken) error {
+	c := r.C(ColRefreshTokens)
+
+	_, err := c.InsertOne(ctx, token)
+	return err
+}
+
+func (r *MongoRefreshTokenRepository) Use(ctx context.Context, id string) (resp *auth.RefreshToken, err error) {
+	c := r.C(ColRefreshTokens)
+
+	err = c.FindOneAndUpdate(ctx, m.M{"_id": id}, m.M{"$set": m.M{"used": true}}, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&resp)
+	return
+}
+
+func (r *MongoRefreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	c := r.C(ColRefreshTokens)
+
+	return m.UpdateAll(ctx, c, m.M{"fam": family}, m.M{"$set": m.M{"rvk": true}})
+}
+
+func (r *MongoRefreshTokenRepository) RevokeSubject(ctx context.Context, subject string) error {
+	c := r.C(ColRefreshTokens)
+
+	return m.UpdateAll(ctx, c, m.M{"sub": subject}, m.M{"$set": m.M{"rvk": true}})
+}
+
+// revokedToken - a revoked token, or with Before set every token issued
+// to the subject before then
+type revokedToken struct {
+	ID        string     `bson:"_id"`
+	Subject   string     `bson:"sub"`
+	Before    *time.Time `bson:"before,omitempty"`
+	ExpiresAt time.Time  `bson:"exp"`
+}
+
+// MongoRevocationRepository -
+type MongoRevocationRepository struct {
+	client *mongo.Client
+	dbname string
+}
+
+// NewMongoRevocationRepository -
+func NewMongoRevocationRepository(client *mongo.Client, dbname string) *MongoRevocationRepository {
+	return &MongoRevocationRepository{client, dbname}
+}
+
+func (r *MongoRevocationRepository) C(colName string) *mongo.Collection {
+	return r.client.Database(r.dbname).Collection(colName)
+}
+
+// Revoke inserts the token's revocation, which the unique _id makes atomic
+func (r *MongoRevocationRepository) Revoke(ctx context.Context, id, subject string, expiresAt time.Time) error {
+	c := r.C(ColRevokedTokens)
+
+	_, err := c.InsertOne(ctx, &revokedToken{ID: id, Subject: subject, ExpiresAt: expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return auth.ErrTokenUsed
+	}
+	return err
+}
+
+func (r *MongoRevocationRepository) RevokeSubject(ctx context.Context, subject string, before, expiresAt time.Time) error {
+	c := r.C(ColRevokedTokens)
+
+	id := subjectRevocationID(subject)
+	return m.Upsert(ctx, c, nil, m.M{"_id": id}, &revokedToken{ID: id, Subject: subject, Before: &before, ExpiresAt: expiresAt})
+}
+
+func (r *MongoRevocationRepository) IsRevoked(ctx context.Context, id, subject string, issuedAt time.Time) (bool, error) {
+	c := r.C(ColRevokedTokens)
+
+	or := []m.M{{"_id": subjectRevocationID(subject), "before": m.M{"$gt": issuedAt}}}
+	if id != "" {
+		or = append(or, m.M{"_id": id})
+	}
+	count, err := m.Count(ctx, c, m.M{"$or": or})
+	return count > 0, err
+}
+
+func subjectRevocationID(subject string) string {
+	return "sub:" + subject
+}
+
+// MongoLoginAttemptRepository -
+type MongoLoginAttemptRepository struct {
+	client *mongo.Client
+	dbname string
+}
+
+// NewMongoLoginAttemptRepository -
+func NewMongoLoginAttemptRepository(client *mongo.Client, dbname string) *MongoLoginAttemptRepository {
+	return &MongoLoginAttemptRepository{client, dbname}
+}
+
+func (r *MongoLoginAttemptRepository) C(colName string) *mongo.Collection {
+	return r.client.Database(r.dbname).Collection(colName)
+}
+
+func (r *MongoLoginAttemptRepository) Get(ctx context.Context, key string) (resp *auth.LoginAttempts, err error) {
+	c := r.C(ColLoginAttempts)
+
+	if err = m.FindOne(ctx, c, &resp, m.M{"_id": key}); err == m.ErrNotFound {
+		return nil, nil
+	}
+	return
+}
+
+// AddFailure increments the failures in an update pipeline, so the count
+// is read and written in one atomic step
+func (r *MongoLoginAttemptRepository) AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (*auth.LoginAttempts, error) {
+	c := r.C(ColLoginAttempts)
+
+	current := m.M{"$gt": []interface{}{"$exp", now}}
+	var resp auth.LoginAttempts
+	err := c.FindOneAndUpdate(ctx, m.M{"_id": key}, []m.M{{"$set": m.M{
+		"n":           m.M{"$cond": []interface{}{current, m.M{"$add": []interface{}{"$n", 1}}, 1}},
+		"lockedUntil": m.M{"$cond": []interface{}{current, "$lockedUntil", "$$REMOVE"}},
+		"last":        now,
+		"exp":         expiresAt,
+	}}}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (r *MongoLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	c := r.C(ColLoginAttempts)
+
+	return m.Update(ctx, c, nil, m.M{"_id": key}, m.M{"$set": m.M{"lockedUntil": until}})
+}
+
+func (r *MongoLoginAttemptRepository) Clear(ctx context.Context, key string) error {
+	c := r.C(ColLoginAttempts)
+
+	return m.Remove(ctx, c, m.M{"_id": key})
+}
+
+func (r *MongoLoginAttemptRepository) RecordLockout(ctx context.Context, lockout *auth.Lockout) error {
+	c := r.C(ColLockouts)
+
+	_, err := c.InsertOne(ctx, lockout)
+	return err
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+)
+
+// PostgresMigrations - schema of the auth tables, shared by every service
+// using the same database. Postgres has no ttl indexes, so expired rows are
+// ignored when read instead.
+var PostgresMigrations = []pg.Migration{
+	{
+		Version: "auth-0001-create-refresh-tokens",
+		SQL: `CREATE TABLE refresh_tokens (
+			id text PRIMARY KEY,
+			family text NOT NULL,
+			subject text NOT NULL,
+			used boolean NOT NULL DEFAULT false,
+			revoked boolean NOT NULL DEFAULT false,
+			expires_at timestamptz NOT NULL,
+			created_at timestamptz NOT NULL
+		);
+		CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
+		CREATE INDEX refresh_tokens_subject_idx ON refresh_tokens (subject);`,
+	},
+	{
+		Version: "auth-0002-create-revoked-tokens",
+		SQL: `CREATE TABLE revoked_tokens (
+			id text PRIMARY KEY,
+			subject text NOT NULL,
+			before timestamptz,
+			expires_at timestamptz NOT NULL
+		);`,
+	},
+	{
+		Version: "auth-0003-create-login-attempts",
+		SQL: `CREATE TABLE login_attempts (
+			key text PRIMARY KEY,
+			failures integer NOT NULL,
+			last_failure timestamptz NOT NULL,
+			locked_until timestamptz,
+			expires_at timestamptz NOT NULL
+		);
+		CREATE TABLE lockouts (
+			id text PRIMARY KEY,
+			service text NOT NULL,
+			kind text NOT NULL,
+			value text NOT NULL,
+			ip text NOT NULL DEFAULT '',
+			failures integer NOT NULL,
+			locked_at timestamptz NOT NULL,
+			locked_until timestamptz NOT NULL
+		);
+		CREATE INDEX lockouts_value_idx ON lockouts (service, value, locked_at DESC);`,
+	},
+}
+
+// MigratePostgres creates or updates the auth tables
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	return pg.Migrate(ctx, db, PostgresMigrations)
+}
+
+// PostgresRefreshTokenRepository -
+type PostgresRefreshTokenRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRefreshTokenRepository -
+func NewPostgresRefreshTokenRepository(db *sql.DB) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db}
+}
+
+func (r *PostgresRefreshTokenRepository) Create(ctx context.Context, token *auth.RefreshToken) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO refresh_tokens (id, family, subject, used, revoked, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		token.ID, token.Family, token.Subject, token.Used, token.Revoked, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+// Use marks a token used, returning it as it was before. The row is locked
+// so concurrent uses of the same token see each other.
+func (r *PostgresRefreshTokenRepository) Use(ctx context.Context, id string) (*auth.RefreshToken, error) {
+	var token auth.RefreshToken
+	if err := r.db.QueryRowContext(ctx, `UPDATE refresh_tokens AS t SET used = true
+		FROM (SELECT id, used FROM refresh_tokens WHERE id = $1 AND expires_at > now() FOR UPDATE) AS prev
+		WHERE t.id = prev.id
+		RETURNING t.id, t.family, t.subject, prev.used, t.revoked, t.expires_at, t.created_at`, id).
+		Scan(&token.ID, &token.Family, &token.Subject, &token.Used, &token.Revoked, &token.ExpiresAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = true WHERE family = $1", family)
+	return err
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeSubject(ctx context.Context, subject string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = true WHERE subject = $1", subject)
+	return err
+}
+
+// PostgresRevocationRepository -
+type PostgresRevocationRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRevocationRepository -
+func NewPostgresRevocationRepository(db *sql.DB) *PostgresRevocationRepository {
+	return &PostgresRevocationRepository{db}
+}
+
+// Revoke inserts the token's revocation, doing nothing when another caller
+// already has so only the first succeeds
+func (r *PostgresRevocationRepository) Revoke(ctx context.Context, id, subject string, expiresAt time.Time) error {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO revoked_tokens (id, subject, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING`, id, subject, expiresAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return auth.ErrTokenUsed
+	}
+	return err
+}
+
+func (r *PostgresRevocationRepository) RevokeSubject(ctx context.Context, subject string, before, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO revoked_tokens (id, subject, before, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET subject = EXCLUDED.subject, before = EXCLUDED.before, expires_at = EXCLUDED.expires_at`,
+		subjectRevocationID(subject), subject, before, expiresAt)
+	return err
+}
+
+func (r *PostgresRevocationRepository) IsRevoked(ctx context.Context, id, subject string, issuedAt time.Time) (revoked bool, err error) {
+	err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE expires_at > now()
+		AND ((id = $1 AND before > $2) OR ($3 <> '' AND id = $3)))`, subjectRevocationID(subject), issuedAt, id).Scan(&revoked)
+	return
+}
+
+// PostgresLoginAttemptRepository -
+type PostgresLoginAttemptRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresLoginAttemptRepository -
+func NewPostgresLoginAttemptRepository(db *sql.DB) *PostgresLoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{db}
+}
+
+func (r *PostgresLoginAttemptRepository) Get(ctx context.Context, key string) (*auth.LoginAttempts, error) {
+	var attempts auth.LoginAttempts
+	err := r.db.QueryRowContext(ctx, "SELECT key, failures, last_failure, locked_until, expires_at FROM login_attempts WHERE key = $1 AND expires_at > now()", key).
+		Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailure, &attempts.LockedUntil, &attempts.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (r *PostgresLoginAttemptRepository) AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (*auth.LoginAttempts, error) {
+	var attempts auth.LoginAttempts
+	err := r.db.QueryRowContext(ctx, `INSERT INTO login_attempts AS a (key, failures, last_failure, expires_at) VALUES ($1, 1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+		failures = CASE WHEN a.expires_at > $2 THEN a.failures + 1 ELSE 1 END,
+		locked_until = CASE WHEN a.expires_at > $2 THEN a.locked_until END,
+		last_failure = $2, expires_at = $3
+		RETURNING key, failures, last_failure, locked_until, expires_at`, key, now, expiresAt).
+		Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailure, &attempts.LockedUntil, &attempts.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (r *PostgresLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE login_attempts SET locked_until = $2 WHERE key = $1", key, until)
+	return err
+}
+
+func (r *PostgresLoginAttemptRepository) Clear(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+func (r *PostgresLoginAttemptRepository) RecordLockout(ctx context.Context, lockout *auth.Lockout) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO lockouts (id, service, kind, value, ip, failures, locked_at, locked_until) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		lockout.ID, lockout.Service, lockout.Kind, lockout.Value, lockout.IP, lockout.Failures, lockout.LockedAt, lockout.LockedUntil)
+	return err
+}
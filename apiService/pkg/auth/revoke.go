@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RevocationStore - persists revoked access tokens until they would have
+// expired anyway
+type RevocationStore interface {
+	// Revoke a single token by its id, returning ErrTokenUsed when it was
+	// already revoked. It must be atomic, so two callers racing to use a
+	// single use token can't both succeed.
+	Revoke(ctx context.Context, id, subject string, expiresAt time.Time) error
+	// RevokeSubject revokes every token issued to subject before a time
+	RevokeSubject(ctx context.Context, subject string, before, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, id, subject string, issuedAt time.Time) (bool, error)
+}
+
+// LogoutReq - request to sign out, optionally with the refresh token to
+// revoke along with the access token
+type LogoutReq struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Logout revokes the access token in claims and, when given, every
+// refresh token rotated from the same sign in as refreshToken
+func (j *JWTService) Logout(ctx context.Context, claims *CustomClaims, refreshToken string) error {
+	if claims == nil {
+		return ErrUnauthorized
+	}
+	if j.revocations == nil {
+		return errors.New("error token revocation is not configured")
+	}
+	err := j.revocations.Revoke(ctx, claims.Id, claims.Subject, time.Unix(claims.ExpiresAt, 0))
+	if err != nil && err != ErrTokenUsed {
+		return errors.Wrap(err, "error revoking access token")
+	}
+
+	if refreshToken == "" || j.refreshTokens == nil {
+		return nil
+	}
+	refreshClaims, err := j.parse(refreshToken)
+	if err != nil || refreshClaims.TokenUse != TokenUseRefresh || refreshClaims.Subject != claims.Subject {
+		return ErrInvalidRefreshToken
+	}
+	stored, err := j.refreshTokens.Use(ctx, refreshClaims.Id)
+	if err != nil {
+		return ErrInvalidRefreshToken
+	}
+	if err = j.refreshTokens.RevokeFamily(ctx, stored.Family); err != nil {
+		return errors.Wrap(err, "error revoking refresh tokens")
+	}
+	return nil
+}
+
+// RevokeAll revokes every access and refresh token issued to subject so far.
+// Tokens only record the second they were issued, so every token from the
+// current second is revoked too, and the account can sign back in from the
+// next one.
+func (j *JWTService) RevokeAll(ctx context.Context, subject string) error {
+	if j.revocations == nil {
+		return errors.New("error token revocation is not configured")
+	}
+	before := time.Now().Truncate(time.Second).Add(time.Second)
+	if err := j.revocations.RevokeSubject(ctx, subject, before, j.longestExpiry()); err != nil {
+		return errors.Wrap(err, "error revoking access tokens")
+	}
+	if j.refreshTokens != nil {
+		if err := j.refreshTokens.RevokeSubject(ctx, subject); err != nil {
+			return errors.Wrap(err, "error revoking refresh tokens")
+		}
+	}
+	return nil
+}
+
+// longestExpiry returns when every token issued now will have expired
+func (j *JWTService) longestExpiry() time.Time {
+	minutes := j.accessExpirationMinutes
+	if j.refreshExpirationMinutes > minutes {
+		minutes = j.refreshExpirationMinutes
+	}
+	if minutes <= 0 {
+		minutes = DefaultRefreshExpirationMinutes
+	}
+	return time.Now().Add(time.Duration(minutes) * time.Minute)
+}
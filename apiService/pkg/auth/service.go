@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Services, which sign the tokens they call each other with
+const (
+	ServiceUsers    = "users"
+	ServicePlaces   = "places"
+	ServiceCheckIns = "check-ins"
+	ServiceCases    = "cases"
+)
+
+// Scopes a service token can grant, each allowing a single kind of call
+const (
+	ScopeReadUsers     = "users:read"
+	ScopeAlertUsers    = "users:alert"
+	ScopeReadPlaces    = "places:read"
+	ScopeTraceContacts = "check-ins:trace"
+	ScopeReadCases     = "cases:read"
+)
+
+// ServiceTokenMinutes - how long a service token is valid for
+const ServiceTokenMinutes = 5
+
+// serviceScopes - the scopes each service may grant its own tokens. A
+// service token claiming any other scope is rejected, so the shared signing
+// key can't be used to mint a token for a call its issuer never makes.
+var serviceScopes = map[string][]string{
+	ServiceUsers:    {},
+	ServicePlaces:   {},
+	ServiceCheckIns: {ScopeReadUsers, ScopeReadPlaces, ScopeReadCases},
+	ServiceCases:    {ScopeTraceContacts, ScopeAlertUsers},
+}
+
+// allowedScopes returns an error unless service may grant every scope
+func allowedScopes(service string, scopes []string) error {
+	allowed, ok := serviceScopes[service]
+	if !ok {
+		return errors.Errorf("Unknown service %s", service)
+	}
+	for _, scope := range scopes {
+		found := false
+		for _, a := range allowed {
+			found = found || scope == a
+		}
+		if !found {
+			return errors.Errorf("Service %s may not grant scope %s", service, scope)
+		}
+	}
+	return nil
+}
+
+// GenServiceToken signs a short lived token for this service to call the
+// audience service with the given scopes
+func (j *JWTService) GenServiceToken(audience string, scopes ...string) (token string, expiresAt time.Time, err error) {
+	if j.service == "" {
+		return "", expiresAt, errors.New("error jwt service has no service name")
+	}
+	if err = allowedScopes(j.service, scopes); err != nil {
+		return "", expiresAt, err
+	}
+	now := time.Now()
+	expiresAt = now.Add(ServiceTokenMinutes * time.Minute)
+	token, err = j.Encode(&CustomClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Audience:  audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+			Issuer:    j.service,
+			Subject:   j.service,
+		},
+		Roles:    []Role{RoleSystem},
+		Scopes:   scopes,
+		TokenUse: TokenUseService,
+	})
+	return token, expiresAt, err
+}
+
+// verifyServiceClaims checks a service token was issued by a known
+// service for this one, with only the scopes that service may grant
+func (j *JWTService) verifyServiceClaims(claims *CustomClaims) error {
+	if claims.Subject != claims.Issuer {
+		return errors.Errorf("Unknown service %s", claims.Issuer)
+	}
+	if err := allowedScopes(claims.Issuer, claims.Scopes); err != nil {
+		return err
+	}
+	if j.service == "" || !claims.VerifyAudience(j.service, true) {
+		return errors.Errorf("Service token is not for %s", j.service)
+	}
+	return nil
+}
+
+// ServiceClaimsFromContext returns the claims of the calling service
+func ServiceClaimsFromContext(ctx context.Context) *CustomClaims {
+	if claims, ok := ctx.Value(RPCAccessTokenKey).(*CustomClaims); ok {
+		return claims
+	}
+	return nil
+}
+
+// HasScope reports whether the claims grant scope
+func (c *CustomClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
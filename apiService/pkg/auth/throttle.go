@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidCredentials - the email or password given to sign in is wrong
+	ErrInvalidCredentials = errors.New("Invalid email or password")
+	// ErrLoginThrottled - too many failed sign ins, so the account or
+	// client must wait before trying again
+	ErrLoginThrottled = errors.New("Too many failed sign in attempts")
+)
+
+// Default sign in throttling
+const (
+	DefaultLoginMaxAttempts    = 5
+	DefaultLoginMaxIPAttempts  = 20
+	DefaultLoginLockoutMinutes = 15
+	// loginBaseBackoff doubles after each failure, up to loginMaxBackoff
+	loginBaseBackoff = time.Second
+	loginMaxBackoff  = time.Minute
+)
+
+// Kinds of key sign in failures are tracked by
+const (
+	LoginKeyAccount = "account"
+	LoginKeyIP      = "ip"
+)
+
+// LoginAttempts - failed sign ins for an account or client ip since the
+// last success or lockout
+type LoginAttempts struct {
+	Key         string     `bson:"_id"`
+	Failures    int        `bson:"n"`
+	LastFailure time.Time  `bson:"last"`
+	LockedUntil *time.Time `bson:"lockedUntil,omitempty"`
+	ExpiresAt   time.Time  `bson:"exp"`
+}
+
+// Lockout - audit record of an account or client ip being locked out
+type Lockout struct {
+	ID          string    `bson:"_id" json:"id"`
+	Service     string    `bson:"svc" json:"service"`
+	Kind        string    `bson:"kind" json:"kind"`
+	Value       string    `bson:"val" json:"value"`
+	IP          string    `bson:"ip,omitempty" json:"ip,omitempty"`
+	Failures    int       `bson:"n" json:"failures"`
+	LockedAt    time.Time `bson:"at" json:"lockedAt"`
+	LockedUntil time.Time `bson:"until" json:"lockedUntil"`
+}
+
+// LoginAttemptStore - persists failed sign ins and lockout audit records
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (*LoginAttempts, error)
+	// AddFailure atomically counts a failure at now, starting over when the
+	// key's earlier failures have expired, and returns the updated attempts
+	AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (*LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Clear(ctx context.Context, key string) error
+	RecordLockout(ctx context.Context, lockout *Lockout) error
+}
+
+// LoginGuardConfig - thresholds for throttling sign ins. Zero values use
+// the defaults.
+type LoginGuardConfig struct {
+	// Service the guarded accounts belong to, keeping user and place
+	// accounts with the same email apart
+	Service        string
+	Attempts       LoginAttemptStore
+	MaxAttempts    int
+	MaxIPAttempts  int
+	LockoutMinutes int
+}
+
+// LoginGuard throttles sign ins by account and client ip. Each failure
+// doubles how long the next attempt must wait, and reaching the threshold
+// locks the account or ip out.
+type LoginGuard struct {
+	service       string
+	attempts      LoginAttemptStore
+	maxAttempts   int
+	maxIPAttempts int
+	lockout       time.Duration
+}
+
+// NewLoginGuard -
+func NewLoginGuard(config LoginGuardConfig) *LoginGuard {
+	g := &LoginGuard{
+		service:       config.Service,
+		attempts:      config.Attempts,
+		maxAttempts:   config.MaxAttempts,
+		maxIPAttempts: config.MaxIPAttempts,
+		lockout:       time.Duration(config.LockoutMinutes) * time.Minute,
+	}
+	if g.maxAttempts <= 0 {
+		g.maxAttempts = DefaultLoginMaxAttempts
+	}
+	if g.maxIPAttempts <= 0 {
+		g.maxIPAttempts = DefaultLoginMaxIPAttempts
+	}
+	if g.lockout <= 0 {
+		g.lockout = DefaultLoginLockoutMinutes * time.Minute
+	}
+	return g
+}
+
+// Check returns ErrLoginThrottled when the account or ip must wait before
+// signing in again. Sign ins are refused when their attempts can't be read.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	for _, key := range g.keyKinds(email, ip) {
+		attempts, err := g.attempts.Get(ctx, key)
+		if err != nil {
+			return errors.Wrap(err, "error checking failed sign ins")
+		}
+		if attempts == nil {
+			continue
+		}
+		if wait := g.wait(attempts, now); wait > 0 {
+			return errors.Wrapf(ErrLoginThrottled, "retry in %s", wait.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Fail records a failed sign in, locking out the account or ip once it
+// reaches its threshold
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	for kind, key := range g.keyKinds(email, ip) {
+		attempts, err := g.attempts.AddFailure(ctx, key, now, now.Add(g.lockout))
+		if err != nil {
+			return errors.Wrap(err, "error recording failed sign in")
+		}
+
+		max := g.maxAttempts
+		if kind == LoginKeyIP {
+			max = g.maxIPAttempts
+		}
+		if attempts.Failures >= max && attempts.LockedUntil == nil {
+			until := now.Add(g.lockout)
+			if err = g.attempts.Lock(ctx, key, until); err != nil {
+				return errors.Wrap(err, "error locking out sign ins")
+			}
+			value := strings.ToLower(email)
+			if kind == LoginKeyIP {
+				value = ip
+			}
+			if err = g.attempts.RecordLockout(ctx, &Lockout{
+				ID:          uuid.New().String(),
+				Service:     g.service,
+				Kind:        kind,
+				Value:       value,
+				IP:          ip,
+				Failures:    attempts.Failures,
+				LockedAt:    now,
+				LockedUntil: until,
+			}); err != nil {
+				return errors.Wrap(err, "error recording lockout")
+			}
+		}
+	}
+	return nil
+}
+
+// Succeed clears the account's failed sign ins. The ip's are kept, so
+// one good account can't be used to keep guessing others.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) error {
+	return g.attempts.Clear(ctx, g.key(LoginKeyAccount, strings.ToLower(email)))
+}
+
+// wait returns how long attempts must wait before the next sign in
+func (g *LoginGuard) wait(attempts *LoginAttempts, now time.Time) time.Duration {
+	if attempts.LockedUntil != nil {
+		return attempts.LockedUntil.Sub(now)
+	}
+	if attempts.Failures < 2 {
+		return 0
+	}
+	backoff := loginMaxBackoff
+	if shift := uint(attempts.Failures - 2); shift < 6 {
+		backoff = loginBaseBackoff << shift
+	}
+	return attempts.LastFailure.Add(backoff).Sub(now)
+}
+
+func (g *LoginGuard) keyKinds(email, ip string) map[string]string {
+	keys := map[string]string{LoginKeyAccount: g.key(LoginKeyAccount, strings.ToLower(email))}
+	if ip != "" {
+		keys[LoginKeyIP] = g.key(LoginKeyIP, ip)
+	}
+	return keys
+}
+
+func (g *LoginGuard) key(kind, value string) string {
+	return g.service + ":" + kind + ":" + value
+}
@@ -0,0 +1,65 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/pkg/errors"
+)
+
+func TestLoginGuard(tt *testing.T) {
+	const (
+		email = "Guarded@example.com"
+		ip    = "10.0.0.1"
+	)
+
+	tests := []struct {
+		name     string
+		failures int
+		failIP   string
+		succeed  bool
+		checkIP  string
+		wait     string
+	}{
+		{"no failures", 0, "", false, "", ""},
+		{"first failure", 1, "", false, "", ""},
+		{"second failure backs off", 2, "", false, "", "retry in 1s"},
+		{"sign in clears the account", 2, "", true, "", ""},
+		{"sign in keeps the ip backing off", 2, ip, true, ip, "retry in 1s"},
+		{"other ips are not throttled", 2, ip, true, "10.0.0.2", ""},
+		{"max failures lock out", 3, "", false, "", "retry in 15m"},
+		{"ip locks out at its own max", 4, ip, true, ip, "retry in 15m"},
+	}
+	for i, test := range tests {
+		ctx := context.Background()
+		guard := auth.NewLoginGuard(auth.LoginGuardConfig{
+			Service:        auth.ServiceUsers,
+			Attempts:       authRepo.NewMemoryLoginAttemptRepository(fmt.Sprintf("throttle-test-%d", i)),
+			MaxAttempts:    3,
+			MaxIPAttempts:  4,
+			LockoutMinutes: 15,
+		})
+		for n := 0; n < test.failures; n++ {
+			if err := guard.Fail(ctx, email, test.failIP); err != nil {
+				tt.Fatalf("%s: error failing sign in: %v", test.name, err)
+			}
+		}
+		if test.succeed {
+			if err := guard.Succeed(ctx, strings.ToLower(email)); err != nil {
+				tt.Fatalf("%s: error succeeding sign in: %v", test.name, err)
+			}
+		}
+
+		err := guard.Check(ctx, email, test.checkIP)
+		switch {
+		case test.wait == "" && err != nil:
+			tt.Errorf("%s: got %v, want no wait", test.name, err)
+		case test.wait != "" && (errors.Cause(err) != auth.ErrLoginThrottled || !strings.Contains(err.Error(), test.wait)):
+			tt.Errorf("%s: got %v, want %s", test.name, err, test.wait)
+		}
+	}
+}
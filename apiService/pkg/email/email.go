@@ -3,6 +3,7 @@ package email
 import (
 	"crypto/tls"
 	"strconv"
+	"strings"
 
 	gomail "gopkg.in/mail.v2"
 )
@@ -37,3 +38,9 @@ func (c EmailClient) SendEmail(to, subject, body string) error {
 	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 	return dialer.DialAndSend(msg)
 }
+
+// Normalize returns the address as accounts store it, trimmed and lower case,
+// so an address is only ever registered once
+func Normalize(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Storage - config value selecting in memory repositories over mongo
+const Storage = "memory"
+
+// ErrNotFound - no record matched the query
+var ErrNotFound = errors.New("not found")
+
+var (
+	mu  sync.Mutex
+	dbs = map[string]map[string]*Collection{}
+)
+
+// Collection - records kept in insertion order. Callers hold the lock
+// while reading or changing records, and copy records they return.
+type Collection struct {
+	sync.RWMutex
+	docs map[string]interface{}
+	ids  []string
+}
+
+// C returns the named collection of a database, shared by every
+// repository in the process using the same names, like a mongo database
+func C(dbname, colName string) *Collection {
+	mu.Lock()
+	defer mu.Unlock()
+
+	db, ok := dbs[dbname]
+	if !ok {
+		db = map[string]*Collection{}
+		dbs[dbname] = db
+	}
+	c, ok := db[colName]
+	if !ok {
+		c = &Collection{docs: map[string]interface{}{}}
+		db[colName] = c
+	}
+	return c
+}
+
+// Get a record by id
+func (c *Collection) Get(id string) (interface{}, bool) {
+	doc, ok := c.docs[id]
+	return doc, ok
+}
+
+// Put inserts or replaces a record
+func (c *Collection) Put(id string, doc interface{}) {
+	if _, ok := c.docs[id]; !ok {
+		c.ids = append(c.ids, id)
+	}
+	c.docs[id] = doc
+}
+
+// Delete a record by id
+func (c *Collection) Delete(id string) bool {
+	if _, ok := c.docs[id]; !ok {
+		return false
+	}
+	delete(c.docs, id)
+	for i, docID := range c.ids {
+		if docID == id {
+			c.ids = append(c.ids[:i], c.ids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Each calls fn with every record in insertion order until it returns false
+func (c *Collection) Each(fn func(doc interface{}) bool) {
+	for _, id := range c.ids {
+		if !fn(c.docs[id]) {
+			return
+		}
+	}
+}
+
+// Page sorts n records by the value then id key returns for each,
+// descending when desc, returning the indexes of up to limit of them. When
+// afterID is set the page starts after the record with afterValue and afterID.
+func Page(n int, key func(i int) (value, id string), desc bool, afterValue, afterID string, limit int) []int {
+	less := func(aValue, aID, bValue, bID string) bool {
+		if aValue == bValue {
+			return aID < bID
+		}
+		return aValue < bValue
+	}
+	if desc {
+		asc := less
+		less = func(aValue, aID, bValue, bID string) bool { return asc(bValue, bID, aValue, aID) }
+	}
+
+	indexes := []int{}
+	for i := 0; i < n; i++ {
+		if value, id := key(i); afterID == "" || less(afterValue, afterID, value, id) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		aValue, aID := key(indexes[a])
+		bValue, bID := key(indexes[b])
+		return less(aValue, aID, bValue, bID)
+	})
+	if len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	return indexes
+}
@@ -0,0 +1,133 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ColMigrations - record of the migrations applied to a database
+var ColMigrations = "migrations"
+
+// Migration - an index or data change, applied once per database
+type Migration struct {
+	// Version uniquely names the migration across every service sharing
+	// the database, ie. "users-0001-email-index"
+	Version string
+	// Up applies the migration. Mongo can't lock a database while it is
+	// migrated, so services starting together may both run Up and it must
+	// be safe to run twice.
+	Up func(ctx context.Context, db *mongo.Database) error
+}
+
+// appliedMigration - a migration recorded in ColMigrations
+type appliedMigration struct {
+	Version   string    `bson:"_id"`
+	AppliedAt time.Time `bson:"at"`
+}
+
+// Migrate applies the migrations not yet recorded in ColMigrations, in order
+func Migrate(ctx context.Context, db *mongo.Database, migrations []Migration) error {
+	pending, err := Pending(ctx, db, migrations)
+	if err != nil {
+		return err
+	}
+	c := db.Collection(ColMigrations)
+	for _, migration := range pending {
+		if err = migration.Up(ctx, db); err != nil {
+			return errors.Wrapf(err, "error applying migration %s", migration.Version)
+		}
+		_, err = c.InsertOne(ctx, &appliedMigration{Version: migration.Version, AppliedAt: time.Now()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return errors.Wrapf(err, "error recording migration %s", migration.Version)
+		}
+	}
+	return nil
+}
+
+// Pending returns the migrations not yet recorded in ColMigrations, in order
+func Pending(ctx context.Context, db *mongo.Database, migrations []Migration) ([]Migration, error) {
+	applied := []*appliedMigration{}
+	if err := Find(ctx, db.Collection(ColMigrations), &applied, M{}); err != nil {
+		return nil, errors.Wrap(err, "error reading applied migrations")
+	}
+	done := map[string]bool{}
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// CreateIndexes returns a migration step creating indexes on the collection
+// col. Creating an index that already exists is a no-op.
+func CreateIndexes(col string, indexes ...mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(col).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+// NormalizeEmails returns a migration step storing the emails in the field key
+// of the collection col trimmed and lower case, so a unique index on key can
+// be created. Accounts whose emails differ only by case can't both keep
+// them, so when there are any the step fails, listing their ids, without
+// changing anything. An operator then merges each listed group by hand:
+// keeping one account, changing the others' emails, or deleting them, after
+// checking with their owners. The migration succeeds on the next start.
+func NormalizeEmails(col, key string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		c := db.Collection(col)
+		var accounts []struct {
+			ID    string `bson:"_id"`
+			Email string `bson:"email"`
+		}
+		if err := Aggregate(ctx, c, &accounts, []M{
+			{"$match": M{key: M{"$type": "string"}}},
+			{"$project": M{"email": "$" + key}},
+			{"$sort": SortKeys("_id")},
+		}); err != nil {
+			return err
+		}
+
+		ids := map[string][]string{}
+		normalized := []string{}
+		for _, account := range accounts {
+			email := strings.ToLower(strings.TrimSpace(account.Email))
+			if len(ids[email]) == 0 {
+				normalized = append(normalized, email)
+			}
+			ids[email] = append(ids[email], account.ID)
+		}
+		conflicts := []string{}
+		for _, email := range normalized {
+			if len(ids[email]) > 1 {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%s)", email, strings.Join(ids[email], ", ")))
+			}
+		}
+		if len(conflicts) > 0 {
+			return errors.Errorf("error %s with emails differing only by case must be merged first: %s", col, strings.Join(conflicts, "; "))
+		}
+
+		for _, account := range accounts {
+			email := strings.ToLower(strings.TrimSpace(account.Email))
+			if email == account.Email {
+				continue
+			}
+			if err := UpdateAll(ctx, c, M{"_id": account.ID}, M{"$set": M{key: email}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -1,134 +1,175 @@
 package mongo
 
 import (
-	"crypto/tls"
-	"fmt"
-	"net"
+	"context"
 	"strings"
+	"time"
 
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var session *mgo.Session
+// ErrNotFound - no document matched the query
+var ErrNotFound = mongo.ErrNoDocuments
 
-func NewClient(host, user, pwd string) (*mgo.Session, error) {
-	if user == "" {
-		return mgo.Dial(host)
-	}
+const connectTimeout = 10 * time.Second
 
-	hostParts := strings.Split(host, "-")
-	hostPre := hostParts[0]
-	hostSuff := hostParts[1]
-	hosts := []string{
-		fmt.Sprintf("%s-shard-00-00-%s:27017", hostPre, hostSuff),
-		fmt.Sprintf("%s-shard-00-01-%s:27017", hostPre, hostSuff),
-		fmt.Sprintf("%s-shard-00-02-%s:27017", hostPre, hostSuff),
+// NewClient connects to the deployment at uri, such as
+// mongodb+srv://cluster0-abcd.mongodb.net or mongodb://localhost:27017. A
+// bare host is treated as mongodb://host. user and pwd are optional and
+// override any credentials in uri.
+func NewClient(uri, user, pwd string) (*mongo.Client, error) {
+	if !strings.Contains(uri, "://") {
+		uri = "mongodb://" + uri
+	}
+	opts := options.Client().ApplyURI(uri)
+	if user != "" {
+		opts.SetAuth(options.Credential{Username: user, Password: pwd})
 	}
 
-	dialInfo := &mgo.DialInfo{
-		Addrs:     hosts,
-		Username:  user,
-		Password:  pwd,
-		PoolLimit: 10,
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
-	tlsConfig := &tls.Config{}
-	dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
-		conn, err := tls.Dial("tcp", addr.String(), tlsConfig)
-		return conn, err
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
 	}
-	return mgo.DialWithInfo(dialInfo)
+	return client, nil
 }
 
 type IdGetter interface {
 	GetId() interface{}
 }
 
-func Insert(c *mgo.Collection, result, data interface{}) error {
-	var err error
+func Insert(ctx context.Context, c *mongo.Collection, result, data interface{}) error {
 	if result == nil {
-		err = c.Insert(data)
-	} else {
-		change := mgo.Change{
-			Update:    data,
-			ReturnNew: true,
-			Upsert:    true,
-		}
-		if ider, ok := data.(IdGetter); ok {
-			_, err = c.Find(M{"_id": ider.GetId()}).Apply(change, result)
-		} else {
-			_, err = c.Find(M{"_id": bson.NewObjectId()}).Apply(change, result)
-		}
+		_, err := c.InsertOne(ctx, data)
+		return err
 	}
-	return err
+	var id interface{} = primitive.NewObjectID()
+	if ider, ok := data.(IdGetter); ok {
+		id = ider.GetId()
+	}
+	return Upsert(ctx, c, result, M{"_id": id}, data)
 }
 
-func Update(c *mgo.Collection, result, query, update interface{}) error {
-	var err error
-	if result == nil {
-		err = c.Update(query, update)
-	} else {
-		change := mgo.Change{
-			Update:    update,
-			ReturnNew: true,
-		}
-		_, err = c.Find(query).Apply(change, result)
+// Update the first document matching query, returning ErrNotFound if there
+// is none. update is either update operators or a replacement document.
+func Update(ctx context.Context, c *mongo.Collection, result, query, update interface{}) error {
+	return modify(ctx, c, result, query, update, false)
+}
+
+// Upsert updates the first document matching query, or inserts one if
+// there is none
+func Upsert(ctx context.Context, c *mongo.Collection, result, query, update interface{}) error {
+	if err := modify(ctx, c, result, query, update, true); err != nil {
+		return err
 	}
-	return err
+	if p, ok := result.(PostProcessable); ok {
+		p.PostProcess()
+	}
+	return nil
 }
 
-func Upsert(c *mgo.Collection, result, query, update interface{}) error {
-	var err error
+func modify(ctx context.Context, c *mongo.Collection, result, query, update interface{}, upsert bool) error {
+	replace, err := isReplacement(update)
+	if err != nil {
+		return err
+	}
+
 	if result == nil {
-		_, err = c.Upsert(query, update)
-	} else {
-		change := mgo.Change{
-			Update:    update,
-			Upsert:    true,
-			ReturnNew: true,
+		var res *mongo.UpdateResult
+		if replace {
+			res, err = c.ReplaceOne(ctx, query, update, options.Replace().SetUpsert(upsert))
+		} else {
+			res, err = c.UpdateOne(ctx, query, update, options.Update().SetUpsert(upsert))
 		}
-		_, err = c.Find(query).Apply(change, result)
-		if p, ok := result.(PostProcessable); ok {
-			p.PostProcess()
+		if err == nil && !upsert && res.MatchedCount == 0 {
+			err = ErrNotFound
 		}
+		return err
 	}
-	return err
+
+	var single *mongo.SingleResult
+	if replace {
+		single = c.FindOneAndReplace(ctx, query, update, options.FindOneAndReplace().SetUpsert(upsert).SetReturnDocument(options.After))
+	} else {
+		single = c.FindOneAndUpdate(ctx, query, update, options.FindOneAndUpdate().SetUpsert(upsert).SetReturnDocument(options.After))
+	}
+	return single.Decode(result)
 }
 
-func UpdateAll(c *mgo.Collection, query, update interface{}) error {
-	_, err := c.UpdateAll(query, update)
+// isReplacement returns true if update is a whole document rather than
+// update operators such as $set
+func isReplacement(update interface{}) (bool, error) {
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return false, err
+	}
+	elems, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return false, err
+	}
+	return len(elems) == 0 || !strings.HasPrefix(elems[0].Key(), "$"), nil
+}
+
+func UpdateAll(ctx context.Context, c *mongo.Collection, query, update interface{}) error {
+	_, err := c.UpdateMany(ctx, query, update)
 	return err
 }
 
 // First optional arg is Fields
 // Second optional arg is slice of sort strings, ie. []string{"price", "-created_at"}
-func Find(c *mgo.Collection, result, query interface{}, args ...interface{}) error {
-	q := c.Find(query)
-	if args != nil {
-		if len(args) > 0 && args[0] != nil {
-			q = q.Select(args[0])
-		}
-		if len(args) > 1 && args[1] != nil {
-			q = q.Sort(args[1].([]string)...)
-		}
+func Find(ctx context.Context, c *mongo.Collection, result, query interface{}, args ...interface{}) error {
+	opts := options.Find()
+	if len(args) > 0 && args[0] != nil {
+		opts.SetProjection(args[0])
 	}
-	if err := q.All(result); err != nil {
+	if len(args) > 1 && args[1] != nil {
+		opts.SetSort(SortKeys(args[1].([]string)...))
+	}
+	cur, err := c.Find(ctx, query, opts)
+	if err != nil {
 		return err
 	}
-	return nil
+	return cur.All(ctx, result)
 }
 
-func FindOne(c *mgo.Collection, result, query interface{}, args ...interface{}) error {
-	q := c.Find(query)
-	if args != nil {
-		if len(args) > 0 && args[0] != nil {
-			q = q.Select(args[0])
-		}
-		if len(args) > 1 && args[1] != nil {
-			q = q.Sort(args[1].([]string)...)
-		}
+// FindPage finds up to limit documents matching query sorted by key then
+// _id, descending when desc. When afterID is set the page starts after the
+// document with the values after and afterID.
+func FindPage(ctx context.Context, c *mongo.Collection, result interface{}, query M, key string, desc bool, after interface{}, afterID string, limit int) error {
+	op, sort := "$gt", []string{key, "_id"}
+	if desc {
+		op, sort = "$lt", []string{"-" + key, "-_id"}
+	}
+	if afterID != "" {
+		query = M{"$and": []M{query, {"$or": []M{
+			{key: M{op: after}},
+			{key: after, "_id": M{op: afterID}},
+		}}}}
+	}
+	cur, err := c.Find(ctx, query, options.Find().SetSort(SortKeys(sort...)).SetLimit(int64(limit)))
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, result)
+}
+
+func FindOne(ctx context.Context, c *mongo.Collection, result, query interface{}, args ...interface{}) error {
+	opts := options.FindOne()
+	if len(args) > 0 && args[0] != nil {
+		opts.SetProjection(args[0])
+	}
+	if len(args) > 1 && args[1] != nil {
+		opts.SetSort(SortKeys(args[1].([]string)...))
 	}
-	if err := q.One(result); err != nil {
+	if err := c.FindOne(ctx, query, opts).Decode(result); err != nil {
 		return err
 	}
 	if p, ok := result.(PostProcessable); ok {
@@ -137,27 +178,80 @@ func FindOne(c *mgo.Collection, result, query interface{}, args ...interface{})
 	return nil
 }
 
-func Aggregate(c *mgo.Collection, result, pipe interface{}) error {
-	if err := c.Pipe(pipe).All(result); err != nil {
+func Count(ctx context.Context, c *mongo.Collection, query interface{}) (int64, error) {
+	return c.CountDocuments(ctx, query)
+}
+
+func Aggregate(ctx context.Context, c *mongo.Collection, result, pipe interface{}) error {
+	cur, err := c.Aggregate(ctx, pipe)
+	if err != nil {
 		return err
 	}
-	return nil
+	return cur.All(ctx, result)
 }
 
-func AggregateOne(c *mgo.Collection, result, pipe interface{}) error {
-	if err := c.Pipe(pipe).One(result); err != nil {
+func AggregateOne(ctx context.Context, c *mongo.Collection, result, pipe interface{}) error {
+	cur, err := c.Aggregate(ctx, pipe)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer cur.Close(ctx)
+
+	if !cur.Next(ctx) {
+		if err = cur.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	return cur.Decode(result)
 }
 
-func Remove(c *mgo.Collection, query interface{}) error {
-	if _, err := c.RemoveAll(query); err != nil {
-		return err
+func Remove(ctx context.Context, c *mongo.Collection, query interface{}) error {
+	_, err := c.DeleteMany(ctx, query)
+	return err
+}
+
+// CaseInsensitive - collation comparing strings ignoring case. Queries only
+// use an index with a collation when they are made with the same collation.
+var CaseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// CreateIndexKey creates an index on key, ie. "svc", "-at"
+func CreateIndexKey(ctx context.Context, c *mongo.Collection, key ...string) error {
+	_, err := c.Indexes().CreateOne(ctx, IndexKey(key...))
+	return err
+}
+
+// IndexKey - an index on key, ie. "svc", "-at"
+func IndexKey(key ...string) mongo.IndexModel {
+	return mongo.IndexModel{Keys: SortKeys(key...)}
+}
+
+// UniqueIndex - a unique index on key, comparing strings with collation
+// when it is not nil
+func UniqueIndex(collation *options.Collation, key ...string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    SortKeys(key...),
+		Options: options.Index().SetUnique(true).SetCollation(collation),
+	}
+}
+
+// TTLIndex - an index removing documents expireAfter the time in key
+func TTLIndex(key string, expireAfter time.Duration) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    SortKeys(key),
+		Options: options.Index().SetExpireAfterSeconds(int32(expireAfter / time.Second)),
 	}
-	return nil
 }
 
-func CreateIndexKey(c *mgo.Collection, key ...string) error {
-	return c.EnsureIndexKey(key...)
+// SortKeys converts keys, descending when prefixed with "-", to an ordered document
+func SortKeys(keys ...string) bson.D {
+	doc := bson.D{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "-") {
+			doc = append(doc, bson.E{Key: key[1:], Value: -1})
+		} else {
+			doc = append(doc, bson.E{Key: key, Value: 1})
+		}
+	}
+	return doc
 }
@@ -1,12 +1,15 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
 
 	api "github.com/contact-tracker/apiService/pkg/api/http"
 	"github.com/contact-tracker/apiService/pkg/auth"
@@ -34,8 +37,11 @@ func (d *handler) Get() http.HandlerFunc {
 func (d *handler) GetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		places, err := d.Usecase.GetAll(ctx)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
+		req := &t.GetPlaces{}
+		api.ParseHTTPParams(r, req)
+
+		places, err := d.Usecase.GetAll(ctx, req)
+		api.CheckHTTPError(api.PageErrorCode(err), err)
 		api.WriteJSON(w, http.StatusOK, places)
 	}
 }
@@ -47,6 +53,7 @@ func (d *handler) Update() http.HandlerFunc {
 		api.ParseHTTPParams(r, req)
 
 		req.ID = chi.URLParam(r, "id")
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, req.ID))
 		resp, err := d.Usecase.Update(ctx, req)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, resp)
@@ -60,7 +67,7 @@ func (d *handler) Create() http.HandlerFunc {
 		api.ParseHTTPParams(r, req)
 		place, err := d.Usecase.Create(ctx, req)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
-		place.AuthToken, err = d.jwt.GenAccessToken(place)
+		place.AuthToken, place.RefreshToken, err = d.jwt.GenTokens(ctx, place)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, place)
 	}
@@ -70,6 +77,7 @@ func (d *handler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		id := chi.URLParam(r, "id")
+		api.CheckHTTPError(http.StatusForbidden, auth.AuthorizePlace(ctx, id))
 
 		err := d.Usecase.Delete(ctx, id)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
@@ -82,29 +90,134 @@ func (d *handler) SignIn() http.HandlerFunc {
 		ctx := r.Context()
 		req := &t.SignInReq{}
 		api.ParseHTTPParams(r, req)
+		req.IP = api.GetClientIP(r)
 		place, err := d.Usecase.SignIn(ctx, req)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
-		place.AuthToken, err = d.jwt.GenAccessToken(place)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		place.AuthToken, place.RefreshToken, err = d.jwt.GenTokens(ctx, place)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, place)
 	}
 }
 
 func (d *handler) Confirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.ConfirmReq{
+			ID:    chi.URLParam(r, "id"),
+			Token: r.URL.Query().Get("token"),
+		}
+
+		err := d.Usecase.Confirm(ctx, req)
+		api.CheckHTTPError(http.StatusUnprocessableEntity, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ResendConfirmation() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		id := chi.URLParam(r, "id")
 
-		err := d.Usecase.Confirm(ctx, id)
+		err := d.Usecase.ResendConfirmation(ctx, id)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, nil)
 	}
 }
 
-func NewServer(port, mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd, storePwd string) (server *api.Server, service *places.PlaceService, err error) {
+func (d *handler) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.RefreshReq{}
+		api.ParseHTTPParams(r, req)
+
+		var place *t.Place
+		accessToken, refreshToken, err := d.jwt.Refresh(ctx, req.RefreshToken, func(ctx context.Context, id string) (auth.Authable, error) {
+			var err error
+			place, err = d.Usecase.Get(ctx, id)
+			return place, err
+		})
+		api.CheckHTTPError(auth.StatusCode(err), err)
+		place.AuthToken, place.RefreshToken = accessToken, refreshToken
+		api.WriteJSON(w, http.StatusOK, place)
+	}
+}
+
+func (d *handler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.LogoutReq{}
+		if r.ContentLength != 0 {
+			api.ParseHTTPParams(r, req)
+		}
+
+		_, claims := auth.ClaimsFromContext(ctx)
+		err := d.jwt.Logout(ctx, claims, req.RefreshToken)
+		api.CheckHTTPError(auth.StatusCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) RevokeSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+
+		err := d.Usecase.RevokeSessions(ctx, id)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ForgotPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ForgotPasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		err := d.Usecase.ForgotPassword(ctx, req)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ResetPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ResetPasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		err := d.Usecase.ResetPassword(ctx, req)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ChangePasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		req.ID = chi.URLParam(r, "id")
+		err := d.Usecase.ChangePassword(ctx, req)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+// passwordErrorCode rejects bad credentials, reset tokens and old
+// passwords, and invalid requests, as the caller's fault
+func passwordErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return auth.StatusCode(err)
+}
+
+func NewServer(port string, cfg places.Config) (server *api.Server, service *places.PlaceService, err error) {
 	fmt.Printf("Listening for places on %s...\n", port)
 
-	svc, j, err := places.Init(mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd, storePwd)
+	svc, j, err := places.Init(cfg)
 	if err != nil {
 		log.Panic(err)
 		return nil, nil, err
@@ -119,12 +232,19 @@ func NewServer(port, mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, j
 	server = api.NewServer(port)
 	r := server.Router
 	r.Post("/places", h.Create())
-	r.Get("/places", j.AuthorizeHandler(h.GetAll()))
-	r.Get("/places/{id}", j.AuthorizeHandler(h.Get()))
-	r.Put("/places/{id}", j.AuthorizeHandler(h.Update()))
-	r.Delete("/places/{id}", j.AuthorizeHandler(h.Delete()))
+	r.Get("/places", j.AuthorizeHandler(places.GetAllPolicy, h.GetAll()))
+	r.Get("/places/{id}", j.AuthorizeHandler(places.GetPolicy, h.Get()))
+	r.Put("/places/{id}", j.AuthorizeHandler(places.UpdatePolicy, h.Update()))
+	r.Delete("/places/{id}", j.AuthorizeHandler(places.DeletePolicy, h.Delete()))
 	r.Post("/places/login", h.SignIn())
+	r.Post("/places/refresh", h.Refresh())
+	r.Post("/places/logout", j.AuthorizeHandler(places.LogoutPolicy, h.Logout()))
+	r.Post("/places/{id}/revoke", j.AuthorizeHandler(places.RevokeSessionsPolicy, h.RevokeSessions()))
 	r.Get("/places/{id}/confirm", h.Confirm())
+	r.Post("/places/password/forgot", h.ForgotPassword())
+	r.Post("/places/password/reset", h.ResetPassword())
+	r.Put("/places/{id}/password", j.AuthorizeHandler(places.ChangePasswordPolicy, h.ChangePassword()))
+	r.Post("/places/{id}/confirm/resend", j.AuthorizeHandler(places.ResendConfirmationPolicy, h.ResendConfirmation()))
 
 	return
 }
@@ -5,6 +5,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/contact-tracker/apiService/pkg/auth"
 	t "github.com/contact-tracker/apiService/places/types"
 )
 
@@ -31,11 +32,11 @@ func (a *LoggerAdapter) Get(ctx context.Context, id string) (*t.Place, error) {
 	return place, err
 }
 
-// GetAll gets all places
-func (a *LoggerAdapter) GetAll(ctx context.Context) ([]*t.Place, error) {
+// GetAll gets a page of places
+func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetPlaces) (*t.PlacePage, error) {
 	defer a.Logger.Sync()
 	a.Logger.Info("getting all places")
-	places, err := a.Usecase.GetAll(ctx)
+	places, err := a.Usecase.GetAll(ctx, req)
 	a.logErr(err)
 	return places, err
 }
@@ -78,10 +79,46 @@ func (a *LoggerAdapter) SignIn(ctx context.Context, req *t.SignInReq) (*t.Place,
 }
 
 // Confirm a single place
-func (a *LoggerAdapter) Confirm(ctx context.Context, id string) error {
+func (a *LoggerAdapter) Confirm(ctx context.Context, req *t.ConfirmReq) error {
 	defer a.Logger.Sync()
 	a.Logger.Info("confirming a single place")
-	err := a.Usecase.Confirm(ctx, id)
+	err := a.Usecase.Confirm(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ResendConfirmation of a single place's email
+func (a *LoggerAdapter) ResendConfirmation(ctx context.Context, id string) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("resending a place confirmation")
+	err := a.Usecase.ResendConfirmation(ctx, id)
+	a.logErr(err)
+	return err
+}
+
+// ForgotPassword emails a place a password reset token
+func (a *LoggerAdapter) ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("sending a place password reset")
+	err := a.Usecase.ForgotPassword(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ResetPassword of a single place
+func (a *LoggerAdapter) ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("resetting a place password")
+	err := a.Usecase.ResetPassword(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ChangePassword of a single place
+func (a *LoggerAdapter) ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("changing a place password")
+	err := a.Usecase.ChangePassword(ctx, req)
 	a.logErr(err)
 	return err
 }
@@ -0,0 +1,26 @@
+package places
+
+import (
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+// Route policies, shared by the http and lambda deliveries
+var (
+	// GetAllPolicy - any signed in caller may list places
+	GetAllPolicy = auth.Allow(auth.AllRoles...)
+	// GetPolicy - any signed in caller may read a place
+	GetPolicy = auth.Allow(auth.AllRoles...).WithScope(auth.ScopeReadPlaces)
+	// UpdatePolicy - place admins may only update their own place
+	UpdatePolicy = auth.Allow(auth.RoleSystem).Own("id", auth.RolePlaceAdmin)
+	// LogoutPolicy - any signed in place may sign out
+	LogoutPolicy = auth.Allow(auth.AllRoles...)
+	// RevokeSessionsPolicy - place admins may sign their place out
+	// everywhere, and admins may sign out a compromised place
+	RevokeSessionsPolicy = auth.Allow(auth.RoleAdmin).Own("id", auth.RolePlaceAdmin)
+	// ResendConfirmationPolicy - place admins may ask for their place's confirmation email again
+	ResendConfirmationPolicy = auth.Allow(auth.RoleSystem).Own("id", auth.RolePlaceAdmin)
+	// ChangePasswordPolicy - place admins may only change their own place's password
+	ChangePasswordPolicy = auth.Allow().Own("id", auth.RolePlaceAdmin)
+	// DeletePolicy - place admins may only delete their own place
+	DeletePolicy = auth.Allow(auth.RoleSystem).Own("id", auth.RolePlaceAdmin)
+)
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/memory"
+	t "github.com/contact-tracker/apiService/places/types"
+)
+
+// MemoryPlaceRepository - keeps places in memory, for running without mongo
+type MemoryPlaceRepository struct {
+	dbname string
+}
+
+// NewMemoryPlaceRepository -
+func NewMemoryPlaceRepository(dbname string) *MemoryPlaceRepository {
+	return &MemoryPlaceRepository{dbname}
+}
+
+func (r *MemoryPlaceRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryPlaceRepository) Get(_ context.Context, id string) (*t.Place, error) {
+	c := r.C(ColPlaces)
+	c.RLock()
+	defer c.RUnlock()
+
+	doc, ok := c.Get(id)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	return copyPlace(doc.(*t.Place)), nil
+}
+
+func (r *MemoryPlaceRepository) FindByEmail(_ context.Context, email string) (*t.Place, error) {
+	places := r.find(func(place *t.Place) bool {
+		return strings.EqualFold(place.Email, email)
+	})
+	if len(places) == 0 {
+		return nil, memory.ErrNotFound
+	}
+	return places[0], nil
+}
+
+func (r *MemoryPlaceRepository) GetAll(_ context.Context, req *t.GetPlaces, page *api.PageQuery) ([]*t.Place, error) {
+	search := strings.ToLower(req.Search)
+	places := r.find(func(place *t.Place) bool {
+		if req.Confirmed != nil && place.Confirmed != *req.Confirmed {
+			return false
+		}
+		return strings.Contains(strings.ToLower(place.Name), search) || strings.Contains(strings.ToLower(place.Email), search)
+	})
+	after, afterID := page.Start()
+
+	resp := []*t.Place{}
+	for _, i := range memory.Page(len(places), func(i int) (string, string) {
+		return places[i].SortValue(page.Field), places[i].ID
+	}, page.Desc, after, afterID, page.Limit+1) {
+		resp = append(resp, places[i])
+	}
+	return resp, nil
+}
+
+func (r *MemoryPlaceRepository) Update(_ context.Context, update *t.UpdatePlace) (*t.Place, error) {
+	c := r.C(ColPlaces)
+	c.Lock()
+	defer c.Unlock()
+
+	doc, ok := c.Get(update.ID)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	place := copyPlace(doc.(*t.Place))
+	if update.Name != nil {
+		place.Name = *update.Name
+	}
+	if update.Email != nil {
+		place.Email = *update.Email
+	}
+	if update.Confirmed != nil {
+		place.Confirmed = *update.Confirmed
+	}
+	if update.EncryptedPassword != nil {
+		place.EncryptedPassword = *update.EncryptedPassword
+	}
+	if update.LastLoggedIn != nil {
+		place.LastLoggedIn = update.LastLoggedIn
+	}
+	if update.MaxDwellMinutes != nil {
+		place.MaxDwellMinutes = *update.MaxDwellMinutes
+	}
+	if update.ClosingTime != nil {
+		place.ClosingTime = *update.ClosingTime
+	}
+	if update.Timezone != nil {
+		place.Timezone = *update.Timezone
+	}
+	if update.RequireConfirmed != nil {
+		place.RequireConfirmed = *update.RequireConfirmed
+	}
+	if update.RetentionDays != nil {
+		place.RetentionDays = *update.RetentionDays
+	}
+	c.Put(place.ID, place)
+	return copyPlace(place), nil
+}
+
+func (r *MemoryPlaceRepository) Create(_ context.Context, place *t.Place) (*t.Place, error) {
+	c := r.C(ColPlaces)
+	c.Lock()
+	defer c.Unlock()
+
+	stored := copyPlace(place)
+	stored.ID = uuid.New().String()
+	c.Put(stored.ID, stored)
+	return copyPlace(stored), nil
+}
+
+func (r *MemoryPlaceRepository) Delete(_ context.Context, id string) error {
+	c := r.C(ColPlaces)
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.Delete(id) {
+		return memory.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryPlaceRepository) find(match func(place *t.Place) bool) []*t.Place {
+	c := r.C(ColPlaces)
+	c.RLock()
+	defer c.RUnlock()
+
+	resp := []*t.Place{}
+	c.Each(func(doc interface{}) bool {
+		if place := doc.(*t.Place); match(place) {
+			resp = append(resp, copyPlace(place))
+		}
+		return true
+	})
+	return resp
+}
+
+// copyPlace copies a place as stored, without the tokens mongo never stores
+func copyPlace(place *t.Place) *t.Place {
+	cp := *place
+	cp.AuthToken = ""
+	cp.RefreshToken = ""
+	return &cp
+}
@@ -2,91 +2,121 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"regexp"
 
-	"github.com/globalsign/mgo"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	api "github.com/contact-tracker/apiService/pkg/api/http"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
 	t "github.com/contact-tracker/apiService/places/types"
 )
 
 var ColPlaces = "places"
 
+// MongoMigrations - indexes and data migrations of the places collection
+var MongoMigrations = []m.Migration{
+	{
+		// emails are stored lower case before they are indexed as unique,
+		// failing until accounts whose emails differ only by case are
+		// merged by hand. Listed first as creating the index fails on
+		// databases which have them.
+		Version: "places-0000-normalize-emails",
+		Up:      m.NormalizeEmails(ColPlaces, "em"),
+	},
+	{
+		// emails are unique ignoring case, and found by FindByEmail
+		Version: "places-0001-email-index",
+		Up:      m.CreateIndexes(ColPlaces, m.UniqueIndex(m.CaseInsensitive, "em")),
+	},
+}
+
 // MongoPlaceRepository -
 type MongoPlaceRepository struct {
-	session *mgo.Session
-	dbname  string
+	client *mongo.Client
+	dbname string
 }
 
 // NewMongoPlaceRepository -
-func NewMongoPlaceRepository(m *mgo.Session, dbname string) *MongoPlaceRepository {
-	return &MongoPlaceRepository{m, dbname}
+func NewMongoPlaceRepository(client *mongo.Client, dbname string) *MongoPlaceRepository {
+	return &MongoPlaceRepository{client, dbname}
+}
+
+// Migrate creates or updates the places indexes
+func (r *MongoPlaceRepository) Migrate(ctx context.Context) error {
+	return m.Migrate(ctx, r.DB(), MongoMigrations)
 }
 
-func (r *MongoPlaceRepository) DB() (*mgo.Session, *mgo.Database) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname)
+func (r *MongoPlaceRepository) DB() *mongo.Database {
+	return r.client.Database(r.dbname)
 }
 
-func (r *MongoPlaceRepository) C(colName string) (*mgo.Session, *mgo.Collection) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname).C(colName)
+func (r *MongoPlaceRepository) C(colName string) *mongo.Collection {
+	return r.DB().Collection(colName)
 }
 
-func (r *MongoPlaceRepository) Get(_ context.Context, id string) (resp *t.Place, err error) {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+func (r *MongoPlaceRepository) Get(ctx context.Context, id string) (resp *t.Place, err error) {
+	c := r.C(ColPlaces)
 
-	err = m.FindOne(c, &resp, m.M{"_id": id})
+	err = m.FindOne(ctx, c, &resp, m.M{"_id": id})
 	return
 }
 
-func (r *MongoPlaceRepository) FindByEmail(_ context.Context, email string) (resp *t.Place, err error) {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+func (r *MongoPlaceRepository) FindByEmail(ctx context.Context, email string) (resp *t.Place, err error) {
+	c := r.C(ColPlaces)
 
-	err = m.FindOne(c, &resp, m.M{"em": m.M{"$regex": fmt.Sprintf("(?i)^%s$", email)}})
+	err = c.FindOne(ctx, m.M{"em": email}, options.FindOne().SetCollation(m.CaseInsensitive)).Decode(&resp)
 	return
 }
 
-func (r *MongoPlaceRepository) GetAll(_ context.Context) (resp []*t.Place, err error) {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+// placeSortKeys - document keys of PlaceSorts
+var placeSortKeys = map[string]string{"name": "nm", "email": "em"}
+
+func (r *MongoPlaceRepository) GetAll(ctx context.Context, req *t.GetPlaces, page *api.PageQuery) (resp []*t.Place, err error) {
+	c := r.C(ColPlaces)
+
+	query := m.M{}
+	if req.Confirmed != nil {
+		query["conf"] = *req.Confirmed
+	}
+	if req.Search != "" {
+		search := m.M{"$regex": regexp.QuoteMeta(req.Search), "$options": "i"}
+		query["$or"] = []m.M{{"nm": search}, {"em": search}}
+	}
+	after, afterID := page.Start()
 
-	err = m.Find(c, &resp, m.M{})
+	resp = []*t.Place{}
+	err = m.FindPage(ctx, c, &resp, query, placeSortKeys[page.Field], page.Desc, after, afterID, page.Limit+1)
 	return
 }
 
-func (r *MongoPlaceRepository) Update(_ context.Context, place *t.UpdatePlace) (*t.Place, error) {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+func (r *MongoPlaceRepository) Update(ctx context.Context, place *t.UpdatePlace) (*t.Place, error) {
+	c := r.C(ColPlaces)
 
 	var resp t.Place
-	err := m.Update(c, &resp, m.M{"_id": place.ID}, m.M{"$set": place})
+	err := m.Update(ctx, c, &resp, m.M{"_id": place.ID}, m.M{"$set": place})
 	return &resp, err
 }
 
-func (r *MongoPlaceRepository) Create(_ context.Context, place *t.Place) (*t.Place, error) {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+func (r *MongoPlaceRepository) Create(ctx context.Context, place *t.Place) (*t.Place, error) {
+	c := r.C(ColPlaces)
 
 	var resp t.Place
 	place.ID = r.newID()
-	err := m.Upsert(c, &resp, m.M{"_id": place.ID}, m.M{"$set": place})
+	err := m.Upsert(ctx, c, &resp, m.M{"_id": place.ID}, m.M{"$set": place})
 	return &resp, err
 }
 
-func (r *MongoPlaceRepository) Delete(_ context.Context, id string) error {
-	sess, c := r.C(ColPlaces)
-	defer sess.Close()
+func (r *MongoPlaceRepository) Delete(ctx context.Context, id string) error {
+	c := r.C(ColPlaces)
 
 	var out t.Place
-	if err := m.FindOne(c, &out, m.M{"_id": id}); err != nil {
+	if err := m.FindOne(ctx, c, &out, m.M{"_id": id}); err != nil {
 		return err
 	}
 
-	return m.Remove(c, m.M{"_id": id})
+	return m.Remove(ctx, c, m.M{"_id": id})
 }
 
 func (r *MongoPlaceRepository) newID() string {
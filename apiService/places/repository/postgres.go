@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+	t "github.com/contact-tracker/apiService/places/types"
+)
+
+// PostgresMigrations - schema of the places table
+var PostgresMigrations = []pg.Migration{
+	{
+		Version: "places-0001-create-places",
+		SQL: `CREATE TABLE places (
+			id text PRIMARY KEY,
+			name text NOT NULL,
+			email text NOT NULL,
+			encrypted_password text NOT NULL DEFAULT '',
+			confirmed boolean NOT NULL DEFAULT false,
+			last_logged_in timestamptz,
+			max_dwell_minutes integer NOT NULL DEFAULT 0,
+			closing_time text NOT NULL DEFAULT '',
+			timezone text NOT NULL DEFAULT '',
+			require_confirmed boolean NOT NULL DEFAULT false
+		);
+		CREATE INDEX places_email_idx ON places (lower(email));`,
+	},
+	{
+		Version: "places-0002-retention",
+		SQL:     `ALTER TABLE places ADD COLUMN retention_days integer NOT NULL DEFAULT 0;`,
+	},
+	{
+		// emails are stored lower case before they are indexed as unique,
+		// failing, listing their ids, until places whose emails differ only
+		// by case are merged by hand: keeping one, and changing the
+		// others' emails or deleting them after checking with their owners
+		Version: "places-0003-unique-email",
+		SQL: `DO $$
+		DECLARE
+			conflicts text;
+		BEGIN
+			SELECT string_agg(email || ' (' || ids || ')', '; ') INTO conflicts FROM (
+				SELECT lower(trim(email)) AS email, string_agg(id, ', ' ORDER BY id) AS ids
+				FROM places GROUP BY lower(trim(email)) HAVING count(*) > 1
+			) dups;
+			IF conflicts IS NOT NULL THEN
+				RAISE EXCEPTION 'places with emails differing only by case must be merged first: %', conflicts;
+			END IF;
+		END $$;
+		UPDATE places SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+		DROP INDEX places_email_idx;
+		CREATE UNIQUE INDEX places_email_idx ON places (lower(email));`,
+	},
+}
+
+const placeColumns = "id, name, email, encrypted_password, confirmed, last_logged_in, max_dwell_minutes, closing_time, timezone, require_confirmed, retention_days"
+
+// PostgresPlaceRepository -
+type PostgresPlaceRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresPlaceRepository -
+func NewPostgresPlaceRepository(db *sql.DB) *PostgresPlaceRepository {
+	return &PostgresPlaceRepository{db}
+}
+
+// Migrate creates or updates the places table
+func (r *PostgresPlaceRepository) Migrate(ctx context.Context) error {
+	return pg.Migrate(ctx, r.db, PostgresMigrations)
+}
+
+func (r *PostgresPlaceRepository) Get(ctx context.Context, id string) (*t.Place, error) {
+	return scanPlace(r.db.QueryRowContext(ctx, "SELECT "+placeColumns+" FROM places WHERE id = $1", id))
+}
+
+func (r *PostgresPlaceRepository) FindByEmail(ctx context.Context, email string) (*t.Place, error) {
+	return scanPlace(r.db.QueryRowContext(ctx, "SELECT "+placeColumns+" FROM places WHERE lower(email) = lower($1) LIMIT 1", email))
+}
+
+func (r *PostgresPlaceRepository) GetAll(ctx context.Context, req *t.GetPlaces, page *api.PageQuery) ([]*t.Place, error) {
+	args := pg.Args{}
+	where := []string{"true"}
+	if req.Confirmed != nil {
+		where = append(where, "confirmed = "+args.Add(*req.Confirmed))
+	}
+	if req.Search != "" {
+		search := args.Add(req.Search)
+		where = append(where, "(strpos(lower(name), lower("+search+")) > 0 OR strpos(lower(email), lower("+search+")) > 0)")
+	}
+	after, afterID := page.Start()
+	order := pg.Page(&where, &args, page.Field, "id", page.Desc, after, afterID, page.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+placeColumns+" FROM places WHERE "+strings.Join(where, " AND ")+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*t.Place{}
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, place)
+	}
+	return resp, rows.Err()
+}
+
+func (r *PostgresPlaceRepository) Update(ctx context.Context, place *t.UpdatePlace) (*t.Place, error) {
+	set := pg.Assignments{}
+	if place.Name != nil {
+		set.Set("name", *place.Name)
+	}
+	if place.Email != nil {
+		set.Set("email", *place.Email)
+	}
+	if place.Confirmed != nil {
+		set.Set("confirmed", *place.Confirmed)
+	}
+	if place.EncryptedPassword != nil {
+		set.Set("encrypted_password", *place.EncryptedPassword)
+	}
+	if place.LastLoggedIn != nil {
+		set.Set("last_logged_in", *place.LastLoggedIn)
+	}
+	if place.MaxDwellMinutes != nil {
+		set.Set("max_dwell_minutes", *place.MaxDwellMinutes)
+	}
+	if place.ClosingTime != nil {
+		set.Set("closing_time", *place.ClosingTime)
+	}
+	if place.Timezone != nil {
+		set.Set("timezone", *place.Timezone)
+	}
+	if place.RequireConfirmed != nil {
+		set.Set("require_confirmed", *place.RequireConfirmed)
+	}
+	if place.RetentionDays != nil {
+		set.Set("retention_days", *place.RetentionDays)
+	}
+	if set.Empty() {
+		return r.Get(ctx, place.ID)
+	}
+	query := "UPDATE places SET " + set.String() + " WHERE id = " + set.Args.Add(place.ID) + " RETURNING " + placeColumns
+	return scanPlace(r.db.QueryRowContext(ctx, query, set.Args...))
+}
+
+func (r *PostgresPlaceRepository) Create(ctx context.Context, place *t.Place) (*t.Place, error) {
+	place.ID = uuid.New().String()
+	return scanPlace(r.db.QueryRowContext(ctx, "INSERT INTO places ("+placeColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING "+placeColumns,
+		place.ID, place.Name, place.Email, place.EncryptedPassword, place.Confirmed, place.LastLoggedIn, place.MaxDwellMinutes, place.ClosingTime, place.Timezone, place.RequireConfirmed, place.RetentionDays))
+}
+
+func (r *PostgresPlaceRepository) Delete(ctx context.Context, id string) error {
+	return pg.CheckAffected(r.db.ExecContext(ctx, "DELETE FROM places WHERE id = $1", id))
+}
+
+func scanPlace(row pg.Scanner) (*t.Place, error) {
+	var place t.Place
+	if err := row.Scan(&place.ID, &place.Name, &place.Email, &place.EncryptedPassword, &place.Confirmed, &place.LastLoggedIn, &place.MaxDwellMinutes, &place.ClosingTime, &place.Timezone, &place.RequireConfirmed, &place.RetentionDays); err != nil {
+		return nil, err
+	}
+	return &place, nil
+}
@@ -5,10 +5,16 @@ import (
 	// "flag"
 	"io/ioutil"
 	"log"
+	"strconv"
+	"time"
 	// "os"
 
 	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
+	"github.com/contact-tracker/apiService/pkg/email"
+	"github.com/contact-tracker/apiService/pkg/memory"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
+	"github.com/contact-tracker/apiService/pkg/postgres"
 	r "github.com/contact-tracker/apiService/places/repository"
 	t "github.com/contact-tracker/apiService/places/types"
 	// "github.com/joho/godotenv"
@@ -18,22 +24,56 @@ import (
 // PlaceService - is the top level signature of this service
 type PlaceService interface {
 	Get(ctx context.Context, id string) (*t.Place, error)
-	GetAll(ctx context.Context) ([]*t.Place, error)
+	GetAll(ctx context.Context, req *t.GetPlaces) (*t.PlacePage, error)
 	Update(ctx context.Context, place *t.UpdatePlace) (*t.Place, error)
 	Create(ctx context.Context, req *t.CreatePlace) (*t.Place, error)
 	Delete(ctx context.Context, id string) error
 	SignIn(ctx context.Context, req *t.SignInReq) (*t.Place, error)
-	Confirm(ctx context.Context, id string) error
+	Confirm(ctx context.Context, req *t.ConfirmReq) error
+	ResendConfirmation(ctx context.Context, id string) error
+	ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error
+	ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error
+	ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error
+	RevokeSessions(ctx context.Context, id string) error
+}
+
+// Config - settings for Init, as read from the environment. Empty
+// numbers and durations fall back to their defaults.
+type Config struct {
+	MongoDBName string
+	MongoURI    string
+	MongoUser   string
+	MongoPwd    string
+	// PlacesHost - this service's own url, linked to in emails
+	PlacesHost    string
+	JWTKeyPath    string
+	JWTSecretPath string
+	FromEmail     string
+	EmailPwd      string
+	SMTPHost      string
+	SMTPPort      string
+	// Token lifetimes in minutes
+	JWTAccessExpir  string
+	JWTRefreshExpir string
+	JWTConfirmExpir string
+	JWTResetExpir   string
+	// Sign in throttling, see auth.LoginGuardConfig
+	LoginMaxAttempts    string
+	LoginMaxIPAttempts  string
+	LoginLockoutMinutes string
+	// Storage picks the repositories, mongo by default
+	Storage     string
+	PostgresURL string
 }
 
 // Init sets up an instance of this domains
 // usecase, pre-configured with the dependencies.
-func Init(mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, jwtKeyPath, jwtSecretPath, rpcPwd, storePwd string) (PlaceService, *auth.JWTService, error) {
+func Init(cfg Config) (PlaceService, *auth.JWTService, error) {
 	// cfgPath := flag.String("config", "config.dev.yml", "path for yaml config")
 	// flag.Parse()
 	// godotenv.Load(*cfgPath)
 	// mongoDBName := os.Getenv("MONGO_DB_NAME")
-	// mongoHost := os.Getenv("MONGO_HOST")
+	// mongoURI := os.Getenv("MONGO_URI")
 	// mongoPlace := os.Getenv("MONGO_USER")
 	// mongoPwd := os.Getenv("MONGO_PWD")
 	// placesHost := os.Getenv("PLACES_HOST")
@@ -43,28 +83,118 @@ func Init(mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, jwtKeyPath,
 	// sesAccessSecret := os.Getenv("AWS_SES_ACCESS_SECRET")
 	// sesRegion := os.Getenv("AWS_SES_REGION")
 	// senderEmail := os.Getenv("SENDER_EMAIL")
-	// rpcPwd := os.Getenv("RPC_AUTH_PWD")
 
-	// Init mongo repo
-	mc, err := m.NewClient(mongoHost, mongoPlace, mongoPwd)
+	// Init repositories in the configured storage, mongo by default
+	var (
+		repo          repository
+		refreshTokens auth.RefreshTokenStore
+		revocations   auth.RevocationStore
+		loginAttempts auth.LoginAttemptStore
+	)
+	switch cfg.Storage {
+	case memory.Storage:
+		repo = r.NewMemoryPlaceRepository(cfg.MongoDBName)
+		refreshTokens = authRepo.NewMemoryRefreshTokenRepository(cfg.MongoDBName)
+		revocations = authRepo.NewMemoryRevocationRepository(cfg.MongoDBName)
+		loginAttempts = authRepo.NewMemoryLoginAttemptRepository(cfg.MongoDBName)
+	case postgres.Storage:
+		db, err := postgres.NewClient(cfg.PostgresURL)
+		if err != nil {
+			log.Fatalf("Error starting postgres client: Error: %v\n", err)
+		}
+		pgRepo := r.NewPostgresPlaceRepository(db)
+		if err = pgRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating postgres schema: %v\n", err)
+		}
+		if err = authRepo.MigratePostgres(context.Background(), db); err != nil {
+			log.Fatalf("Error migrating postgres auth schema: %v\n", err)
+		}
+		repo = pgRepo
+		refreshTokens = authRepo.NewPostgresRefreshTokenRepository(db)
+		revocations = authRepo.NewPostgresRevocationRepository(db)
+		loginAttempts = authRepo.NewPostgresLoginAttemptRepository(db)
+	default:
+		mc, err := m.NewClient(cfg.MongoURI, cfg.MongoUser, cfg.MongoPwd)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mongoRepo := r.NewMongoPlaceRepository(mc, cfg.MongoDBName)
+		if err = mongoRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating mongo indexes: %v\n", err)
+		}
+		if err = authRepo.MigrateMongo(context.Background(), mc, cfg.MongoDBName); err != nil {
+			log.Fatalf("Error migrating mongo auth indexes: %v\n", err)
+		}
+		repo = mongoRepo
+		refreshTokens = authRepo.NewMongoRefreshTokenRepository(mc, cfg.MongoDBName)
+		revocations = authRepo.NewMongoRevocationRepository(mc, cfg.MongoDBName)
+		loginAttempts = authRepo.NewMongoLoginAttemptRepository(mc, cfg.MongoDBName)
+	}
+
+	// Email
+	emailClient, err := email.NewEmailClient(cfg.FromEmail, cfg.EmailPwd, cfg.SMTPHost, cfg.SMTPPort)
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalf("Error starting email client: Error: %v\n", err)
 	}
-	repo := r.NewMongoPlaceRepository(mc, mongoDBName)
 
 	// Init jwt service
-	jwtKey, err := ioutil.ReadFile(jwtKeyPath)
+	accessExpir, err := parseInt(cfg.JWTAccessExpir, auth.DefaultAccessExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt access expiration minutes: %v\n", err)
+	}
+	if accessExpir <= 0 {
+		log.Fatalf("Error jwt access expiration minutes must be positive: %d\n", accessExpir)
+	}
+	refreshExpir, err := parseInt(cfg.JWTRefreshExpir, auth.DefaultRefreshExpirationMinutes)
 	if err != nil {
-		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", jwtKeyPath, err)
+		log.Fatalf("Error parsing jwt refresh expiration minutes: %v\n", err)
 	}
-	jwtSecret, err := ioutil.ReadFile(jwtSecretPath)
+	confirmExpir, err := parseInt(cfg.JWTConfirmExpir, auth.DefaultConfirmExpirationMinutes)
 	if err != nil {
-		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", jwtSecretPath, err)
+		log.Fatalf("Error parsing jwt confirm expiration minutes: %v\n", err)
+	}
+	resetExpir, err := parseInt(cfg.JWTResetExpir, auth.DefaultResetExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt reset expiration minutes: %v\n", err)
+	}
+
+	// Init sign in throttling
+	maxAttempts, err := parseInt(cfg.LoginMaxAttempts, auth.DefaultLoginMaxAttempts)
+	if err != nil {
+		log.Fatalf("Error parsing login max attempts: %v\n", err)
+	}
+	maxIPAttempts, err := parseInt(cfg.LoginMaxIPAttempts, auth.DefaultLoginMaxIPAttempts)
+	if err != nil {
+		log.Fatalf("Error parsing login max ip attempts: %v\n", err)
+	}
+	lockoutMinutes, err := parseInt(cfg.LoginLockoutMinutes, auth.DefaultLoginLockoutMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing login lockout minutes: %v\n", err)
+	}
+	guard := auth.NewLoginGuard(auth.LoginGuardConfig{
+		Service:        auth.ServicePlaces,
+		Attempts:       loginAttempts,
+		MaxAttempts:    maxAttempts,
+		MaxIPAttempts:  maxIPAttempts,
+		LockoutMinutes: lockoutMinutes,
+	})
+
+	jwtKey, err := ioutil.ReadFile(cfg.JWTKeyPath)
+	if err != nil {
+		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", cfg.JWTKeyPath, err)
+	}
+	jwtSecret, err := ioutil.ReadFile(cfg.JWTSecretPath)
+	if err != nil {
+		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", cfg.JWTSecretPath, err)
 	}
 	j, err := auth.NewJWTService(auth.JWTServiceConfig{
-		Key:    jwtKey,
-		Secret: jwtSecret,
-		RPCPwd: rpcPwd,
+		Key:                      jwtKey,
+		Secret:                   jwtSecret,
+		Service:                  auth.ServicePlaces,
+		AccessExpirationMinutes:  accessExpir,
+		RefreshExpirationMinutes: refreshExpir,
+		RefreshTokens:            refreshTokens,
+		Revocations:              revocations,
 	})
 	if err != nil {
 		log.Fatalf("Error creating jwt service: %v\n", err)
@@ -73,15 +203,22 @@ func Init(mongoDBName, mongoHost, mongoPlace, mongoPwd, placesHost, jwtKeyPath,
 	// logger, _ := zap.NewProduction()
 
 	usecase := &Usecase{
-		Repository: repo,
-		placesHost: placesHost,
-		storePwd:   storePwd,
-		adminPlace: &t.Place{
-			ID:        "Admin",
-			Email:     "Admin",
-			Name:      "Admin",
-			Confirmed: true,
-		},
+		Repository:        repo,
+		Sessions:          j,
+		Tokens:            j,
+		Guard:             guard,
+		EmailClient:       emailClient,
+		ConfirmExpiration: time.Duration(confirmExpir) * time.Minute,
+		ResetExpiration:   time.Duration(resetExpir) * time.Minute,
+		placesHost:        cfg.PlacesHost,
 	}
 	return usecase, j, nil
 }
+
+// parseInt parses an integer config value, using def when empty
+func parseInt(val string, def int) (int, error) {
+	if val == "" {
+		return def, nil
+	}
+	return strconv.Atoi(val)
+}
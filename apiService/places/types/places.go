@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
 
 // Place -
 type Place struct {
@@ -10,13 +16,100 @@ type Place struct {
 	EncryptedPassword string     `bson:"pwd" json:"-"`
 	Confirmed         bool       `bson:"conf" json:"confirmed"`
 	LastLoggedIn      *time.Time `bson:"lstLogIn" json:"lastLoggedIn"`
-	AuthToken         string     `bson:"-" json:"authToken"`
+	MaxDwellMinutes   int        `bson:"maxDwell,omitempty" json:"maxDwellMinutes,omitempty"`
+	ClosingTime       string     `bson:"closing,omitempty" json:"closingTime,omitempty"`
+	Timezone          string     `bson:"tz,omitempty" json:"timezone,omitempty"`
+	// RequireConfirmed only lets users who have confirmed their email check in
+	RequireConfirmed bool `bson:"reqConf,omitempty" json:"requireConfirmed,omitempty"`
+	// RetentionDays purges check ins at this place sooner than the
+	// deployment's retention period, which it can't extend
+	RetentionDays int    `bson:"retention,omitempty" json:"retentionDays,omitempty"`
+	AuthToken     string `bson:"-" json:"authToken"`
+	RefreshToken  string `bson:"-" json:"refreshToken,omitempty"`
 }
 
 func (u Place) GetAuthables() (id, email string, conf bool) {
 	return u.ID, u.Email, u.Confirmed
 }
 
+func (u Place) GetAuthRoles() (roles []auth.Role, placeID string) {
+	return []auth.Role{auth.RolePlaceAdmin}, u.ID
+}
+
+// PlaceSorts - fields places may be sorted by, the first by default
+var PlaceSorts = []string{"name", "email"}
+
+// SortValue returns the value of one of PlaceSorts
+func (u Place) SortValue(field string) string {
+	if field == "email" {
+		return u.Email
+	}
+	return u.Name
+}
+
+// GetPlaces - filters and page of a list of places
+type GetPlaces struct {
+	api.PageRequest
+	Confirmed *bool `json:"confirmed"`
+	// Search matches part of a place's name or email, ignoring case
+	Search string `json:"search"`
+}
+
+// PlacePage - a page of places
+type PlacePage struct {
+	Items []*Place `json:"items"`
+	api.PageInfo
+}
+
+// CloseBy returns when a check in at this place starting at in should be
+// automatically checked out. This is the earlier of the place's maximum
+// dwell time, or defaultMaxDwell if it has none, and its next closing time.
+func (u Place) CloseBy(in time.Time, defaultMaxDwell time.Duration) time.Time {
+	maxDwell := defaultMaxDwell
+	if u.MaxDwellMinutes > 0 {
+		maxDwell = time.Duration(u.MaxDwellMinutes) * time.Minute
+	}
+	closeBy := in.Add(maxDwell)
+	if closing, err := u.NextClosing(in); err == nil && closing.Before(closeBy) {
+		closeBy = closing
+	}
+	return closeBy
+}
+
+// PurgeAfter returns when a check in at this place starting at in is
+// purged under the place's retention, or nil if it has none
+func (u Place) PurgeAfter(in time.Time) *time.Time {
+	if u.RetentionDays <= 0 {
+		return nil
+	}
+	purgeAfter := in.AddDate(0, 0, u.RetentionDays)
+	return &purgeAfter
+}
+
+// NextClosing returns the first closing time after t
+func (u Place) NextClosing(t time.Time) (time.Time, error) {
+	if u.ClosingTime == "" {
+		return time.Time{}, fmt.Errorf("place has no closing time")
+	}
+	loc := time.UTC
+	if u.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(u.Timezone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	closing, err := time.ParseInLocation("15:04", u.ClosingTime, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := t.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), closing.Hour(), closing.Minute(), 0, 0, loc)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
 // UpdatePlace -
 type UpdatePlace struct {
 	ID                string     `bson:"-" json:"id"`
@@ -25,18 +118,33 @@ type UpdatePlace struct {
 	Confirmed         *bool      `bson:"conf,omitempty" json:"-"`
 	EncryptedPassword *string    `bson:"pwd,omitempty" json:"-"`
 	LastLoggedIn      *time.Time `bson:"lstLogIn,omitempty" json:"-"`
+	MaxDwellMinutes   *int       `bson:"maxDwell,omitempty" json:"maxDwellMinutes,omitempty" validate:"omitempty,gte=0"`
+	ClosingTime       *string    `bson:"closing,omitempty" json:"closingTime,omitempty"`
+	Timezone          *string    `bson:"tz,omitempty" json:"timezone,omitempty"`
+	RequireConfirmed  *bool      `bson:"reqConf,omitempty" json:"requireConfirmed,omitempty"`
+	RetentionDays     *int       `bson:"retention,omitempty" json:"retentionDays,omitempty" validate:"omitempty,gte=0"`
 }
 
 // CreatePlace -
 type CreatePlace struct {
-	Email    string `json:"email"`
-	Name     string `json:"name" validate:"required,gte=3,lte=50"`
-	Password string `json:"password" validate:"gte=3,lte=50"`
+	Email            string `json:"email"`
+	Name             string `json:"name" validate:"required,gte=3,lte=50"`
+	Password         string `json:"password" validate:"gte=3,lte=50"`
+	MaxDwellMinutes  int    `json:"maxDwellMinutes" validate:"gte=0"`
+	ClosingTime      string `json:"closingTime"`
+	Timezone         string `json:"timezone"`
+	RequireConfirmed bool   `json:"requireConfirmed"`
+	RetentionDays    int    `json:"retentionDays" validate:"gte=0"`
 }
 
 func (c CreatePlace) ToPlace() *Place {
 	return &Place{
-		Email: c.Email,
-		Name:  c.Name,
+		Email:            c.Email,
+		Name:             c.Name,
+		MaxDwellMinutes:  c.MaxDwellMinutes,
+		ClosingTime:      c.ClosingTime,
+		Timezone:         c.Timezone,
+		RequireConfirmed: c.RequireConfirmed,
+		RetentionDays:    c.RetentionDays,
 	}
 }
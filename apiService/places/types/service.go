@@ -2,5 +2,14 @@ package types
 
 // SignInReq - request to check in place at place
 type SignInReq struct {
+	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	// IP of the client signing in, to throttle failed attempts
+	IP string `json:"-"`
+}
+
+// ConfirmReq - request to confirm a place's email with an emailed token
+type ConfirmReq struct {
+	ID    string `json:"-"`
+	Token string `json:"token" validate:"required"`
 }
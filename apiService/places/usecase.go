@@ -3,9 +3,13 @@ package places
 import (
 	"context"
 	"fmt"
+	"log"
+	"net/url"
 	"time"
 
+	api "github.com/contact-tracker/apiService/pkg/api/http"
 	"github.com/contact-tracker/apiService/pkg/auth"
+	"github.com/contact-tracker/apiService/pkg/email"
 	t "github.com/contact-tracker/apiService/places/types"
 
 	"github.com/google/uuid"
@@ -19,19 +23,40 @@ var (
 
 type repository interface {
 	Get(ctx context.Context, id string) (*t.Place, error)
-	GetAll(ctx context.Context) ([]*t.Place, error)
+	GetAll(ctx context.Context, req *t.GetPlaces, page *api.PageQuery) ([]*t.Place, error)
 	Update(ctx context.Context, place *t.UpdatePlace) (*t.Place, error)
 	Create(ctx context.Context, place *t.Place) (*t.Place, error)
 	Delete(ctx context.Context, id string) error
 	FindByEmail(ctx context.Context, email string) (*t.Place, error)
 }
 
+type sessions interface {
+	RevokeAll(ctx context.Context, subject string) error
+}
+
+type loginGuard interface {
+	Check(ctx context.Context, email, ip string) error
+	Fail(ctx context.Context, email, ip string) error
+	Succeed(ctx context.Context, email string) error
+}
+
+type actionTokens interface {
+	GenActionToken(subject, use string, expiresIn time.Duration) (string, error)
+	UseActionToken(ctx context.Context, token, use, subject string) (*auth.CustomClaims, error)
+}
+
 // Usecase for interacting with places
 type Usecase struct {
-	Repository repository
-	placesHost string
-	storePwd   string
-	adminPlace *t.Place
+	Repository  repository
+	Sessions    sessions
+	Tokens      actionTokens
+	Guard       loginGuard
+	EmailClient *email.EmailClient
+	// ConfirmExpiration is how long an emailed confirmation link is valid for
+	ConfirmExpiration time.Duration
+	// ResetExpiration is how long an emailed password reset token is valid for
+	ResetExpiration time.Duration
+	placesHost      string
 }
 
 // Get a single place
@@ -43,13 +68,29 @@ func (u *Usecase) Get(ctx context.Context, id string) (*t.Place, error) {
 	return place, nil
 }
 
-// GetAll gets all places
-func (u *Usecase) GetAll(ctx context.Context) ([]*t.Place, error) {
-	places, err := u.Repository.GetAll(ctx)
+// GetAll gets a page of places
+func (u *Usecase) GetAll(ctx context.Context, req *t.GetPlaces) (*t.PlacePage, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	page, err := req.Query(t.PlaceSorts...)
+	if err != nil {
+		return nil, err
+	}
+
+	places, err := u.Repository.GetAll(ctx, req, page)
 	if err != nil {
 		return nil, errors.Wrap(err, "error fetching all places")
 	}
-	return places, nil
+	resp := &t.PlacePage{Items: places, PageInfo: api.PageInfo{Limit: page.Limit}}
+	if len(places) > page.Limit {
+		resp.Items = places[:page.Limit]
+		last := resp.Items[page.Limit-1]
+		resp.NextCursor = page.NextCursor(last.SortValue(page.Field), last.ID)
+	}
+	return resp, nil
 }
 
 // Update a single place
@@ -60,9 +101,39 @@ func (u *Usecase) Update(ctx context.Context, place *t.UpdatePlace) (resp *t.Pla
 		return nil, validationErrors
 	}
 
+	if place.Timezone != nil {
+		if _, err = time.LoadLocation(*place.Timezone); err != nil {
+			return nil, errors.Wrap(err, "error invalid timezone")
+		}
+	}
+	if place.ClosingTime != nil && *place.ClosingTime != "" {
+		if _, err = time.Parse("15:04", *place.ClosingTime); err != nil {
+			return nil, errors.Wrap(err, "error invalid closing time")
+		}
+	}
+
+	// A new email must be confirmed again before the place counts as confirmed
+	emailChanged := false
+	if place.Email != nil {
+		normalized := email.Normalize(*place.Email)
+		place.Email = &normalized
+		var current *t.Place
+		if current, err = u.Repository.Get(ctx, place.ID); err != nil {
+			return nil, errors.Wrap(err, "error getting place to update")
+		}
+		if emailChanged = normalized != current.Email; emailChanged {
+			unconfirmed := false
+			place.Confirmed = &unconfirmed
+		}
+	}
 	if resp, err = u.Repository.Update(ctx, place); err != nil {
 		return nil, errors.Wrap(err, "error updating place")
 	}
+	if emailChanged {
+		if err := u.sendConfirmation(resp); err != nil {
+			log.Printf("Error sending confirmation email to place %s: %v\n", resp.ID, err)
+		}
+	}
 	return resp, nil
 }
 
@@ -74,6 +145,7 @@ func (u *Usecase) Create(ctx context.Context, req *t.CreatePlace) (resp *t.Place
 		return nil, validationErrors
 	}
 
+	req.Email = email.Normalize(req.Email)
 	if _, err := u.Repository.FindByEmail(ctx, req.Email); err == nil {
 		return nil, errors.New("error place for email already exists")
 	}
@@ -82,6 +154,14 @@ func (u *Usecase) Create(ctx context.Context, req *t.CreatePlace) (resp *t.Place
 	place.ID = u.newID()
 	now := time.Now()
 	place.LastLoggedIn = &now
+	if _, err = time.LoadLocation(place.Timezone); err != nil {
+		return nil, errors.Wrap(err, "error invalid timezone")
+	}
+	if place.ClosingTime != "" {
+		if _, err = place.NextClosing(now); err != nil {
+			return nil, errors.Wrap(err, "error invalid closing time")
+		}
+	}
 	if place.EncryptedPassword, err = auth.EncryptPassword(req.Password); err != nil {
 		return nil, errors.Wrap(err, "error encrypting password")
 	}
@@ -90,6 +170,12 @@ func (u *Usecase) Create(ctx context.Context, req *t.CreatePlace) (resp *t.Place
 		return nil, errors.Wrap(err, "error creating new place")
 	}
 
+	// The account exists either way, so a failed email is only logged
+	// and the place can ask for another
+	if err := u.sendConfirmation(resp); err != nil {
+		log.Printf("Error sending confirmation email to place %s: %v\n", resp.ID, err)
+	}
+
 	return resp, nil
 }
 
@@ -102,49 +188,176 @@ func (u *Usecase) Delete(ctx context.Context, id string) error {
 }
 
 // SignIn -
-func (u *Usecase) SignIn(ctx context.Context, req *t.SignInReq) (place *t.Place, err error) {
+func (u *Usecase) SignIn(ctx context.Context, req *t.SignInReq) (resp *t.Place, err error) {
 	validate = validator.New()
 	if err = validate.Struct(req); err != nil {
 		return nil, err.(validator.ValidationErrors)
 	}
 
-	// place, err := u.Repository.FindByEmail(ctx, req.Email)
-	// if err != nil {
-	// 	return nil, errors.Wrap(err, "error finding place by email")
-	// }
-	// if err = auth.ValidateCredentials(place.EncryptedPassword, req.Password); err != nil {
-	// 	return nil, errors.Wrap(err, "error validating credentials")
-	// }
-
-	// now := time.Now()
-	// if resp, err = u.Repository.Update(ctx, &t.UpdatePlace{ID: place.ID, LastLoggedIn: &now}); err != nil {
-	// 	return nil, errors.Wrap(err, "error updating place")
-	// }
+	if err = u.Guard.Check(ctx, req.Email, req.IP); err != nil {
+		return nil, err
+	}
+	place, err := u.Repository.FindByEmail(ctx, req.Email)
+	if err == nil {
+		err = auth.ValidateCredentials(place.EncryptedPassword, req.Password)
+	}
+	if err != nil {
+		if err = u.Guard.Fail(ctx, req.Email, req.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrap(auth.ErrInvalidCredentials, "error validating credentials")
+	}
+	if err = u.Guard.Succeed(ctx, req.Email); err != nil {
+		return nil, errors.Wrap(err, "error clearing failed sign ins")
+	}
 
-	if req.Password != u.storePwd {
-		return nil, errors.New("Invalid store owner password")
+	now := time.Now()
+	if resp, err = u.Repository.Update(ctx, &t.UpdatePlace{ID: place.ID, LastLoggedIn: &now}); err != nil {
+		return nil, errors.Wrap(err, "error updating place")
 	}
-	place = u.adminPlace
-	return
+
+	return resp, nil
 }
 
-// Confirm a place
-func (u *Usecase) Confirm(ctx context.Context, id string) error {
-	place, err := u.Repository.Get(ctx, id)
+// Confirm a place's email with the token from its confirmation email
+func (u *Usecase) Confirm(ctx context.Context, req *t.ConfirmReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	if _, err := u.Tokens.UseActionToken(ctx, req.Token, auth.TokenUseConfirm, req.ID); err != nil {
+		return err
+	}
+	place, err := u.Repository.Get(ctx, req.ID)
 	if err != nil {
-		errors.Wrap(err, "error getting place for confirmation")
+		return errors.Wrap(err, "error getting place for confirmation")
 	}
 	if place.Confirmed {
 		return fmt.Errorf("place already confirmed")
 	}
 
 	c := true
-	if _, err := u.Repository.Update(ctx, &t.UpdatePlace{ID: id, Confirmed: &c}); err != nil {
+	if _, err := u.Repository.Update(ctx, &t.UpdatePlace{ID: req.ID, Confirmed: &c}); err != nil {
 		return errors.Wrap(err, "error confirming place")
 	}
 	return nil
 }
 
+// ResendConfirmation emails an unconfirmed place a new confirmation link
+func (u *Usecase) ResendConfirmation(ctx context.Context, id string) error {
+	place, err := u.Repository.Get(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "error getting place for confirmation")
+	}
+	if place.Confirmed {
+		return fmt.Errorf("place already confirmed")
+	}
+	if err = u.sendConfirmation(place); err != nil {
+		return errors.Wrap(err, "error sending confirmation email")
+	}
+	return nil
+}
+
+// sendConfirmation emails the place a link to confirm its email address
+func (u *Usecase) sendConfirmation(place *t.Place) error {
+	token, err := u.Tokens.GenActionToken(place.ID, auth.TokenUseConfirm, u.ConfirmExpiration)
+	if err != nil {
+		return errors.Wrap(err, "error generating confirmation token")
+	}
+	link := fmt.Sprintf("%s/places/%s/confirm?token=%s", u.placesHost, place.ID, url.QueryEscape(token))
+	return u.EmailClient.SendEmail(
+		place.Email,
+		"Confirm Your Contact Tracker Place",
+		fmt.Sprintf("Hello %s,\n\nPlease confirm your place's email address by opening the link below.\n\n%s\n\nThank You,\nContact Tracker Team", place.Name, link),
+	)
+}
+
+// RevokeSessions signs a place out everywhere by revoking every token issued to them
+func (u *Usecase) RevokeSessions(ctx context.Context, id string) error {
+	if _, err := u.Repository.Get(ctx, id); err != nil {
+		return errors.Wrap(err, "error getting place to revoke sessions")
+	}
+	if err := u.Sessions.RevokeAll(ctx, id); err != nil {
+		return errors.Wrap(err, "error revoking place sessions")
+	}
+	return nil
+}
+
+// ForgotPassword emails a password reset token to the place with the given
+// email. Unknown emails succeed silently so they can't be used to find accounts.
+func (u *Usecase) ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	place, err := u.Repository.FindByEmail(ctx, req.Email)
+	if err != nil {
+		return nil
+	}
+	token, err := u.Tokens.GenActionToken(place.ID, auth.TokenUseReset, u.ResetExpiration)
+	if err != nil {
+		return errors.Wrap(err, "error generating password reset token")
+	}
+	if err = u.EmailClient.SendEmail(
+		place.Email,
+		"Reset Your Contact Tracker Password",
+		fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Use the token below to choose a new password at %s/places/password/reset. If you did not ask to reset your password, you can ignore this email.\n\n%s\n\nThank You,\nContact Tracker Team", place.Name, u.placesHost, token),
+	); err != nil {
+		return errors.Wrap(err, "error sending password reset email")
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with an emailed reset token, signing
+// the place out everywhere
+func (u *Usecase) ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	claims, err := u.Tokens.UseActionToken(ctx, req.Token, auth.TokenUseReset, "")
+	if err != nil {
+		return err
+	}
+	return u.setPassword(ctx, claims.Subject, req.Password)
+}
+
+// ChangePassword of a signed in place, who must know their old password,
+// signing them out everywhere
+func (u *Usecase) ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	place, err := u.Repository.Get(ctx, req.ID)
+	if err != nil {
+		return errors.Wrap(err, "error getting place to change password")
+	}
+	if err = auth.ValidateCredentials(place.EncryptedPassword, req.OldPassword); err != nil {
+		return errors.Wrap(auth.ErrForbidden, "error validating old password")
+	}
+	return u.setPassword(ctx, place.ID, req.Password)
+}
+
+// setPassword stores a new password and revokes every token issued with the old one
+func (u *Usecase) setPassword(ctx context.Context, id, password string) error {
+	encrypted, err := auth.EncryptPassword(password)
+	if err != nil {
+		return errors.Wrap(err, "error encrypting password")
+	}
+	if _, err = u.Repository.Update(ctx, &t.UpdatePlace{ID: id, EncryptedPassword: &encrypted}); err != nil {
+		return errors.Wrap(err, "error updating place password")
+	}
+	if err = u.Sessions.RevokeAll(ctx, id); err != nil {
+		return errors.Wrap(err, "error revoking place sessions")
+	}
+	return nil
+}
+
 func (u *Usecase) newID() string {
 	uid := uuid.New()
 	return uid.String()
@@ -1,12 +1,15 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
 
 	api "github.com/contact-tracker/apiService/pkg/api/http"
 	"github.com/contact-tracker/apiService/pkg/auth"
@@ -34,8 +37,11 @@ func (d *handler) Get() http.HandlerFunc {
 func (d *handler) GetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		users, err := d.Usecase.GetAll(ctx)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
+		req := &t.GetUsers{}
+		api.ParseHTTPParams(r, req)
+
+		users, err := d.Usecase.GetAll(ctx, req)
+		api.CheckHTTPError(api.PageErrorCode(err), err)
 		api.WriteJSON(w, http.StatusOK, users)
 	}
 }
@@ -58,9 +64,10 @@ func (d *handler) SignIn() http.HandlerFunc {
 		ctx := r.Context()
 		req := &t.SignInReq{}
 		api.ParseHTTPParams(r, req)
+		req.IP = api.GetClientIP(r)
 		user, err := d.Usecase.SignIn(ctx, req)
-		api.CheckHTTPError(http.StatusInternalServerError, err)
-		user.AuthToken, err = d.jwt.GenAccessToken(user)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		user.AuthToken, user.RefreshToken, err = d.jwt.GenTokens(ctx, user)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, user)
 	}
@@ -73,7 +80,7 @@ func (d *handler) Create() http.HandlerFunc {
 		api.ParseHTTPParams(r, req)
 		user, err := d.Usecase.Create(ctx, req)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
-		user.AuthToken, err = d.jwt.GenAccessToken(user)
+		user.AuthToken, user.RefreshToken, err = d.jwt.GenTokens(ctx, user)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, user)
 	}
@@ -91,20 +98,149 @@ func (d *handler) Delete() http.HandlerFunc {
 }
 
 func (d *handler) Confirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.ConfirmReq{
+			ID:    chi.URLParam(r, "id"),
+			Token: r.URL.Query().Get("token"),
+		}
+
+		err := d.Usecase.Confirm(ctx, req)
+		api.CheckHTTPError(http.StatusUnprocessableEntity, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ResendConfirmation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+
+		err := d.Usecase.ResendConfirmation(ctx, id)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) AlertUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.AlertUsers{}
+		api.ParseHTTPParams(r, req)
+
+		err := d.Usecase.AlertUsers(ctx, req.IDs)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) UpdateRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &t.UpdateRoles{}
+		api.ParseHTTPParams(r, req)
+
+		req.ID = chi.URLParam(r, "id")
+		resp, err := d.Usecase.UpdateRoles(ctx, req)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (d *handler) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.RefreshReq{}
+		api.ParseHTTPParams(r, req)
+
+		var user *t.User
+		accessToken, refreshToken, err := d.jwt.Refresh(ctx, req.RefreshToken, func(ctx context.Context, id string) (auth.Authable, error) {
+			var err error
+			user, err = d.Usecase.Get(ctx, id)
+			return user, err
+		})
+		api.CheckHTTPError(auth.StatusCode(err), err)
+		user.AuthToken, user.RefreshToken = accessToken, refreshToken
+		api.WriteJSON(w, http.StatusOK, user)
+	}
+}
+
+func (d *handler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.LogoutReq{}
+		if r.ContentLength != 0 {
+			api.ParseHTTPParams(r, req)
+		}
+
+		_, claims := auth.ClaimsFromContext(ctx)
+		err := d.jwt.Logout(ctx, claims, req.RefreshToken)
+		api.CheckHTTPError(auth.StatusCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) RevokeSessions() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		id := chi.URLParam(r, "id")
 
-		err := d.Usecase.Confirm(ctx, id)
+		err := d.Usecase.RevokeSessions(ctx, id)
 		api.CheckHTTPError(http.StatusInternalServerError, err)
 		api.WriteJSON(w, http.StatusOK, nil)
 	}
 }
 
-func NewServer(port, mongoDBName, mongoHost, mongoPlace, mongoPwd, jwtKeyPath, jwtSecretPath, fromEmail, emailPwd, smtpHost, smtpPort, rpcPwd string) (server *api.Server, service *users.UserService, err error) {
+func (d *handler) ForgotPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ForgotPasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		err := d.Usecase.ForgotPassword(ctx, req)
+		api.CheckHTTPError(http.StatusInternalServerError, err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ResetPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ResetPasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		err := d.Usecase.ResetPassword(ctx, req)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (d *handler) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &auth.ChangePasswordReq{}
+		api.ParseHTTPParams(r, req)
+
+		req.ID = chi.URLParam(r, "id")
+		err := d.Usecase.ChangePassword(ctx, req)
+		api.CheckHTTPError(passwordErrorCode(err), err)
+		api.WriteJSON(w, http.StatusOK, nil)
+	}
+}
+
+// passwordErrorCode rejects bad credentials, reset tokens and old
+// passwords, and invalid requests, as the caller's fault
+func passwordErrorCode(err error) int {
+	if _, ok := errors.Cause(err).(validator.ValidationErrors); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return auth.StatusCode(err)
+}
+
+func NewServer(port string, cfg users.Config) (server *api.Server, service *users.UserService, err error) {
 	fmt.Printf("Listening for users on %s...\n", port)
 
-	svc, j, err := users.Init(mongoDBName, mongoHost, mongoPlace, mongoPwd, port, jwtKeyPath, jwtSecretPath, fromEmail, emailPwd, smtpHost, smtpPort, rpcPwd)
+	svc, j, err := users.Init(cfg)
 	if err != nil {
 		log.Panic(err)
 		return nil, nil, err
@@ -117,14 +253,27 @@ func NewServer(port, mongoDBName, mongoHost, mongoPlace, mongoPwd, jwtKeyPath, j
 	}
 
 	server = api.NewServer(port)
-	r := server.Router
-	r.Post("/users", h.Create())
-	r.Get("/users", j.AuthorizeHandler(h.GetAll()))
-	r.Get("/users/{id}", j.AuthorizeHandler(h.Get()))
-	r.Put("/users/{id}", j.AuthorizeHandler(h.Update()))
-	r.Delete("/users/{id}", j.AuthorizeHandler(h.Delete()))
-	r.Post("/users/login", h.SignIn())
-	r.Get("/users/{id}/confirm", h.Confirm())
-
+	h.routes(server.Router)
 	return
 }
+
+// routes registers the users routes, each authorized by its policy
+func (d *handler) routes(r chi.Router) {
+	j := d.jwt
+	r.Post("/users", d.Create())
+	r.Get("/users", j.AuthorizeHandler(users.GetAllPolicy, d.GetAll()))
+	r.Get("/users/{id}", j.AuthorizeHandler(users.GetPolicy, d.Get()))
+	r.Put("/users/{id}", j.AuthorizeHandler(users.UpdatePolicy, d.Update()))
+	r.Delete("/users/{id}", j.AuthorizeHandler(users.DeletePolicy, d.Delete()))
+	r.Post("/users/login", d.SignIn())
+	r.Post("/users/refresh", d.Refresh())
+	r.Post("/users/logout", j.AuthorizeHandler(users.LogoutPolicy, d.Logout()))
+	r.Post("/users/{id}/revoke", j.AuthorizeHandler(users.RevokeSessionsPolicy, d.RevokeSessions()))
+	r.Post("/users/alert", j.AuthorizeHandler(users.AlertPolicy, d.AlertUsers()))
+	r.Put("/users/{id}/roles", j.AuthorizeHandler(users.UpdateRolesPolicy, d.UpdateRoles()))
+	r.Get("/users/{id}/confirm", d.Confirm())
+	r.Post("/users/password/forgot", d.ForgotPassword())
+	r.Post("/users/password/reset", d.ResetPassword())
+	r.Put("/users/{id}/password", j.AuthorizeHandler(users.ChangePasswordPolicy, d.ChangePassword()))
+	r.Post("/users/{id}/confirm/resend", j.AuthorizeHandler(users.ResendConfirmationPolicy, d.ResendConfirmation()))
+}
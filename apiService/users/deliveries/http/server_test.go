@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
+	"github.com/contact-tracker/apiService/users"
+	repo "github.com/contact-tracker/apiService/users/repository"
+	t "github.com/contact-tracker/apiService/users/types"
+)
+
+// newTestJWTService returns a jwt service signing with a new key
+func newTestJWTService(tt *testing.T) *auth.JWTService {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tt.Fatalf("Error generating key: %v", err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		tt.Fatalf("Error encoding public key: %v", err)
+	}
+	j, err := auth.NewJWTService(auth.JWTServiceConfig{
+		Key:                     pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}),
+		Secret:                  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		Service:                 auth.ServiceUsers,
+		AccessExpirationMinutes: 5,
+	})
+	if err != nil {
+		tt.Fatalf("Error creating jwt service: %v", err)
+	}
+	return j
+}
+
+func TestUpdateRolesRoute(tt *testing.T) {
+	j := newTestJWTService(tt)
+	repository := repo.NewMemoryUserRepository("users-http-test")
+	user, err := repository.Create(context.Background(), &t.User{Email: "roles@example.com", Name: "Roles"})
+	if err != nil {
+		tt.Fatalf("Error creating user: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Use(api.Recoverer)
+	h := &handler{Usecase: &users.Usecase{Repository: repository}, jwt: j}
+	h.routes(r)
+
+	tests := []struct {
+		name   string
+		roles  []auth.Role
+		status int
+	}{
+		{"signed out", nil, http.StatusUnauthorized},
+		{"customer", []auth.Role{auth.RoleCustomer}, http.StatusForbidden},
+		{"health official", []auth.Role{auth.RoleHealthOfficial}, http.StatusForbidden},
+		{"admin", []auth.Role{auth.RoleAdmin}, http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/users/"+user.ID+"/roles", strings.NewReader(`{"roles": ["healthOfficial"]}`))
+		if test.roles != nil {
+			token, err := j.GenAccessToken(t.User{ID: "caller", Email: "caller@example.com", Roles: test.roles})
+			if err != nil {
+				tt.Fatalf("%s: error generating token: %v", test.name, err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.status {
+			tt.Errorf("%s: got status %d, want %d: %s", test.name, w.Code, test.status, w.Body.String())
+		}
+	}
+
+	updated, err := repository.Get(context.Background(), user.ID)
+	if err != nil {
+		tt.Fatalf("Error getting user: %v", err)
+	}
+	if len(updated.Roles) != 1 || updated.Roles[0] != auth.RoleHealthOfficial {
+		tt.Errorf("Got roles %v, want [%s]", updated.Roles, auth.RoleHealthOfficial)
+	}
+}
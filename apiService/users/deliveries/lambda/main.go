@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	pkgErrors "github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
 
+	apiHttp "github.com/contact-tracker/apiService/pkg/api/http"
 	api "github.com/contact-tracker/apiService/pkg/api/lambda"
 	"github.com/contact-tracker/apiService/pkg/auth"
 	"github.com/contact-tracker/apiService/users"
@@ -22,11 +26,8 @@ type handler struct {
 	jwt     *auth.JWTService
 }
 
-func isAuthorized(ctx context.Context) error {
-	if authorized, _ := auth.ClaimsFromContext(ctx); !authorized {
-		return errors.New("Unauthorized")
-	}
-	return nil
+func isAuthorized(ctx context.Context, req *api.Request, policy auth.Policy) error {
+	return policy.AuthorizeLambda(ctx, req)
 }
 
 func (h handler) router() func(context.Context, api.Request) (api.Response, error) {
@@ -36,39 +37,78 @@ func (h handler) router() func(context.Context, api.Request) (api.Response, erro
 		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 		defer cancel()
 
+		// Public routes, reachable with an expired access token
+		if api.MatchesRoute("/users", "POST", &req) {
+			return h.Create(ctx, &req)
+		} else if api.MatchesRoute("/users/login", "POST", &req) {
+			return h.SignIn(ctx, &req)
+		} else if api.MatchesRoute("/users/refresh", "POST", &req) {
+			return h.Refresh(ctx, &req)
+		} else if api.MatchesRoute("/users/{id}/confirm", "GET", &req) {
+			return h.Confirm(ctx, &req)
+		} else if api.MatchesRoute("/users/password/forgot", "POST", &req) {
+			return h.ForgotPassword(ctx, &req)
+		} else if api.MatchesRoute("/users/password/reset", "POST", &req) {
+			return h.ResetPassword(ctx, &req)
+		}
+
 		// Add auth
 		var err error
 		if ctx, err = h.jwt.IncludeLambdaAuth(ctx, &req); err != nil {
-			return api.Fail(err, http.StatusInternalServerError)
+			return api.Fail(err, http.StatusUnauthorized)
 		}
 
 		// Routes
-		if api.MatchesRoute("/users", "POST", &req) {
-			return h.Create(ctx, &req)
-		} else if api.MatchesRoute("/users/{id}", "GET", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+		if api.MatchesRoute("/users/{id}", "GET", &req) {
+			if err := isAuthorized(ctx, &req, users.GetPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.Get(ctx, &req)
 		} else if api.MatchesRoute("/users", "GET", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, users.GetAllPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.GetAll(ctx, &req)
 		} else if api.MatchesRoute("/users/{id}", "PUT", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, users.UpdatePolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.Update(ctx, &req)
 		} else if api.MatchesRoute("/users/{id}", "DELETE", &req) {
-			if err := isAuthorized(ctx); err != nil {
-				return api.Fail(err, http.StatusUnauthorized)
+			if err := isAuthorized(ctx, &req, users.DeletePolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
 			}
 			return h.Delete(ctx, &req)
-		} else if api.MatchesRoute("/users/login", "POST", &req) {
-			return h.SignIn(ctx, &req)
-		} else if api.MatchesRoute("/users/{id}/confirm", "GET", &req) {
-			return h.Confirm(ctx, &req)
+		} else if api.MatchesRoute("/users/alert", "POST", &req) {
+			if err := isAuthorized(ctx, &req, users.AlertPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.AlertUsers(ctx, &req)
+		} else if api.MatchesRoute("/users/{id}/roles", "PUT", &req) {
+			if err := isAuthorized(ctx, &req, users.UpdateRolesPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.UpdateRoles(ctx, &req)
+		} else if api.MatchesRoute("/users/logout", "POST", &req) {
+			if err := isAuthorized(ctx, &req, users.LogoutPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.Logout(ctx, &req)
+		} else if api.MatchesRoute("/users/{id}/confirm/resend", "POST", &req) {
+			if err := isAuthorized(ctx, &req, users.ResendConfirmationPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.ResendConfirmation(ctx, &req)
+		} else if api.MatchesRoute("/users/{id}/password", "PUT", &req) {
+			if err := isAuthorized(ctx, &req, users.ChangePasswordPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.ChangePassword(ctx, &req)
+		} else if api.MatchesRoute("/users/{id}/revoke", "POST", &req) {
+			if err := isAuthorized(ctx, &req, users.RevokeSessionsPolicy); err != nil {
+				return api.Fail(err, auth.StatusCode(err))
+			}
+			return h.RevokeSessions(ctx, &req)
 		} else {
 			return api.Fail(errors.New("not found"), http.StatusNotFound)
 		}
@@ -89,10 +129,14 @@ func (h *handler) Get(ctx context.Context, req *api.Request) (resp api.Response,
 }
 
 // GetAll users
-func (h *handler) GetAll(ctx context.Context, _ *api.Request) (resp api.Response, err error) {
-	var users []*t.User
-	if users, err = h.usecase.GetAll(ctx); err != nil {
-		return api.Fail(err, http.StatusInternalServerError)
+func (h *handler) GetAll(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var query t.GetUsers
+	if err = api.ParseQuery(req, &query); err != nil {
+		return api.Fail(err, http.StatusUnprocessableEntity)
+	}
+	var users *t.UserPage
+	if users, err = h.usecase.GetAll(ctx, &query); err != nil {
+		return api.Fail(err, apiHttp.PageErrorCode(err))
 	}
 	return api.Success(users, http.StatusOK)
 }
@@ -147,13 +191,14 @@ func (h *handler) SignIn(ctx context.Context, r *api.Request) (resp api.Response
 	body := []byte(r.Body)
 	var req t.SignInReq
 	if err := json.Unmarshal(body, &req); err != nil {
-		return api.Fail(err, http.StatusInternalServerError)
+		return api.Fail(err, http.StatusUnprocessableEntity)
 	}
+	req.IP = api.GetClientIP(r)
 	var user *t.User
 	if user, err = h.usecase.SignIn(ctx, &req); err != nil {
-		return api.Fail(err, http.StatusInternalServerError)
+		return api.Fail(err, passwordErrorCode(err))
 	}
-	if user.AuthToken, err = h.jwt.GenAccessToken(user); err != nil {
+	if user.AuthToken, user.RefreshToken, err = h.jwt.GenTokens(ctx, user); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
 	return api.Success(user, http.StatusCreated)
@@ -165,15 +210,181 @@ func (h *handler) Confirm(ctx context.Context, req *api.Request) (resp api.Respo
 	if id, err = api.GetPathParam("id", req); err != nil {
 		return api.Fail(err, http.StatusInternalServerError)
 	}
-	if err := h.usecase.Confirm(ctx, id); err != nil {
+	confirm := &t.ConfirmReq{ID: id, Token: req.QueryStringParameters["token"]}
+	if err := h.usecase.Confirm(ctx, confirm); err != nil {
 		return api.Fail(err, http.StatusUnprocessableEntity)
 	}
 	return api.Success(map[string]interface{}{"success": true}, http.StatusNoContent)
 }
 
+// ResendConfirmation emails a user a new confirmation link
+func (h *handler) ResendConfirmation(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = h.usecase.ResendConfirmation(ctx, id); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// AlertUsers of a possible unsafe contact
+func (h *handler) AlertUsers(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req t.AlertUsers
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = h.usecase.AlertUsers(ctx, req.IDs); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// UpdateRoles granted to a user
+func (h *handler) UpdateRoles(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", r); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	body := []byte(r.Body)
+	var req t.UpdateRoles
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	req.ID = id
+	var user *t.User
+	if user, err = h.usecase.UpdateRoles(ctx, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(user, http.StatusOK)
+}
+
+// Refresh a user's tokens
+func (h *handler) Refresh(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req auth.RefreshReq
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	var user *t.User
+	accessToken, refreshToken, err := h.jwt.Refresh(ctx, req.RefreshToken, func(ctx context.Context, id string) (auth.Authable, error) {
+		var err error
+		user, err = h.usecase.Get(ctx, id)
+		return user, err
+	})
+	if err != nil {
+		return api.Fail(err, auth.StatusCode(err))
+	}
+	user.AuthToken, user.RefreshToken = accessToken, refreshToken
+	return api.Success(user, http.StatusOK)
+}
+
+// Logout revokes the caller's access token and, when given, their refresh token
+func (h *handler) Logout(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	var req auth.LogoutReq
+	if r.Body != "" {
+		if err := json.Unmarshal([]byte(r.Body), &req); err != nil {
+			return api.Fail(err, http.StatusInternalServerError)
+		}
+	}
+	_, claims := auth.ClaimsFromContext(ctx)
+	if err = h.jwt.Logout(ctx, claims, req.RefreshToken); err != nil {
+		return api.Fail(err, auth.StatusCode(err))
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// RevokeSessions signs a user out everywhere
+func (h *handler) RevokeSessions(ctx context.Context, req *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = h.usecase.RevokeSessions(ctx, id); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// ForgotPassword emails a user a password reset token
+func (h *handler) ForgotPassword(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req auth.ForgotPasswordReq
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = h.usecase.ForgotPassword(ctx, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// ResetPassword of a user with an emailed reset token
+func (h *handler) ResetPassword(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	body := []byte(r.Body)
+	var req auth.ResetPasswordReq
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	if err = h.usecase.ResetPassword(ctx, &req); err != nil {
+		return api.Fail(err, passwordErrorCode(err))
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// ChangePassword of a signed in user
+func (h *handler) ChangePassword(ctx context.Context, r *api.Request) (resp api.Response, err error) {
+	var id string
+	if id, err = api.GetPathParam("id", r); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	body := []byte(r.Body)
+	var req auth.ChangePasswordReq
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.Fail(err, http.StatusInternalServerError)
+	}
+	req.ID = id
+	if err = h.usecase.ChangePassword(ctx, &req); err != nil {
+		return api.Fail(err, passwordErrorCode(err))
+	}
+	return api.Success(map[string]interface{}{"success": true}, http.StatusOK)
+}
+
+// passwordErrorCode rejects bad credentials, reset tokens and old
+// passwords, and invalid requests, as the caller's fault
+func passwordErrorCode(err error) int {
+	if _, ok := pkgErrors.Cause(err).(validator.ValidationErrors); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return auth.StatusCode(err)
+}
+
 func main() {
 	fmt.Println("Starting user lambda main...")
-	usecase, j, err := users.Init()
+	usecase, j, err := users.Init(users.Config{
+		MongoDBName:         os.Getenv("MONGO_DB_NAME"),
+		MongoURI:            os.Getenv("MONGO_URI"),
+		MongoUser:           os.Getenv("MONGO_USER"),
+		MongoPwd:            os.Getenv("MONGO_PWD"),
+		UsersHost:           os.Getenv("USERS_HOST"),
+		JWTKeyPath:          os.Getenv("JWT_KEY_PATH"),
+		JWTSecretPath:       os.Getenv("JWT_SECRET_PATH"),
+		FromEmail:           os.Getenv("NOTIFICATIONS_FROM_EMAIL"),
+		EmailPwd:            os.Getenv("EMAIL_PWD"),
+		SMTPHost:            os.Getenv("SMTP_HOST"),
+		SMTPPort:            os.Getenv("SMTP_PORT"),
+		JWTAccessExpir:      os.Getenv("JWT_ACCESS_EXPIR"),
+		JWTRefreshExpir:     os.Getenv("JWT_REFRESH_EXPIR"),
+		JWTConfirmExpir:     os.Getenv("JWT_CONFIRM_EXPIR"),
+		JWTResetExpir:       os.Getenv("JWT_RESET_EXPIR"),
+		LoginMaxAttempts:    os.Getenv("LOGIN_MAX_ATTEMPTS"),
+		LoginMaxIPAttempts:  os.Getenv("LOGIN_MAX_IP_ATTEMPTS"),
+		LoginLockoutMinutes: os.Getenv("LOGIN_LOCKOUT_MINUTES"),
+		Storage:             os.Getenv("STORAGE"),
+		PostgresURL:         os.Getenv("POSTGRES_URL"),
+	})
 	if err != nil {
 		log.Panic(err)
 	}
@@ -5,6 +5,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/contact-tracker/apiService/pkg/auth"
 	t "github.com/contact-tracker/apiService/users/types"
 )
 
@@ -31,11 +32,11 @@ func (a *LoggerAdapter) Get(ctx context.Context, id string) (*t.User, error) {
 	return user, err
 }
 
-// GetAll gets all users
-func (a *LoggerAdapter) GetAll(ctx context.Context) ([]*t.User, error) {
+// GetAll gets a page of users
+func (a *LoggerAdapter) GetAll(ctx context.Context, req *t.GetUsers) (*t.UserPage, error) {
 	defer a.Logger.Sync()
 	a.Logger.Info("getting all users")
-	users, err := a.Usecase.GetAll(ctx)
+	users, err := a.Usecase.GetAll(ctx, req)
 	a.logErr(err)
 	return users, err
 }
@@ -79,10 +80,46 @@ func (a *LoggerAdapter) SignIn(ctx context.Context, req *t.SignInReq) (*t.User,
 }
 
 // Confirm a single user
-func (a *LoggerAdapter) Confirm(ctx context.Context, id string) error {
+func (a *LoggerAdapter) Confirm(ctx context.Context, req *t.ConfirmReq) error {
 	defer a.Logger.Sync()
 	a.Logger.Info("confirming a single user")
-	err := a.Usecase.Confirm(ctx, id)
+	err := a.Usecase.Confirm(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ResendConfirmation of a single user's email
+func (a *LoggerAdapter) ResendConfirmation(ctx context.Context, id string) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("resending a user confirmation")
+	err := a.Usecase.ResendConfirmation(ctx, id)
+	a.logErr(err)
+	return err
+}
+
+// ForgotPassword emails a user a password reset token
+func (a *LoggerAdapter) ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("sending a user password reset")
+	err := a.Usecase.ForgotPassword(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ResetPassword of a single user
+func (a *LoggerAdapter) ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("resetting a user password")
+	err := a.Usecase.ResetPassword(ctx, req)
+	a.logErr(err)
+	return err
+}
+
+// ChangePassword of a single user
+func (a *LoggerAdapter) ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error {
+	defer a.Logger.Sync()
+	a.Logger.Info("changing a user password")
+	err := a.Usecase.ChangePassword(ctx, req)
 	a.logErr(err)
 	return err
 }
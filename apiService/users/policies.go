@@ -0,0 +1,31 @@
+package users
+
+import (
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
+// Route policies, shared by the http and lambda deliveries
+var (
+	// GetAllPolicy - only health officials may list every user
+	GetAllPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem)
+	// GetPolicy - users may read their own profile, and places may look
+	// up the customers they check in
+	GetPolicy = auth.Allow(auth.RolePlaceStaff, auth.RolePlaceAdmin, auth.RoleHealthOfficial, auth.RoleSystem).Own("id", auth.AllRoles...).WithScope(auth.ScopeReadUsers)
+	// UpdatePolicy - users may only update their own profile
+	UpdatePolicy = auth.Allow(auth.RoleSystem).Own("id", auth.AllRoles...)
+	// DeletePolicy - users may only delete their own account
+	DeletePolicy = auth.Allow(auth.RoleSystem).Own("id", auth.AllRoles...)
+	// UpdateRolesPolicy - roles are only granted by admins
+	UpdateRolesPolicy = auth.Allow(auth.RoleAdmin)
+	// LogoutPolicy - any signed in user may sign out
+	LogoutPolicy = auth.Allow(auth.AllRoles...)
+	// RevokeSessionsPolicy - users may sign themselves out everywhere, and
+	// admins may sign out a compromised account
+	RevokeSessionsPolicy = auth.Allow(auth.RoleAdmin).Own("id", auth.AllRoles...)
+	// ResendConfirmationPolicy - users may ask for their own confirmation email again
+	ResendConfirmationPolicy = auth.Allow(auth.RoleSystem).Own("id", auth.AllRoles...)
+	// ChangePasswordPolicy - users may only change their own password
+	ChangePasswordPolicy = auth.Allow().Own("id", auth.AllRoles...)
+	// AlertPolicy - only health officials may alert users of a contact
+	AlertPolicy = auth.Allow(auth.RoleHealthOfficial, auth.RoleSystem).WithScope(auth.ScopeAlertUsers)
+)
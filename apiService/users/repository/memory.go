@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/memory"
+	t "github.com/contact-tracker/apiService/users/types"
+)
+
+// MemoryUserRepository - keeps users in memory, for running without mongo
+type MemoryUserRepository struct {
+	dbname string
+}
+
+// NewMemoryUserRepository -
+func NewMemoryUserRepository(dbname string) *MemoryUserRepository {
+	return &MemoryUserRepository{dbname}
+}
+
+func (r *MemoryUserRepository) C(colName string) *memory.Collection {
+	return memory.C(r.dbname, colName)
+}
+
+func (r *MemoryUserRepository) Get(_ context.Context, id string) (*t.User, error) {
+	c := r.C(ColUsers)
+	c.RLock()
+	defer c.RUnlock()
+
+	doc, ok := c.Get(id)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	return copyUser(doc.(*t.User)), nil
+}
+
+func (r *MemoryUserRepository) GetByIds(_ context.Context, ids []string) ([]*t.User, error) {
+	c := r.C(ColUsers)
+	c.RLock()
+	defer c.RUnlock()
+
+	resp := []*t.User{}
+	for _, id := range ids {
+		if doc, ok := c.Get(id); ok {
+			resp = append(resp, copyUser(doc.(*t.User)))
+		}
+	}
+	return resp, nil
+}
+
+func (r *MemoryUserRepository) Search(_ context.Context, search string) ([]*t.User, error) {
+	search = strings.ToLower(search)
+	return r.find(func(user *t.User) bool {
+		return strings.Contains(strings.ToLower(user.Name), search) || strings.Contains(strings.ToLower(user.Email), search)
+	}), nil
+}
+
+func (r *MemoryUserRepository) FindByEmail(_ context.Context, email string) (*t.User, error) {
+	users := r.find(func(user *t.User) bool {
+		return strings.EqualFold(user.Email, email)
+	})
+	if len(users) == 0 {
+		return nil, memory.ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (r *MemoryUserRepository) GetAll(_ context.Context, req *t.GetUsers, page *api.PageQuery) ([]*t.User, error) {
+	search := strings.ToLower(req.Search)
+	users := r.find(func(user *t.User) bool {
+		if req.Confirmed != nil && user.Confirmed != *req.Confirmed {
+			return false
+		}
+		return strings.Contains(strings.ToLower(user.Name), search) || strings.Contains(strings.ToLower(user.Email), search)
+	})
+	after, afterID := page.Start()
+
+	resp := []*t.User{}
+	for _, i := range memory.Page(len(users), func(i int) (string, string) {
+		return users[i].SortValue(page.Field), users[i].ID
+	}, page.Desc, after, afterID, page.Limit+1) {
+		resp = append(resp, users[i])
+	}
+	return resp, nil
+}
+
+func (r *MemoryUserRepository) Update(_ context.Context, update *t.UpdateUser) (*t.User, error) {
+	c := r.C(ColUsers)
+	c.Lock()
+	defer c.Unlock()
+
+	doc, ok := c.Get(update.ID)
+	if !ok {
+		return nil, memory.ErrNotFound
+	}
+	user := copyUser(doc.(*t.User))
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.Name != nil {
+		user.Name = *update.Name
+	}
+	if update.LastLoggedIn != nil {
+		user.LastLoggedIn = update.LastLoggedIn
+	}
+	if update.Confirmed != nil {
+		user.Confirmed = *update.Confirmed
+	}
+	if update.EncryptedPassword != nil {
+		user.EncryptedPassword = *update.EncryptedPassword
+	}
+	if update.Roles != nil {
+		user.Roles = *update.Roles
+	}
+	if update.PlaceID != nil {
+		user.PlaceID = *update.PlaceID
+	}
+	c.Put(user.ID, user)
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) Create(_ context.Context, user *t.User) (*t.User, error) {
+	c := r.C(ColUsers)
+	c.Lock()
+	defer c.Unlock()
+
+	stored := copyUser(user)
+	stored.ID = uuid.New().String()
+	c.Put(stored.ID, stored)
+	return copyUser(stored), nil
+}
+
+func (r *MemoryUserRepository) Delete(_ context.Context, id string) error {
+	c := r.C(ColUsers)
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.Delete(id) {
+		return memory.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) find(match func(user *t.User) bool) []*t.User {
+	c := r.C(ColUsers)
+	c.RLock()
+	defer c.RUnlock()
+
+	resp := []*t.User{}
+	c.Each(func(doc interface{}) bool {
+		if user := doc.(*t.User); match(user) {
+			resp = append(resp, copyUser(user))
+		}
+		return true
+	})
+	return resp
+}
+
+// copyUser copies a user as stored, without the tokens mongo never stores
+func copyUser(user *t.User) *t.User {
+	cp := *user
+	cp.AuthToken = ""
+	cp.RefreshToken = ""
+	return &cp
+}
@@ -3,111 +3,140 @@ package repository
 import (
 	"context"
 	"fmt"
+	"regexp"
 
-	"github.com/globalsign/mgo"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	api "github.com/contact-tracker/apiService/pkg/api/http"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
 	t "github.com/contact-tracker/apiService/users/types"
 )
 
 var ColUsers = "users"
 
+// MongoMigrations - indexes and data migrations of the users collection
+var MongoMigrations = []m.Migration{
+	{
+		// emails are stored lower case before they are indexed as unique,
+		// failing until accounts whose emails differ only by case are
+		// merged by hand. Listed first as creating the index fails on
+		// databases which have them.
+		Version: "users-0000-normalize-emails",
+		Up:      m.NormalizeEmails(ColUsers, "em"),
+	},
+	{
+		// emails are unique ignoring case, and found by FindByEmail
+		Version: "users-0001-email-index",
+		Up:      m.CreateIndexes(ColUsers, m.UniqueIndex(m.CaseInsensitive, "em")),
+	},
+}
+
 // MongoUserRepository -
 type MongoUserRepository struct {
-	session *mgo.Session
-	dbname  string
+	client *mongo.Client
+	dbname string
 }
 
 // NewMongoUserRepository -
-func NewMongoUserRepository(m *mgo.Session, dbname string) *MongoUserRepository {
-	return &MongoUserRepository{m, dbname}
+func NewMongoUserRepository(client *mongo.Client, dbname string) *MongoUserRepository {
+	return &MongoUserRepository{client, dbname}
 }
 
-func (r *MongoUserRepository) DB() (*mgo.Session, *mgo.Database) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname)
+// Migrate creates or updates the users indexes
+func (r *MongoUserRepository) Migrate(ctx context.Context) error {
+	return m.Migrate(ctx, r.DB(), MongoMigrations)
 }
 
-func (r *MongoUserRepository) C(colName string) (*mgo.Session, *mgo.Collection) {
-	sess := r.session.Copy()
-	return sess, sess.DB(r.dbname).C(colName)
+func (r *MongoUserRepository) DB() *mongo.Database {
+	return r.client.Database(r.dbname)
 }
 
-func (r *MongoUserRepository) Get(_ context.Context, id string) (resp *t.User, err error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) C(colName string) *mongo.Collection {
+	return r.DB().Collection(colName)
+}
 
-	err = m.FindOne(c, &resp, m.M{"_id": id})
+func (r *MongoUserRepository) Get(ctx context.Context, id string) (resp *t.User, err error) {
+	c := r.C(ColUsers)
+
+	err = m.FindOne(ctx, c, &resp, m.M{"_id": id})
 	return
 }
 
-func (r *MongoUserRepository) GetByIds(_ context.Context, ids []string) (resp []*t.User, err error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) GetByIds(ctx context.Context, ids []string) (resp []*t.User, err error) {
+	c := r.C(ColUsers)
 
 	resp = []*t.User{}
-	err = m.Find(c, &resp, m.M{"_id": m.M{"$in": ids}})
+	err = m.Find(ctx, c, &resp, m.M{"_id": m.M{"$in": ids}})
 	return
 }
 
-func (r *MongoUserRepository) Search(_ context.Context, search string) (resp []*t.User, err error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) Search(ctx context.Context, search string) (resp []*t.User, err error) {
+	c := r.C(ColUsers)
 
 	resp = []*t.User{}
-	err = m.Find(c, &resp, m.M{"$or": []m.M{
+	err = m.Find(ctx, c, &resp, m.M{"$or": []m.M{
 		{"nm": m.M{"$regex": fmt.Sprintf("(?i)%s", search)}},
 		{"em": m.M{"$regex": fmt.Sprintf("(?i)%s", search)}},
 	}})
 	return
 }
 
-func (r *MongoUserRepository) FindByEmail(_ context.Context, email string) (resp *t.User, err error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (resp *t.User, err error) {
+	c := r.C(ColUsers)
 
-	err = m.FindOne(c, &resp, m.M{"em": m.M{"$regex": fmt.Sprintf("(?i)^%s$", email)}})
+	err = c.FindOne(ctx, m.M{"em": email}, options.FindOne().SetCollation(m.CaseInsensitive)).Decode(&resp)
 	return
 }
 
-func (r *MongoUserRepository) GetAll(_ context.Context) (resp []*t.User, err error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+// userSortKeys - document keys of UserSorts
+var userSortKeys = map[string]string{"name": "nm", "email": "em"}
+
+func (r *MongoUserRepository) GetAll(ctx context.Context, req *t.GetUsers, page *api.PageQuery) (resp []*t.User, err error) {
+	c := r.C(ColUsers)
 
-	err = m.Find(c, &resp, m.M{})
+	query := m.M{}
+	if req.Confirmed != nil {
+		query["conf"] = *req.Confirmed
+	}
+	if req.Search != "" {
+		search := m.M{"$regex": regexp.QuoteMeta(req.Search), "$options": "i"}
+		query["$or"] = []m.M{{"nm": search}, {"em": search}}
+	}
+	after, afterID := page.Start()
+
+	resp = []*t.User{}
+	err = m.FindPage(ctx, c, &resp, query, userSortKeys[page.Field], page.Desc, after, afterID, page.Limit+1)
 	return
 }
 
-func (r *MongoUserRepository) Update(_ context.Context, user *t.UpdateUser) (*t.User, error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) Update(ctx context.Context, user *t.UpdateUser) (*t.User, error) {
+	c := r.C(ColUsers)
 
 	var resp t.User
-	err := m.Update(c, &resp, m.M{"_id": user.ID}, m.M{"$set": user})
+	err := m.Update(ctx, c, &resp, m.M{"_id": user.ID}, m.M{"$set": user})
 	return &resp, err
 }
 
-func (r *MongoUserRepository) Create(_ context.Context, user *t.User) (*t.User, error) {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) Create(ctx context.Context, user *t.User) (*t.User, error) {
+	c := r.C(ColUsers)
 
 	var resp t.User
 	user.ID = r.newID()
-	err := m.Upsert(c, &resp, m.M{"_id": user.ID}, m.M{"$set": user})
+	err := m.Upsert(ctx, c, &resp, m.M{"_id": user.ID}, m.M{"$set": user})
 	return &resp, err
 }
 
-func (r *MongoUserRepository) Delete(_ context.Context, id string) error {
-	sess, c := r.C(ColUsers)
-	defer sess.Close()
+func (r *MongoUserRepository) Delete(ctx context.Context, id string) error {
+	c := r.C(ColUsers)
 
 	var out t.User
-	if err := m.FindOne(c, &out, m.M{"_id": id}); err != nil {
+	if err := m.FindOne(ctx, c, &out, m.M{"_id": id}); err != nil {
 		return err
 	}
 
-	return m.Remove(c, m.M{"_id": id})
+	return m.Remove(ctx, c, m.M{"_id": id})
 }
 
 func (r *MongoUserRepository) newID() string {
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
+	pg "github.com/contact-tracker/apiService/pkg/postgres"
+	t "github.com/contact-tracker/apiService/users/types"
+)
+
+// PostgresMigrations - schema of the users table
+var PostgresMigrations = []pg.Migration{
+	{
+		Version: "users-0001-create-users",
+		SQL: `CREATE TABLE users (
+			id text PRIMARY KEY,
+			email text NOT NULL,
+			name text NOT NULL,
+			encrypted_password text NOT NULL DEFAULT '',
+			confirmed boolean NOT NULL DEFAULT false,
+			last_logged_in timestamptz,
+			roles text[] NOT NULL DEFAULT '{}',
+			place_id text NOT NULL DEFAULT ''
+		);
+		CREATE INDEX users_email_idx ON users (lower(email));`,
+	},
+	{
+		// emails are stored lower case before they are indexed as unique,
+		// failing, listing their ids, until users whose emails differ only
+		// by case are merged by hand: keeping one, and changing the
+		// others' emails or deleting them after checking with their owners
+		Version: "users-0002-unique-email",
+		SQL: `DO $$
+		DECLARE
+			conflicts text;
+		BEGIN
+			SELECT string_agg(email || ' (' || ids || ')', '; ') INTO conflicts FROM (
+				SELECT lower(trim(email)) AS email, string_agg(id, ', ' ORDER BY id) AS ids
+				FROM users GROUP BY lower(trim(email)) HAVING count(*) > 1
+			) dups;
+			IF conflicts IS NOT NULL THEN
+				RAISE EXCEPTION 'users with emails differing only by case must be merged first: %', conflicts;
+			END IF;
+		END $$;
+		UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+		DROP INDEX users_email_idx;
+		CREATE UNIQUE INDEX users_email_idx ON users (lower(email));`,
+	},
+}
+
+const userColumns = "id, email, name, encrypted_password, confirmed, last_logged_in, roles, place_id"
+
+// PostgresUserRepository -
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserRepository -
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db}
+}
+
+// Migrate creates or updates the users table
+func (r *PostgresUserRepository) Migrate(ctx context.Context) error {
+	return pg.Migrate(ctx, r.db, PostgresMigrations)
+}
+
+func (r *PostgresUserRepository) Get(ctx context.Context, id string) (*t.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (r *PostgresUserRepository) GetByIds(ctx context.Context, ids []string) ([]*t.User, error) {
+	return r.find(ctx, "SELECT "+userColumns+" FROM users WHERE id = ANY($1)", pq.Array(ids))
+}
+
+func (r *PostgresUserRepository) Search(ctx context.Context, search string) ([]*t.User, error) {
+	return r.find(ctx, "SELECT "+userColumns+" FROM users WHERE strpos(lower(name), lower($1)) > 0 OR strpos(lower(email), lower($1)) > 0", search)
+}
+
+func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*t.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1) LIMIT 1", email))
+}
+
+func (r *PostgresUserRepository) GetAll(ctx context.Context, req *t.GetUsers, page *api.PageQuery) ([]*t.User, error) {
+	args := pg.Args{}
+	where := []string{"true"}
+	if req.Confirmed != nil {
+		where = append(where, "confirmed = "+args.Add(*req.Confirmed))
+	}
+	if req.Search != "" {
+		search := args.Add(req.Search)
+		where = append(where, "(strpos(lower(name), lower("+search+")) > 0 OR strpos(lower(email), lower("+search+")) > 0)")
+	}
+	after, afterID := page.Start()
+	order := pg.Page(&where, &args, page.Field, "id", page.Desc, after, afterID, page.Limit+1)
+	return r.find(ctx, "SELECT "+userColumns+" FROM users WHERE "+strings.Join(where, " AND ")+order, args...)
+}
+
+func (r *PostgresUserRepository) Update(ctx context.Context, user *t.UpdateUser) (*t.User, error) {
+	set := pg.Assignments{}
+	if user.Email != nil {
+		set.Set("email", *user.Email)
+	}
+	if user.Name != nil {
+		set.Set("name", *user.Name)
+	}
+	if user.LastLoggedIn != nil {
+		set.Set("last_logged_in", *user.LastLoggedIn)
+	}
+	if user.Confirmed != nil {
+		set.Set("confirmed", *user.Confirmed)
+	}
+	if user.EncryptedPassword != nil {
+		set.Set("encrypted_password", *user.EncryptedPassword)
+	}
+	if user.Roles != nil {
+		set.Set("roles", pq.Array(roleStrings(*user.Roles)))
+	}
+	if user.PlaceID != nil {
+		set.Set("place_id", *user.PlaceID)
+	}
+	if set.Empty() {
+		return r.Get(ctx, user.ID)
+	}
+	query := "UPDATE users SET " + set.String() + " WHERE id = " + set.Args.Add(user.ID) + " RETURNING " + userColumns
+	return scanUser(r.db.QueryRowContext(ctx, query, set.Args...))
+}
+
+func (r *PostgresUserRepository) Create(ctx context.Context, user *t.User) (*t.User, error) {
+	user.ID = uuid.New().String()
+	return scanUser(r.db.QueryRowContext(ctx, "INSERT INTO users ("+userColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+userColumns,
+		user.ID, user.Email, user.Name, user.EncryptedPassword, user.Confirmed, user.LastLoggedIn, pq.Array(roleStrings(user.Roles)), user.PlaceID))
+}
+
+func (r *PostgresUserRepository) Delete(ctx context.Context, id string) error {
+	return pg.CheckAffected(r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id))
+}
+
+func (r *PostgresUserRepository) find(ctx context.Context, query string, args ...interface{}) ([]*t.User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*t.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, user)
+	}
+	return resp, rows.Err()
+}
+
+func scanUser(row pg.Scanner) (*t.User, error) {
+	var (
+		user  t.User
+		roles []string
+	)
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.EncryptedPassword, &user.Confirmed, &user.LastLoggedIn, pq.Array(&roles), &user.PlaceID); err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		user.Roles = append(user.Roles, auth.Role(role))
+	}
+	return &user, nil
+}
+
+func roleStrings(roles []auth.Role) []string {
+	resp := make([]string, len(roles))
+	for i, role := range roles {
+		resp[i] = string(role)
+	}
+	return resp
+}
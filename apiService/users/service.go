@@ -5,11 +5,16 @@ import (
 	// "flag"
 	"io/ioutil"
 	"log"
+	"strconv"
+	"time"
 	// "os"
 
 	"github.com/contact-tracker/apiService/pkg/auth"
+	authRepo "github.com/contact-tracker/apiService/pkg/auth/repository"
 	"github.com/contact-tracker/apiService/pkg/email"
+	"github.com/contact-tracker/apiService/pkg/memory"
 	m "github.com/contact-tracker/apiService/pkg/mongo"
+	"github.com/contact-tracker/apiService/pkg/postgres"
 	repo "github.com/contact-tracker/apiService/users/repository"
 	t "github.com/contact-tracker/apiService/users/types"
 	// "github.com/joho/godotenv"
@@ -19,24 +24,59 @@ import (
 // UserService - is the top level signature of this service
 type UserService interface {
 	Get(ctx context.Context, id string) (*t.User, error)
-	GetAll(ctx context.Context) ([]*t.User, error)
+	GetAll(ctx context.Context, req *t.GetUsers) (*t.UserPage, error)
 	Search(ctx context.Context, search string) ([]*t.User, error)
 	Update(ctx context.Context, user *t.UpdateUser) (*t.User, error)
 	Create(ctx context.Context, user *t.CreateUser) (*t.User, error)
 	Delete(ctx context.Context, id string) error
 	SignIn(ctx context.Context, req *t.SignInReq) (*t.User, error)
-	Confirm(ctx context.Context, id string) error
+	Confirm(ctx context.Context, req *t.ConfirmReq) error
+	ResendConfirmation(ctx context.Context, id string) error
+	ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error
+	ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error
+	ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error
 	AlertUsers(ctx context.Context, ids []string) error
+	UpdateRoles(ctx context.Context, req *t.UpdateRoles) (*t.User, error)
+	RevokeSessions(ctx context.Context, id string) error
+}
+
+// Config - settings for Init, as read from the environment. Empty
+// numbers and durations fall back to their defaults.
+type Config struct {
+	MongoDBName string
+	MongoURI    string
+	MongoUser   string
+	MongoPwd    string
+	// UsersHost - this service's own url, linked to in emails
+	UsersHost     string
+	JWTKeyPath    string
+	JWTSecretPath string
+	FromEmail     string
+	EmailPwd      string
+	SMTPHost      string
+	SMTPPort      string
+	// Token lifetimes in minutes
+	JWTAccessExpir  string
+	JWTRefreshExpir string
+	JWTConfirmExpir string
+	JWTResetExpir   string
+	// Sign in throttling, see auth.LoginGuardConfig
+	LoginMaxAttempts    string
+	LoginMaxIPAttempts  string
+	LoginLockoutMinutes string
+	// Storage picks the repositories, mongo by default
+	Storage     string
+	PostgresURL string
 }
 
 // Init sets up an instance of this domains
 // usecase, pre-configured with the dependencies.
-func Init(mongoDBName, mongoHost, mongoUser, mongoPwd, usersHost, jwtKeyPath, jwtSecretPath, fromEmail, emailPwd, smtpHost, smtpPort, rpcPwd string) (UserService, *auth.JWTService, error) {
+func Init(cfg Config) (UserService, *auth.JWTService, error) {
 	// cfgPath := flag.String("config", "config.dev.yml", "path for yaml config")
 	// flag.Parse()
 	// godotenv.Load(*cfgPath)
 	// mongoDBName := os.Getenv("MONGO_DB_NAME")
-	// mongoHost := os.Getenv("MONGO_HOST")
+	// mongoURI := os.Getenv("MONGO_URI")
 	// mongoUser := os.Getenv("MONGO_USER")
 	// mongoPwd := os.Getenv("MONGO_PWD")
 	// usersHost := os.Getenv("USERS_HOST")
@@ -46,34 +86,118 @@ func Init(mongoDBName, mongoHost, mongoUser, mongoPwd, usersHost, jwtKeyPath, jw
 	// sesAccessSecret := os.Getenv("AWS_SES_ACCESS_SECRET")
 	// sesRegion := os.Getenv("AWS_SES_REGION")
 	// senderEmail := os.Getenv("SENDER_EMAIL")
-	// rpcPwd := os.Getenv("RPC_AUTH_PWD")
 
-	// Init mongo repo
-	mc, err := m.NewClient(mongoHost, mongoUser, mongoPwd)
-	if err != nil {
-		log.Fatalf("Error starting mongo client: Error: %v\n", err)
+	// Init repositories in the configured storage, mongo by default
+	var (
+		repository    repository
+		refreshTokens auth.RefreshTokenStore
+		revocations   auth.RevocationStore
+		loginAttempts auth.LoginAttemptStore
+	)
+	switch cfg.Storage {
+	case memory.Storage:
+		repository = repo.NewMemoryUserRepository(cfg.MongoDBName)
+		refreshTokens = authRepo.NewMemoryRefreshTokenRepository(cfg.MongoDBName)
+		revocations = authRepo.NewMemoryRevocationRepository(cfg.MongoDBName)
+		loginAttempts = authRepo.NewMemoryLoginAttemptRepository(cfg.MongoDBName)
+	case postgres.Storage:
+		db, err := postgres.NewClient(cfg.PostgresURL)
+		if err != nil {
+			log.Fatalf("Error starting postgres client: Error: %v\n", err)
+		}
+		pgRepo := repo.NewPostgresUserRepository(db)
+		if err = pgRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating postgres schema: %v\n", err)
+		}
+		if err = authRepo.MigratePostgres(context.Background(), db); err != nil {
+			log.Fatalf("Error migrating postgres auth schema: %v\n", err)
+		}
+		repository = pgRepo
+		refreshTokens = authRepo.NewPostgresRefreshTokenRepository(db)
+		revocations = authRepo.NewPostgresRevocationRepository(db)
+		loginAttempts = authRepo.NewPostgresLoginAttemptRepository(db)
+	default:
+		mc, err := m.NewClient(cfg.MongoURI, cfg.MongoUser, cfg.MongoPwd)
+		if err != nil {
+			log.Fatalf("Error starting mongo client: Error: %v\n", err)
+		}
+		mongoRepo := repo.NewMongoUserRepository(mc, cfg.MongoDBName)
+		if err = mongoRepo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating mongo indexes: %v\n", err)
+		}
+		if err = authRepo.MigrateMongo(context.Background(), mc, cfg.MongoDBName); err != nil {
+			log.Fatalf("Error migrating mongo auth indexes: %v\n", err)
+		}
+		repository = mongoRepo
+		refreshTokens = authRepo.NewMongoRefreshTokenRepository(mc, cfg.MongoDBName)
+		revocations = authRepo.NewMongoRevocationRepository(mc, cfg.MongoDBName)
+		loginAttempts = authRepo.NewMongoLoginAttemptRepository(mc, cfg.MongoDBName)
 	}
-	repository := repo.NewMongoUserRepository(mc, mongoDBName)
 
 	// Email
-	emailClient, err := email.NewEmailClient(fromEmail, emailPwd, smtpHost, smtpPort)
+	emailClient, err := email.NewEmailClient(cfg.FromEmail, cfg.EmailPwd, cfg.SMTPHost, cfg.SMTPPort)
 	if err != nil {
 		log.Fatalf("Error starting email client: Error: %v\n", err)
 	}
 
 	// Init jwt service
-	jwtKey, err := ioutil.ReadFile(jwtKeyPath)
+	accessExpir, err := parseInt(cfg.JWTAccessExpir, auth.DefaultAccessExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt access expiration minutes: %v\n", err)
+	}
+	if accessExpir <= 0 {
+		log.Fatalf("Error jwt access expiration minutes must be positive: %d\n", accessExpir)
+	}
+	refreshExpir, err := parseInt(cfg.JWTRefreshExpir, auth.DefaultRefreshExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt refresh expiration minutes: %v\n", err)
+	}
+	confirmExpir, err := parseInt(cfg.JWTConfirmExpir, auth.DefaultConfirmExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt confirm expiration minutes: %v\n", err)
+	}
+	resetExpir, err := parseInt(cfg.JWTResetExpir, auth.DefaultResetExpirationMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing jwt reset expiration minutes: %v\n", err)
+	}
+
+	// Init sign in throttling
+	maxAttempts, err := parseInt(cfg.LoginMaxAttempts, auth.DefaultLoginMaxAttempts)
+	if err != nil {
+		log.Fatalf("Error parsing login max attempts: %v\n", err)
+	}
+	maxIPAttempts, err := parseInt(cfg.LoginMaxIPAttempts, auth.DefaultLoginMaxIPAttempts)
+	if err != nil {
+		log.Fatalf("Error parsing login max ip attempts: %v\n", err)
+	}
+	lockoutMinutes, err := parseInt(cfg.LoginLockoutMinutes, auth.DefaultLoginLockoutMinutes)
+	if err != nil {
+		log.Fatalf("Error parsing login lockout minutes: %v\n", err)
+	}
+	guard := auth.NewLoginGuard(auth.LoginGuardConfig{
+		Service:        auth.ServiceUsers,
+		Attempts:       loginAttempts,
+		MaxAttempts:    maxAttempts,
+		MaxIPAttempts:  maxIPAttempts,
+		LockoutMinutes: lockoutMinutes,
+	})
+
+	jwtKey, err := ioutil.ReadFile(cfg.JWTKeyPath)
 	if err != nil {
-		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", jwtKeyPath, err)
+		log.Fatalf("Error reading jwt key file path: %s Error: %v\n", cfg.JWTKeyPath, err)
 	}
-	jwtSecret, err := ioutil.ReadFile(jwtSecretPath)
+	jwtSecret, err := ioutil.ReadFile(cfg.JWTSecretPath)
 	if err != nil {
-		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", jwtSecretPath, err)
+		log.Fatalf("Error reading jwt secret file path: %s Error: %v\n", cfg.JWTSecretPath, err)
 	}
 	j, err := auth.NewJWTService(auth.JWTServiceConfig{
-		Key:    jwtKey,
-		Secret: jwtSecret,
-		RPCPwd: rpcPwd,
+		Key:                      jwtKey,
+		Secret:                   jwtSecret,
+		Service:                  auth.ServiceUsers,
+		AccessExpirationMinutes:  accessExpir,
+		RefreshExpirationMinutes: refreshExpir,
+		RefreshTokens:            refreshTokens,
+		Revocations:              revocations,
 	})
 	if err != nil {
 		log.Fatalf("Error creating jwt service: %v\n", err)
@@ -83,9 +207,22 @@ func Init(mongoDBName, mongoHost, mongoUser, mongoPwd, usersHost, jwtKeyPath, jw
 	// logger, _ := zap.NewProduction()
 
 	usecase := &Usecase{
-		Repository:  repository,
-		EmailClient: emailClient,
-		usersHost:   usersHost,
+		Repository:        repository,
+		Sessions:          j,
+		Tokens:            j,
+		Guard:             guard,
+		EmailClient:       emailClient,
+		ConfirmExpiration: time.Duration(confirmExpir) * time.Minute,
+		ResetExpiration:   time.Duration(resetExpir) * time.Minute,
+		usersHost:         cfg.UsersHost,
 	}
 	return usecase, j, nil
 }
+
+// parseInt parses an integer config value, using def when empty
+func parseInt(val string, def int) (int, error) {
+	if val == "" {
+		return def, nil
+	}
+	return strconv.Atoi(val)
+}
@@ -1,7 +1,31 @@
 package types
 
+import (
+	"github.com/contact-tracker/apiService/pkg/auth"
+)
+
 // SignInReq - request to check in user at place
 type SignInReq struct {
 	Email    string `json:"email" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	// IP of the client signing in, to throttle failed attempts
+	IP string `json:"-"`
+}
+
+// UpdateRoles - request to change the roles a user is granted
+type UpdateRoles struct {
+	ID      string      `json:"-"`
+	Roles   []auth.Role `json:"roles" validate:"required,gte=1"`
+	PlaceID string      `json:"placeId"`
+}
+
+// ConfirmReq - request to confirm a user's email with an emailed token
+type ConfirmReq struct {
+	ID    string `json:"-"`
+	Token string `json:"token" validate:"required"`
+}
+
+// AlertUsers - request to alert users of a possible unsafe contact
+type AlertUsers struct {
+	IDs []string `json:"ids" validate:"required"`
 }
@@ -2,31 +2,68 @@ package types
 
 import (
 	"time"
+
+	api "github.com/contact-tracker/apiService/pkg/api/http"
+	"github.com/contact-tracker/apiService/pkg/auth"
 )
 
 // User -
 type User struct {
-	ID                string     `bson:"_id" json:"id"`
-	Email             string     `bson:"em" json:"email" validate:"email,required"`
-	Name              string     `bson:"nm" json:"name" validate:"required,gte=1,lte=50"`
-	EncryptedPassword string     `bson:"pwd" json:"-"`
-	Confirmed         bool       `bson:"conf" json:"confirmed"`
-	LastLoggedIn      *time.Time `bson:"lstLogIn" json:"lastLoggedIn"`
-	AuthToken         string     `bson:"-" json:"authToken"`
+	ID                string      `bson:"_id" json:"id"`
+	Email             string      `bson:"em" json:"email" validate:"email,required"`
+	Name              string      `bson:"nm" json:"name" validate:"required,gte=1,lte=50"`
+	EncryptedPassword string      `bson:"pwd" json:"-"`
+	Confirmed         bool        `bson:"conf" json:"confirmed"`
+	LastLoggedIn      *time.Time  `bson:"lstLogIn" json:"lastLoggedIn"`
+	Roles             []auth.Role `bson:"roles,omitempty" json:"roles,omitempty"`
+	PlaceID           string      `bson:"placeId,omitempty" json:"placeId,omitempty"`
+	AuthToken         string      `bson:"-" json:"authToken"`
+	RefreshToken      string      `bson:"-" json:"refreshToken,omitempty"`
 }
 
 func (u User) GetAuthables() (id, email string, conf bool) {
 	return u.ID, u.Email, u.Confirmed
 }
 
+func (u User) GetAuthRoles() (roles []auth.Role, placeID string) {
+	return u.Roles, u.PlaceID
+}
+
+// UserSorts - fields users may be sorted by, the first by default
+var UserSorts = []string{"name", "email"}
+
+// SortValue returns the value of one of UserSorts
+func (u User) SortValue(field string) string {
+	if field == "email" {
+		return u.Email
+	}
+	return u.Name
+}
+
+// GetUsers - filters and page of a list of users
+type GetUsers struct {
+	api.PageRequest
+	Confirmed *bool `json:"confirmed"`
+	// Search matches part of a user's name or email, ignoring case
+	Search string `json:"search"`
+}
+
+// UserPage - a page of users
+type UserPage struct {
+	Items []*User `json:"items"`
+	api.PageInfo
+}
+
 // UpdateUser -
 type UpdateUser struct {
-	ID                string     `bson:"-" json:"id"`
-	Email             *string    `bson:"em,omitempty" json:"email,omitempty"`
-	Name              *string    `bson:"nm,omitempty" json:"name,omitempty" validate:"gte=1,lte=50"`
-	LastLoggedIn      *time.Time `bson:"lstLogIn,omitempty" json:"-"`
-	Confirmed         *bool      `bson:"conf,omitempty" json:"-"`
-	EncryptedPassword *string    `bson:"pwd,omitempty" json:"-"`
+	ID                string       `bson:"-" json:"id"`
+	Email             *string      `bson:"em,omitempty" json:"email,omitempty"`
+	Name              *string      `bson:"nm,omitempty" json:"name,omitempty" validate:"gte=1,lte=50"`
+	LastLoggedIn      *time.Time   `bson:"lstLogIn,omitempty" json:"-"`
+	Confirmed         *bool        `bson:"conf,omitempty" json:"-"`
+	EncryptedPassword *string      `bson:"pwd,omitempty" json:"-"`
+	Roles             *[]auth.Role `bson:"roles,omitempty" json:"-"`
+	PlaceID           *string      `bson:"placeId,omitempty" json:"-"`
 }
 
 // CreateUser -
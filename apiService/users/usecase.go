@@ -3,8 +3,11 @@ package users
 import (
 	"context"
 	"fmt"
+	"log"
+	"net/url"
 	"time"
 
+	api "github.com/contact-tracker/apiService/pkg/api/http"
 	"github.com/contact-tracker/apiService/pkg/auth"
 	"github.com/contact-tracker/apiService/pkg/email"
 	t "github.com/contact-tracker/apiService/users/types"
@@ -22,18 +25,40 @@ type repository interface {
 	Get(ctx context.Context, id string) (*t.User, error)
 	GetByIds(ctx context.Context, id []string) ([]*t.User, error)
 	Search(ctx context.Context, search string) ([]*t.User, error)
-	GetAll(ctx context.Context) ([]*t.User, error)
+	GetAll(ctx context.Context, req *t.GetUsers, page *api.PageQuery) ([]*t.User, error)
 	Update(ctx context.Context, user *t.UpdateUser) (*t.User, error)
 	FindByEmail(ctx context.Context, email string) (*t.User, error)
 	Create(ctx context.Context, user *t.User) (*t.User, error)
 	Delete(ctx context.Context, id string) error
 }
 
+type sessions interface {
+	RevokeAll(ctx context.Context, subject string) error
+}
+
+type loginGuard interface {
+	Check(ctx context.Context, email, ip string) error
+	Fail(ctx context.Context, email, ip string) error
+	Succeed(ctx context.Context, email string) error
+}
+
+type actionTokens interface {
+	GenActionToken(subject, use string, expiresIn time.Duration) (string, error)
+	UseActionToken(ctx context.Context, token, use, subject string) (*auth.CustomClaims, error)
+}
+
 // Usecase for interacting with users
 type Usecase struct {
 	Repository  repository
+	Sessions    sessions
+	Tokens      actionTokens
+	Guard       loginGuard
 	EmailClient *email.EmailClient
-	usersHost   string
+	// ConfirmExpiration is how long an emailed confirmation link is valid for
+	ConfirmExpiration time.Duration
+	// ResetExpiration is how long an emailed password reset token is valid for
+	ResetExpiration time.Duration
+	usersHost       string
 }
 
 // Get a single user
@@ -45,13 +70,29 @@ func (u *Usecase) Get(ctx context.Context, id string) (*t.User, error) {
 	return user, nil
 }
 
-// GetAll gets all users
-func (u *Usecase) GetAll(ctx context.Context) ([]*t.User, error) {
-	users, err := u.Repository.GetAll(ctx)
+// GetAll gets a page of users
+func (u *Usecase) GetAll(ctx context.Context, req *t.GetUsers) (*t.UserPage, error) {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+	page, err := req.Query(t.UserSorts...)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := u.Repository.GetAll(ctx, req, page)
 	if err != nil {
 		return nil, errors.Wrap(err, "error fetching all users")
 	}
-	return users, nil
+	resp := &t.UserPage{Items: users, PageInfo: api.PageInfo{Limit: page.Limit}}
+	if len(users) > page.Limit {
+		resp.Items = users[:page.Limit]
+		last := resp.Items[page.Limit-1]
+		resp.NextCursor = page.NextCursor(last.SortValue(page.Field), last.ID)
+	}
+	return resp, nil
 }
 
 // Search for users
@@ -71,9 +112,28 @@ func (u *Usecase) Update(ctx context.Context, user *t.UpdateUser) (resp *t.User,
 		return nil, validationErrors
 	}
 
+	// A new email must be confirmed again before the user counts as confirmed
+	emailChanged := false
+	if user.Email != nil {
+		normalized := email.Normalize(*user.Email)
+		user.Email = &normalized
+		var current *t.User
+		if current, err = u.Repository.Get(ctx, user.ID); err != nil {
+			return nil, errors.Wrap(err, "error getting user to update")
+		}
+		if emailChanged = normalized != current.Email; emailChanged {
+			unconfirmed := false
+			user.Confirmed = &unconfirmed
+		}
+	}
 	if resp, err = u.Repository.Update(ctx, user); err != nil {
 		return nil, errors.Wrap(err, "error updating user")
 	}
+	if emailChanged {
+		if err := u.sendConfirmation(resp); err != nil {
+			log.Printf("Error sending confirmation email to user %s: %v\n", resp.ID, err)
+		}
+	}
 	return resp, nil
 }
 
@@ -85,12 +145,21 @@ func (u *Usecase) SignIn(ctx context.Context, req *t.SignInReq) (resp *t.User, e
 		return nil, validationErrors
 	}
 
+	if err = u.Guard.Check(ctx, req.Email, req.IP); err != nil {
+		return nil, err
+	}
 	user, err := u.Repository.FindByEmail(ctx, req.Email)
+	if err == nil {
+		err = auth.ValidateCredentials(user.EncryptedPassword, req.Password)
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "error finding user by email")
+		if err = u.Guard.Fail(ctx, req.Email, req.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrap(auth.ErrInvalidCredentials, "error validating credentials")
 	}
-	if err = auth.ValidateCredentials(user.EncryptedPassword, req.Password); err != nil {
-		return nil, errors.Wrap(err, "error validating credentials")
+	if err = u.Guard.Succeed(ctx, req.Email); err != nil {
+		return nil, errors.Wrap(err, "error clearing failed sign ins")
 	}
 
 	now := time.Now()
@@ -109,6 +178,7 @@ func (u *Usecase) Create(ctx context.Context, req *t.CreateUser) (resp *t.User,
 		return nil, validationErrors
 	}
 
+	req.Email = email.Normalize(req.Email)
 	if _, err := u.Repository.FindByEmail(ctx, req.Email); err == nil {
 		return nil, errors.New("error user for email already exists")
 	}
@@ -125,6 +195,12 @@ func (u *Usecase) Create(ctx context.Context, req *t.CreateUser) (resp *t.User,
 		return nil, errors.Wrap(err, "error creating new user")
 	}
 
+	// The account exists either way, so a failed email is only logged
+	// and the user can ask for another
+	if err := u.sendConfirmation(resp); err != nil {
+		log.Printf("Error sending confirmation email to user %s: %v\n", resp.ID, err)
+	}
+
 	return resp, nil
 }
 
@@ -136,23 +212,93 @@ func (u *Usecase) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// Confirm a single user
-func (u *Usecase) Confirm(ctx context.Context, id string) error {
-	user, err := u.Repository.Get(ctx, id)
+// Confirm a single user's email with the token from their confirmation email
+func (u *Usecase) Confirm(ctx context.Context, req *t.ConfirmReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return validationErrors
+	}
+
+	if _, err := u.Tokens.UseActionToken(ctx, req.Token, auth.TokenUseConfirm, req.ID); err != nil {
+		return err
+	}
+	user, err := u.Repository.Get(ctx, req.ID)
 	if err != nil {
-		errors.Wrap(err, "error getting user for confirmation")
+		return errors.Wrap(err, "error getting user for confirmation")
 	}
 	if user.Confirmed {
 		return fmt.Errorf("user already confirmed")
 	}
 
 	c := true
-	if _, err := u.Repository.Update(ctx, &t.UpdateUser{ID: id, Confirmed: &c}); err != nil {
+	if _, err := u.Repository.Update(ctx, &t.UpdateUser{ID: req.ID, Confirmed: &c}); err != nil {
 		return errors.Wrap(err, "error confirming user")
 	}
 	return nil
 }
 
+// ResendConfirmation emails an unconfirmed user a new confirmation link
+func (u *Usecase) ResendConfirmation(ctx context.Context, id string) error {
+	user, err := u.Repository.Get(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "error getting user for confirmation")
+	}
+	if user.Confirmed {
+		return fmt.Errorf("user already confirmed")
+	}
+	if err = u.sendConfirmation(user); err != nil {
+		return errors.Wrap(err, "error sending confirmation email")
+	}
+	return nil
+}
+
+// sendConfirmation emails the user a link to confirm their email address
+func (u *Usecase) sendConfirmation(user *t.User) error {
+	token, err := u.Tokens.GenActionToken(user.ID, auth.TokenUseConfirm, u.ConfirmExpiration)
+	if err != nil {
+		return errors.Wrap(err, "error generating confirmation token")
+	}
+	link := fmt.Sprintf("%s/users/%s/confirm?token=%s", u.usersHost, user.ID, url.QueryEscape(token))
+	return u.EmailClient.SendEmail(
+		user.Email,
+		"Confirm Your Contact Tracker Account",
+		fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below.\n\n%s\n\nThank You,\nContact Tracker Team", user.Name, link),
+	)
+}
+
+// UpdateRoles grants a user roles, replacing those they had. Place staff
+// and admins must be granted the place they act for. The system role is
+// only held by service tokens, whose scopes limit it, so it isn't granted.
+func (u *Usecase) UpdateRoles(ctx context.Context, req *t.UpdateRoles) (resp *t.User, err error) {
+	validate = validator.New()
+	if err = validate.Struct(*req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		return nil, validationErrors
+	}
+
+	placeID := ""
+	for _, role := range req.Roles {
+		if !auth.ValidRole(role) {
+			return nil, fmt.Errorf("error invalid role %s", role)
+		}
+		if role == auth.RoleSystem {
+			return nil, fmt.Errorf("error role %s can't be granted to users", role)
+		}
+		if role == auth.RolePlaceStaff || role == auth.RolePlaceAdmin {
+			if req.PlaceID == "" {
+				return nil, fmt.Errorf("error role %s requires a place", role)
+			}
+			placeID = req.PlaceID
+		}
+	}
+
+	if resp, err = u.Repository.Update(ctx, &t.UpdateUser{ID: req.ID, Roles: &req.Roles, PlaceID: &placeID}); err != nil {
+		return nil, errors.Wrap(err, "error updating user roles")
+	}
+	return resp, nil
+}
+
 // Alert users of possible unsafe contact
 func (u *Usecase) AlertUsers(ctx context.Context, ids []string) (err error) {
 	var users []*t.User
@@ -172,6 +318,91 @@ func (u *Usecase) AlertUsers(ctx context.Context, ids []string) (err error) {
 	return nil
 }
 
+// RevokeSessions signs a user out everywhere by revoking every token issued to them
+func (u *Usecase) RevokeSessions(ctx context.Context, id string) error {
+	if _, err := u.Repository.Get(ctx, id); err != nil {
+		return errors.Wrap(err, "error getting user to revoke sessions")
+	}
+	if err := u.Sessions.RevokeAll(ctx, id); err != nil {
+		return errors.Wrap(err, "error revoking user sessions")
+	}
+	return nil
+}
+
+// ForgotPassword emails a password reset token to the user with the given
+// email. Unknown emails succeed silently so they can't be used to find accounts.
+func (u *Usecase) ForgotPassword(ctx context.Context, req *auth.ForgotPasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	user, err := u.Repository.FindByEmail(ctx, req.Email)
+	if err != nil {
+		return nil
+	}
+	token, err := u.Tokens.GenActionToken(user.ID, auth.TokenUseReset, u.ResetExpiration)
+	if err != nil {
+		return errors.Wrap(err, "error generating password reset token")
+	}
+	if err = u.EmailClient.SendEmail(
+		user.Email,
+		"Reset Your Contact Tracker Password",
+		fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Use the token below to choose a new password at %s/users/password/reset. If you did not ask to reset your password, you can ignore this email.\n\n%s\n\nThank You,\nContact Tracker Team", user.Name, u.usersHost, token),
+	); err != nil {
+		return errors.Wrap(err, "error sending password reset email")
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with an emailed reset token, signing
+// the user out everywhere
+func (u *Usecase) ResetPassword(ctx context.Context, req *auth.ResetPasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	claims, err := u.Tokens.UseActionToken(ctx, req.Token, auth.TokenUseReset, "")
+	if err != nil {
+		return err
+	}
+	return u.setPassword(ctx, claims.Subject, req.Password)
+}
+
+// ChangePassword of a signed in user, who must know their old password,
+// signing them out everywhere
+func (u *Usecase) ChangePassword(ctx context.Context, req *auth.ChangePasswordReq) error {
+	validate = validator.New()
+	if err := validate.Struct(*req); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	user, err := u.Repository.Get(ctx, req.ID)
+	if err != nil {
+		return errors.Wrap(err, "error getting user to change password")
+	}
+	if err = auth.ValidateCredentials(user.EncryptedPassword, req.OldPassword); err != nil {
+		return errors.Wrap(auth.ErrForbidden, "error validating old password")
+	}
+	return u.setPassword(ctx, user.ID, req.Password)
+}
+
+// setPassword stores a new password and revokes every token issued with the old one
+func (u *Usecase) setPassword(ctx context.Context, id, password string) error {
+	encrypted, err := auth.EncryptPassword(password)
+	if err != nil {
+		return errors.Wrap(err, "error encrypting password")
+	}
+	if _, err = u.Repository.Update(ctx, &t.UpdateUser{ID: id, EncryptedPassword: &encrypted}); err != nil {
+		return errors.Wrap(err, "error updating user password")
+	}
+	if err = u.Sessions.RevokeAll(ctx, id); err != nil {
+		return errors.Wrap(err, "error revoking user sessions")
+	}
+	return nil
+}
+
 func (u *Usecase) newID() string {
 	uid := uuid.New()
 	return uid.String()
@@ -20,7 +20,7 @@ const mapDispatch = (dispatch: any) => {
         console.log("before loading checkins...")
         const resp = await instance.get(`/check-ins?userId=${userId}`)
         console.log("resp", resp)
-        const data = resp?.data || []
+        const data = resp?.data?.items || []
         dispatch(setCheckIns(data))
         return true
       } catch (e) {